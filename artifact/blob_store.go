@@ -1,31 +1,96 @@
 package artifact
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/flanksource/duty/models"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// ContentAddressedPrefix is the path prefix under which content-addressed blobs are stored.
+const ContentAddressedPrefix = "cas/"
+
 type BlobStore interface {
 	Write(data Data, artifact *models.Artifact) (*models.Artifact, error)
 	Read(artifactID uuid.UUID) (*Data, error)
+
+	// ReadRange reads length bytes of the artifact starting at offset.
+	// A length of 0 reads until the end of the artifact.
+	ReadRange(artifactID uuid.UUID, offset, length int64) (*Data, error)
+
+	// Upload replaces the content of an existing artifact.
+	Upload(artifactID uuid.UUID, data Data) (*models.Artifact, error)
+
+	// SignedURL returns a URL that grants direct GET or PUT access to the artifact until expiry elapses.
+	// Backends that cannot presign URLs fall back to the HMAC signer configured with WithURLSigner.
+	SignedURL(artifactID uuid.UUID, expiry time.Duration, method string) (string, error)
+
+	// DeleteOrphans frees content-addressed blobs that are no longer referenced
+	// by any live artifact and whose last reference was deleted before olderThan.
+	// It returns the number of blobs freed.
+	DeleteOrphans(olderThan time.Duration) (int, error)
+
+	// ApplyRetention soft-deletes the artifacts expired by the policy that are inline
+	// or stored on the store's connection, and removes their blobs from the backend.
+	ApplyRetention(policy RetentionPolicy) (RetentionResult, error)
+
+	// DeleteExpired soft-deletes the artifacts whose expires_at has passed that are inline
+	// or stored on the store's connection, and removes their blobs from the backend.
+	DeleteExpired() (RetentionResult, error)
 	io.Closer
 }
 
+type BlobStoreOption func(*blobStore)
+
+// WithContentAddressing stores blobs under their SHA-256 checksum
+// so that identical payloads share a single stored object.
+func WithContentAddressing() BlobStoreOption {
+	return func(s *blobStore) {
+		s.contentAddressed = true
+	}
+}
+
+// WithConnectionID records the connection of the backend on the artifacts written to it,
+// so that they can be told apart from the artifacts of other backends, e.g. when migrating.
+func WithConnectionID(id uuid.UUID) BlobStoreOption {
+	return func(s *blobStore) {
+		s.connectionID = id
+	}
+}
+
 type blobStore struct {
-	fs      FilesystemRW
-	db      *gorm.DB
-	backend string
+	fs           FilesystemRW
+	db           *gorm.DB
+	backend      string
+	connectionID uuid.UUID
+
+	contentAddressed bool
+	signer           *HMACSigner
+	keeper           Keeper
 }
 
-func NewBlobStore(fs FilesystemRW, db *gorm.DB, backend string) BlobStore {
-	return &blobStore{fs: fs, db: db, backend: backend}
+func NewBlobStore(fs FilesystemRW, db *gorm.DB, backend string, opts ...BlobStoreOption) BlobStore {
+	s := &blobStore{fs: fs, db: db, backend: backend}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ContentAddressedPath returns the storage path for a blob with the given SHA-256 checksum.
+func ContentAddressedPath(checksum string) string {
+	if len(checksum) < 2 {
+		return ContentAddressedPrefix + checksum
+	}
+	return path.Join(ContentAddressedPrefix, checksum[:2], checksum)
 }
 
 func (s *blobStore) Write(data Data, a *models.Artifact) (*models.Artifact, error) {
@@ -37,15 +102,95 @@ func (s *blobStore) Write(data Data, a *models.Artifact) (*models.Artifact, erro
 	}
 	defer func() { _ = data.Content.Close() }()
 
+	if err := s.writeContent(data, a, func(tx *gorm.DB) error {
+		s.setConnection(a)
+		if err := tx.Create(a).Error; err != nil {
+			return fmt.Errorf("saving artifact to db: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (s *blobStore) Upload(artifactID uuid.UUID, data Data) (*models.Artifact, error) {
+	if data.Content == nil {
+		return nil, fmt.Errorf("artifact data content is nil")
+	}
+	defer func() { _ = data.Content.Close() }()
+
+	var a models.Artifact
+	if err := s.db.Where("id = ?", artifactID).First(&a).Error; err != nil {
+		return nil, fmt.Errorf("finding artifact %s: %w", artifactID, err)
+	}
+
+	filename := a.Filename
+	if s.contentAddressed {
+		data.Filename = a.Filename
+	} else {
+		data.Filename = a.Path
+	}
+	if data.ContentType == "" {
+		data.ContentType = a.ContentType
+	}
+
+	if a.IsInline() && strings.HasPrefix(a.Path, ContentAddressedPrefix) {
+		// The inline content is shared by every artifact with the same path,
+		// so hand it over to another reference before replacing it.
+		if err := s.db.Exec(`UPDATE artifacts SET content = ?, compression_type = ?
+			WHERE id = (SELECT id FROM artifacts WHERE path = ? AND id != ? AND content IS NULL LIMIT 1)`,
+			a.Content, a.CompressionType, a.Path, a.ID).Error; err != nil {
+			return nil, fmt.Errorf("moving shared content of artifact %s: %w", artifactID, err)
+		}
+	}
+
+	a.Content, a.CompressionType = nil, ""
+	if err := s.writeContent(data, &a, func(tx *gorm.DB) error {
+		a.Filename = filename
+		s.setConnection(&a)
+		if err := tx.Save(&a).Error; err != nil {
+			return fmt.Errorf("saving artifact %s: %w", artifactID, err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// setConnection points an artifact to the connection its content was written to.
+// Inline content has no connection; artifacts keep theirs if the store's connection is unknown.
+func (s *blobStore) setConnection(a *models.Artifact) {
+	if a.IsInline() {
+		a.ConnectionID = uuid.Nil
+	} else if s.connectionID != uuid.Nil {
+		a.ConnectionID = s.connectionID
+	}
+}
+
+// writeContent writes the data to the backend, populates the artifact's
+// path, size, checksum and content type from it and saves the artifact with save.
+func (s *blobStore) writeContent(data Data, a *models.Artifact, save func(tx *gorm.DB) error) error {
+	if s.contentAddressed {
+		return s.writeContentAddressed(data, a, save)
+	}
+
+	var size byteCounter
 	checksum := sha256.New()
-	mimeReader := io.TeeReader(data.Content, checksum)
+	mimeReader := io.TeeReader(data.Content, io.MultiWriter(checksum, &size))
 
 	mw := &mimeWriter{Max: maxBytesForMimeDetection}
-	fileReader := io.TeeReader(mimeReader, mw)
+	fileReader, err := s.encrypt(io.TeeReader(mimeReader, mw), a)
+	if err != nil {
+		return fmt.Errorf("encrypting artifact %s: %w", data.Filename, err)
+	}
 
 	info, err := s.fs.Write(s.db.Statement.Context, data.Filename, fileReader)
 	if err != nil {
-		return nil, fmt.Errorf("writing artifact %s: %w", data.Filename, err)
+		return fmt.Errorf("writing artifact %s: %w", data.Filename, err)
 	}
 
 	if data.ContentType == "" {
@@ -60,49 +205,202 @@ func (s *blobStore) Write(data Data, a *models.Artifact) (*models.Artifact, erro
 
 	a.Path = data.Filename
 	a.Filename = info.Name()
-	a.Size = info.Size()
+	a.Size = int64(size)
 	a.ContentType = data.ContentType
 	a.Checksum = hex.EncodeToString(checksum.Sum(nil))
+	return save(s.db)
+}
 
-	if err := s.db.Create(a).Error; err != nil {
-		return nil, fmt.Errorf("saving artifact to db: %w", err)
+// writeContentAddressed spools the content to a temporary file to compute its checksum,
+// and only writes it to the backend if no object with the same checksum exists yet.
+//
+// The blob is reused and the artifact saved under the lock of the blob,
+// so DeleteOrphans can't delete a blob that an artifact is about to reference.
+func (s *blobStore) writeContentAddressed(data Data, a *models.Artifact, save func(tx *gorm.DB) error) error {
+	spool, err := os.CreateTemp("", "artifact-*")
+	if err != nil {
+		return fmt.Errorf("creating spool file for %s: %w", data.Filename, err)
 	}
+	defer func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+	}()
 
-	return a, nil
+	checksum := sha256.New()
+	mw := &mimeWriter{Max: maxBytesForMimeDetection}
+	size, err := io.Copy(io.MultiWriter(spool, checksum, mw), data.Content)
+	if err != nil {
+		return fmt.Errorf("spooling artifact %s: %w", data.Filename, err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("rewinding spool file for %s: %w", data.Filename, err)
+	}
+
+	if data.ContentType == "" {
+		data.ContentType = mw.Detect().String()
+	}
+
+	sum := hex.EncodeToString(checksum.Sum(nil))
+	blobPath := ContentAddressedPath(sum)
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBlob(tx, blobPath); err != nil {
+			return err
+		}
+
+		if _, err := s.fs.Stat(blobPath); err == nil {
+			// The blob is shared, so it must be read with the key it was written with.
+			var existing models.Artifact
+			if err := tx.Select("encryption_key", "encryption_algorithm").Where("path = ?", blobPath).Limit(1).Find(&existing).Error; err != nil {
+				return fmt.Errorf("finding encryption key of %s: %w", blobPath, err)
+			}
+			a.EncryptionKey, a.EncryptionAlgorithm = existing.EncryptionKey, existing.EncryptionAlgorithm
+		} else {
+			content, err := s.encrypt(spool, a)
+			if err != nil {
+				return fmt.Errorf("encrypting artifact %s: %w", data.Filename, err)
+			}
+
+			info, err := s.fs.Write(s.db.Statement.Context, blobPath, content)
+			if err != nil {
+				return fmt.Errorf("writing artifact %s: %w", data.Filename, err)
+			}
+
+			if inlineArt := InlineArtifact(info); inlineArt != nil {
+				a.Content = inlineArt.Content
+				a.CompressionType = inlineArt.CompressionType
+			}
+		}
+
+		a.Path = blobPath
+		a.Filename = data.Filename
+		a.Size = size
+		a.ContentType = data.ContentType
+		a.Checksum = sum
+		return save(tx)
+	})
+}
+
+// lockBlob locks a content-addressed blob until the end of the transaction.
+func lockBlob(tx *gorm.DB, blobPath string) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", blobPath).Error; err != nil {
+		return fmt.Errorf("locking blob %s: %w", blobPath, err)
+	}
+	return nil
 }
 
 func (s *blobStore) Read(artifactID uuid.UUID) (*Data, error) {
+	return s.ReadRange(artifactID, 0, 0)
+}
+
+func (s *blobStore) ReadRange(artifactID uuid.UUID, offset, length int64) (*Data, error) {
+	if err := validateRange(offset, length); err != nil {
+		return nil, err
+	}
+
 	var a models.Artifact
 	if err := s.db.Where("id = ?", artifactID).First(&a).Error; err != nil {
 		return nil, fmt.Errorf("finding artifact %s: %w", artifactID, err)
 	}
 
+	data := &Data{
+		ContentLength: rangeLength(a.Size, offset, length),
+		Checksum:      a.Checksum,
+		ContentType:   a.ContentType,
+		Filename:      a.Filename,
+	}
+
+	if a.IsEncrypted() {
+		r, err := s.readEncrypted(a, offset, length)
+		if err != nil {
+			return nil, fmt.Errorf("reading encrypted artifact %s: %w", artifactID, err)
+		}
+		data.Content = r
+		return data, nil
+	}
+
 	if a.IsInline() {
-		content, err := a.GetContent()
+		r, err := a.GetContentReader()
 		if err != nil {
 			return nil, fmt.Errorf("decompressing inline artifact %s: %w", artifactID, err)
 		}
-		return &Data{
-			Content:       io.NopCloser(bytes.NewReader(content)),
-			ContentLength: a.Size,
-			Checksum:      a.Checksum,
-			ContentType:   a.ContentType,
-			Filename:      a.Filename,
-		}, nil
+		if data.Content, err = SkipRange(r, offset, length); err != nil {
+			return nil, fmt.Errorf("reading inline artifact %s: %w", artifactID, err)
+		}
+		return data, nil
 	}
 
-	r, err := s.fs.Read(s.db.Statement.Context, a.Path)
+	var r io.ReadCloser
+	var err error
+	if offset == 0 && length == 0 {
+		r, err = s.fs.Read(s.db.Statement.Context, a.Path)
+	} else {
+		r, err = s.fs.ReadRange(s.db.Statement.Context, a.Path, offset, length)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("reading artifact %s from %s: %w", artifactID, a.Path, err)
 	}
 
-	return &Data{
-		Content:       r,
-		ContentLength: a.Size,
-		Checksum:      a.Checksum,
-		ContentType:   a.ContentType,
-		Filename:      a.Filename,
-	}, nil
+	data.Content = r
+	return data, nil
+}
+
+// rangeLength returns the number of bytes a range read of an artifact with the given size returns.
+func rangeLength(size, offset, length int64) int64 {
+	remaining := max(size-offset, 0)
+	if length > 0 {
+		return min(length, remaining)
+	}
+	return remaining
+}
+
+func (s *blobStore) DeleteOrphans(olderThan time.Duration) (int, error) {
+	var paths []string
+	if err := s.db.Raw(`
+		SELECT path FROM artifacts
+		WHERE path LIKE ?
+		GROUP BY path
+		HAVING COUNT(*) FILTER (WHERE deleted_at IS NULL) = 0
+			AND MAX(deleted_at) < NOW() - INTERVAL '1 SECOND' * ?`,
+		ContentAddressedPrefix+"%", int64(olderThan.Seconds()),
+	).Scan(&paths).Error; err != nil {
+		return 0, fmt.Errorf("finding orphaned blobs: %w", err)
+	}
+
+	freed := 0
+	for _, p := range paths {
+		var deleted bool
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := lockBlob(tx, p); err != nil {
+				return err
+			}
+
+			// An artifact written since the blob was found orphaned reuses it
+			var live int64
+			if err := tx.Raw("SELECT COUNT(*) FROM artifacts WHERE path = ? AND deleted_at IS NULL", p).Scan(&live).Error; err != nil {
+				return fmt.Errorf("counting references to blob %s: %w", p, err)
+			} else if live > 0 {
+				return nil
+			}
+
+			if err := s.fs.Delete(s.db.Statement.Context, p); err != nil {
+				return fmt.Errorf("deleting orphaned blob %s: %w", p, err)
+			}
+			if err := tx.Exec("DELETE FROM artifacts WHERE path = ? AND deleted_at IS NOT NULL", p).Error; err != nil {
+				return fmt.Errorf("deleting references to orphaned blob %s: %w", p, err)
+			}
+			deleted = true
+			return nil
+		})
+		if err != nil {
+			return freed, err
+		}
+		if deleted {
+			freed++
+		}
+	}
+
+	return freed, nil
 }
 
 func (s *blobStore) Close() error {
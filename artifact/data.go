@@ -64,3 +64,11 @@ func (t *mimeWriter) Write(bb []byte) (n int, err error) {
 func (t *mimeWriter) Detect() *mimetype.MIME {
 	return mimetype.Detect(t.buffer)
 }
+
+// byteCounter counts the bytes written to it.
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,239 @@
+package artifact
+
+import (
+	gocontext "context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/flanksource/duty/models"
+)
+
+// EncryptionAlgorithmAESGCM encrypts content with AES-256-GCM in 64 KiB chunks,
+// so that it can be streamed and read by range without decrypting the whole blob.
+const EncryptionAlgorithmAESGCM = "aes-256-gcm-chunked"
+
+const (
+	encryptionChunkSize = 64 * 1024
+	encryptionOverhead  = 16 // GCM tag size
+	encryptedChunkSize  = encryptionChunkSize + encryptionOverhead
+	dataKeySize         = 32
+)
+
+// Keeper wraps and unwraps the per-artifact data keys used to encrypt content at rest.
+// *secrets.Keeper from gocloud.dev/secrets satisfies it.
+type Keeper interface {
+	Encrypt(ctx gocontext.Context, plaintext []byte) ([]byte, error)
+	Decrypt(ctx gocontext.Context, ciphertext []byte) ([]byte, error)
+}
+
+// WithEncryption encrypts new artifacts with a per-artifact data key wrapped by keeper.
+// The keeper also unwraps the keys of encrypted artifacts on read.
+func WithEncryption(keeper Keeper) BlobStoreOption {
+	return func(s *blobStore) {
+		s.keeper = keeper
+	}
+}
+
+// newDataKey generates a data key and returns it along with its wrapped form.
+func newDataKey(ctx gocontext.Context, keeper Keeper) ([]byte, []byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, fmt.Errorf("generating data key: %w", err)
+	}
+
+	wrapped, err := keeper.Encrypt(ctx, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("wrapping data key: %w", err)
+	}
+	return key, wrapped, nil
+}
+
+// encrypt returns a reader of the ciphertext of r under a new data key,
+// and records the wrapped key on the artifact.
+// r is returned as is when encryption is not enabled.
+func (s *blobStore) encrypt(r io.Reader, a *models.Artifact) (io.Reader, error) {
+	a.EncryptionKey, a.EncryptionAlgorithm = nil, ""
+	if s.keeper == nil {
+		return r, nil
+	}
+
+	key, wrapped, err := newDataKey(s.db.Statement.Context, s.keeper)
+	if err != nil {
+		return nil, err
+	}
+	a.EncryptionKey, a.EncryptionAlgorithm = wrapped, EncryptionAlgorithmAESGCM
+	return EncryptReader(r, key)
+}
+
+func (s *blobStore) unwrapKey(a models.Artifact) ([]byte, error) {
+	if a.EncryptionAlgorithm != EncryptionAlgorithmAESGCM {
+		return nil, fmt.Errorf("unsupported encryption algorithm %q", a.EncryptionAlgorithm)
+	}
+	if s.keeper == nil {
+		return nil, fmt.Errorf("artifact is encrypted but no keeper is configured")
+	}
+
+	key, err := s.keeper.Decrypt(s.db.Statement.Context, a.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key: %w", err)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce derives the nonce of a chunk from its index.
+// The last byte flags the final chunk so that a truncated blob fails to decrypt.
+// A fixed prefix is safe because every data key encrypts a single blob.
+func chunkNonce(index uint32, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint32(nonce[7:11], index)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+type encryptingReader struct {
+	src     io.Reader
+	aead    cipher.AEAD
+	plain   []byte
+	sealed  []byte
+	pending []byte
+	index   uint32
+	done    bool
+}
+
+// EncryptReader returns a reader of the ciphertext of r.
+// Every chunk but the last holds exactly 64 KiB of plain text; the last one
+// holds the remainder, which may be empty.
+func EncryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptingReader{
+		src:    r,
+		aead:   aead,
+		plain:  make([]byte, encryptionChunkSize),
+		sealed: make([]byte, 0, encryptedChunkSize),
+	}, nil
+}
+
+func (e *encryptingReader) Read(p []byte) (int, error) {
+	for len(e.pending) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(e.src, e.plain)
+		final := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !final {
+			return 0, err
+		}
+
+		e.pending = e.aead.Seal(e.sealed[:0], chunkNonce(e.index, final), e.plain[:n], nil)
+		e.index++
+		e.done = final
+	}
+
+	n := copy(p, e.pending)
+	e.pending = e.pending[n:]
+	return n, nil
+}
+
+type decryptingReader struct {
+	src     io.ReadCloser
+	aead    cipher.AEAD
+	chunk   []byte
+	pending []byte
+	index   uint32
+	done    bool
+}
+
+// DecryptReader returns a reader of the plain text of rc, which starts at the given chunk.
+func DecryptReader(rc io.ReadCloser, key []byte, chunk uint32) (io.ReadCloser, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{src: rc, aead: aead, chunk: make([]byte, encryptedChunkSize), index: chunk}, nil
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(d.src, d.chunk)
+		final := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !final {
+			return 0, err
+		}
+		if n < encryptionOverhead {
+			return 0, fmt.Errorf("decrypting chunk %d: %w", d.index, io.ErrUnexpectedEOF)
+		}
+
+		plain, err := d.aead.Open(d.chunk[:0], chunkNonce(d.index, final), d.chunk[:n], nil)
+		if err != nil {
+			return 0, fmt.Errorf("decrypting chunk %d: %w", d.index, err)
+		}
+		d.pending = plain
+		d.index++
+		d.done = final
+	}
+
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+func (d *decryptingReader) Close() error {
+	return d.src.Close()
+}
+
+// readEncrypted reads a range of an encrypted artifact, fetching the ciphertext
+// from the first chunk that overlaps the range.
+func (s *blobStore) readEncrypted(a models.Artifact, offset, length int64) (io.ReadCloser, error) {
+	key, err := s.unwrapKey(a)
+	if err != nil {
+		return nil, err
+	}
+
+	chunk := offset / encryptionChunkSize
+	cipherOffset := chunk * encryptedChunkSize
+
+	var src io.ReadCloser
+	switch {
+	case a.IsInline():
+		if src, err = a.GetContentReader(); err == nil {
+			src, err = SkipRange(src, cipherOffset, 0)
+		}
+	case cipherOffset == 0:
+		src, err = s.fs.Read(s.db.Statement.Context, a.Path)
+	default:
+		src, err = s.fs.ReadRange(s.db.Statement.Context, a.Path, cipherOffset, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := DecryptReader(src, key, uint32(chunk))
+	if err != nil {
+		_ = src.Close()
+		return nil, err
+	}
+	return SkipRange(plain, offset-chunk*encryptionChunkSize, length)
+}
@@ -14,7 +14,16 @@ type FileInfo interface {
 type FilesystemRW interface {
 	io.Closer
 	Read(ctx gocontext.Context, path string) (io.ReadCloser, error)
+
+	// ReadRange reads length bytes of the object at path starting at offset.
+	// A length of 0 reads until the end of the object.
+	ReadRange(ctx gocontext.Context, path string, offset, length int64) (io.ReadCloser, error)
+
 	Write(ctx gocontext.Context, path string, data io.Reader) (os.FileInfo, error)
 	ReadDir(name string) ([]FileInfo, error)
 	Stat(name string) (os.FileInfo, error)
+
+	// Delete removes the object at path.
+	// Deleting an object that does not exist is not an error.
+	Delete(ctx gocontext.Context, path string) error
 }
@@ -7,8 +7,11 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
 	"github.com/flanksource/duty/artifact"
 	azureUtil "github.com/flanksource/duty/artifact/clients/azure"
 )
@@ -32,6 +35,21 @@ func (t *azureBlobFS) Read(ctx gocontext.Context, path string) (io.ReadCloser, e
 	return resp.Body, nil
 }
 
+func (t *azureBlobFS) ReadRange(ctx gocontext.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	resp, err := t.client.DownloadStream(ctx, t.container, path, &azblob.DownloadStreamOptions{
+		Range: azblob.HTTPRange{Offset: offset, Count: length},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("downloading blob %s: %w", path, err)
+	}
+	return resp.Body, nil
+}
+
+func (t *azureBlobFS) SignedURL(_ gocontext.Context, path string, expiry time.Duration) (string, error) {
+	blobClient := t.client.ServiceClient().NewContainerClient(t.container).NewBlobClient(path)
+	return blobClient.GetSASURL(sas.BlobPermissions{Read: true}, time.Now().Add(expiry), nil)
+}
+
 func (t *azureBlobFS) Write(ctx gocontext.Context, path string, data io.Reader) (os.FileInfo, error) {
 	content, err := io.ReadAll(data)
 	if err != nil {
@@ -45,6 +63,16 @@ func (t *azureBlobFS) Write(ctx gocontext.Context, path string, data io.Reader)
 	return t.Stat(path)
 }
 
+func (t *azureBlobFS) Delete(ctx gocontext.Context, path string) error {
+	if _, err := t.client.DeleteBlob(ctx, t.container, path, nil); err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return nil
+		}
+		return fmt.Errorf("deleting blob %s: %w", path, err)
+	}
+	return nil
+}
+
 func (t *azureBlobFS) ReadDir(name string) ([]artifact.FileInfo, error) {
 	prefix := name
 	if strings.Contains(prefix, "*") {
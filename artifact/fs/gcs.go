@@ -4,8 +4,10 @@ import (
 	gocontext "context"
 	"errors"
 	"io"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	gcs "cloud.google.com/go/storage"
 	"github.com/flanksource/duty/artifact"
@@ -66,6 +68,21 @@ func (t *gcsFS) Read(ctx gocontext.Context, path string) (io.ReadCloser, error)
 	return t.Client.Bucket(t.Bucket).Object(path).NewReader(ctx)
 }
 
+func (t *gcsFS) ReadRange(ctx gocontext.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	if length <= 0 {
+		length = -1
+	}
+	return t.Client.Bucket(t.Bucket).Object(path).NewRangeReader(ctx, offset, length)
+}
+
+func (t *gcsFS) SignedURL(_ gocontext.Context, path string, expiry time.Duration) (string, error) {
+	return t.Client.Bucket(t.Bucket).SignedURL(path, &gcs.SignedURLOptions{
+		Method:  http.MethodGet,
+		Expires: time.Now().Add(expiry),
+		Scheme:  gcs.SigningSchemeV4,
+	})
+}
+
 func (t *gcsFS) Write(ctx gocontext.Context, path string, data io.Reader) (os.FileInfo, error) {
 	obj := t.Client.Bucket(t.Bucket).Object(path)
 
@@ -84,3 +101,11 @@ func (t *gcsFS) Write(ctx gocontext.Context, path string, data io.Reader) (os.Fi
 
 	return t.Stat(path)
 }
+
+func (t *gcsFS) Delete(ctx gocontext.Context, path string) error {
+	err := t.Client.Bucket(t.Bucket).Object(path).Delete(ctx)
+	if errors.Is(err, gcs.ErrObjectNotExist) {
+		return nil
+	}
+	return err
+}
@@ -111,6 +111,18 @@ func (t *localFS) Read(_ gocontext.Context, path string) (io.ReadCloser, error)
 	return os.Open(p)
 }
 
+func (t *localFS) ReadRange(_ gocontext.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	p, err := t.safePath(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	return artifact.SeekRange(f, offset, length)
+}
+
 func (t *localFS) Write(_ gocontext.Context, path string, data io.Reader) (os.FileInfo, error) {
 	fullpath, err := t.safePath(path)
 	if err != nil {
@@ -133,3 +145,14 @@ func (t *localFS) Write(_ gocontext.Context, path string, data io.Reader) (os.Fi
 
 	return t.Stat(path)
 }
+
+func (t *localFS) Delete(_ gocontext.Context, path string) error {
+	p, err := t.safePath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
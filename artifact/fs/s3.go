@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -127,6 +128,36 @@ func (t *s3FS) Read(ctx gocontext.Context, key string) (io.ReadCloser, error) {
 	return results.Body, nil
 }
 
+func (t *s3FS) ReadRange(ctx gocontext.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		byteRange = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+
+	results, err := t.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(t.Bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(byteRange),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results.Body, nil
+}
+
+func (t *s3FS) SignedURL(ctx gocontext.Context, key string, expiry time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(t.Client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(t.Bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", err
+	}
+
+	return req.URL, nil
+}
+
 func (t *s3FS) Write(ctx gocontext.Context, path string, data io.Reader) (os.FileInfo, error) {
 	content, err := io.ReadAll(data)
 	if err != nil {
@@ -145,3 +176,11 @@ func (t *s3FS) Write(ctx gocontext.Context, path string, data io.Reader) (os.Fil
 
 	return t.Stat(path)
 }
+
+func (t *s3FS) Delete(ctx gocontext.Context, key string) error {
+	_, err := t.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(t.Bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
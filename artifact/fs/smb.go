@@ -49,6 +49,14 @@ func (s *smbFS) Read(_ gocontext.Context, path string) (io.ReadCloser, error) {
 	return s.Open(path)
 }
 
+func (s *smbFS) ReadRange(_ gocontext.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	f, err := s.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return artifact.SeekRange(f, offset, length)
+}
+
 func (s *smbFS) Write(_ gocontext.Context, path string, data io.Reader) (os.FileInfo, error) {
 	f, err := s.Create(path)
 	if err != nil {
@@ -63,6 +71,13 @@ func (s *smbFS) Write(_ gocontext.Context, path string, data io.Reader) (os.File
 	return f.Stat()
 }
 
+func (s *smbFS) Delete(_ gocontext.Context, path string) error {
+	if err := s.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 func (t *smbFS) ReadDir(name string) ([]artifact.FileInfo, error) {
 	if strings.Contains(name, "*") {
 		return t.ReadDirGlob(name)
@@ -89,6 +89,14 @@ func (s *sshFS) Read(_ gocontext.Context, path string) (io.ReadCloser, error) {
 	return s.Open(path)
 }
 
+func (s *sshFS) ReadRange(_ gocontext.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	f, err := s.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return artifact.SeekRange(f, offset, length)
+}
+
 func (s *sshFS) Close() error {
 	return s.Client.Close()
 }
@@ -111,3 +119,10 @@ func (s *sshFS) Write(_ gocontext.Context, path string, data io.Reader) (os.File
 
 	return f.Stat()
 }
+
+func (s *sshFS) Delete(_ gocontext.Context, path string) error {
+	if err := s.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
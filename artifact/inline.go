@@ -1,7 +1,6 @@
 package artifact
 
 import (
-	"bytes"
 	gocontext "context"
 	"fmt"
 	"io"
@@ -67,16 +66,24 @@ func InlineArtifact(info os.FileInfo) *models.Artifact {
 
 func (s *InlineStore) Read(_ gocontext.Context, path string) (io.ReadCloser, error) {
 	var artifact models.Artifact
-	if err := s.db.Where("path = ?", path).First(&artifact).Error; err != nil {
+	if err := s.db.Where("path = ? AND content IS NOT NULL", path).First(&artifact).Error; err != nil {
 		return nil, fmt.Errorf("finding inline artifact %s: %w", path, err)
 	}
 
-	content, err := artifact.GetContent()
+	r, err := artifact.GetContentReader()
 	if err != nil {
 		return nil, fmt.Errorf("decompressing inline artifact %s: %w", path, err)
 	}
 
-	return io.NopCloser(bytes.NewReader(content)), nil
+	return r, nil
+}
+
+func (s *InlineStore) ReadRange(ctx gocontext.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	r, err := s.Read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return SkipRange(r, offset, length)
 }
 
 func (s *InlineStore) ReadDir(name string) ([]FileInfo, error) {
@@ -103,7 +110,7 @@ func (s *InlineStore) ReadDir(name string) ([]FileInfo, error) {
 
 func (s *InlineStore) Stat(name string) (os.FileInfo, error) {
 	var artifact models.Artifact
-	if err := s.db.Where("path = ?", name).First(&artifact).Error; err != nil {
+	if err := s.db.Where("path = ? AND content IS NOT NULL", name).First(&artifact).Error; err != nil {
 		return nil, fmt.Errorf("stat inline artifact %s: %w", name, err)
 	}
 
@@ -115,6 +122,14 @@ func (s *InlineStore) Stat(name string) (os.FileInfo, error) {
 	}, nil
 }
 
+// Delete clears the inline content of every artifact stored at path.
+func (s *InlineStore) Delete(_ gocontext.Context, path string) error {
+	if err := s.db.Model(&models.Artifact{}).Where("path = ?", path).Update("content", nil).Error; err != nil {
+		return fmt.Errorf("deleting inline artifact %s: %w", path, err)
+	}
+	return nil
+}
+
 func (s *InlineStore) Close() error { return nil }
 
 type inlineFileInfo struct {
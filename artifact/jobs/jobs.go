@@ -0,0 +1,174 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/flanksource/duty/artifact"
+	"github.com/flanksource/duty/connection"
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/job"
+	"github.com/google/uuid"
+)
+
+// SweepOrphanedBlobs frees content-addressed blobs that are no longer referenced by any live artifact.
+var SweepOrphanedBlobs = &job.Job{
+	Name:       "SweepOrphanedArtifactBlobs",
+	Schedule:   "@every 1h",
+	Singleton:  true,
+	JobHistory: true,
+	Retention:  job.RetentionFew,
+	Fn: func(ctx job.JobRuntime) error {
+		store, err := ctx.Blobs()
+		if err != nil {
+			return fmt.Errorf("failed to get blob store: %w", err)
+		}
+		defer func() { _ = store.Close() }()
+
+		gracePeriod := ctx.Properties().Duration("artifacts.orphan.grace_period", time.Hour)
+		freed, err := store.DeleteOrphans(gracePeriod)
+		ctx.History.SuccessCount = freed
+		return err
+	},
+}
+
+// RetentionPolicies returns the artifact retention policies configured via
+// artifacts.retention.<owner>.age and artifacts.retention.<owner>.size.
+func RetentionPolicies(ctx context.Context) []artifact.RetentionPolicy {
+	var policies []artifact.RetentionPolicy
+	for _, owner := range artifact.RetentionOwners {
+		policy := artifact.RetentionPolicy{
+			Owner:   owner,
+			MaxAge:  ctx.Properties().Duration(fmt.Sprintf("artifacts.retention.%s.age", owner), 0),
+			MaxSize: int64(ctx.Properties().Int(fmt.Sprintf("artifacts.retention.%s.size", owner), 0)),
+		}
+		if !policy.Empty() {
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
+// CleanupArtifacts deletes artifacts that have passed their expires_at
+// or are expired by the configured retention policies.
+var CleanupArtifacts = &job.Job{
+	Name:       "CleanupArtifacts",
+	Schedule:   "@every 6h",
+	Singleton:  true,
+	JobHistory: true,
+	Retention:  job.RetentionFew,
+	Fn: func(ctx job.JobRuntime) error {
+		store, err := ctx.Blobs()
+		if err != nil {
+			return fmt.Errorf("failed to get blob store: %w", err)
+		}
+		defer func() { _ = store.Close() }()
+
+		var total artifact.RetentionResult
+		result, err := store.DeleteExpired()
+		total.Add(result)
+		ctx.History.AddDetails("expired", result)
+		if err != nil {
+			return err
+		}
+
+		for _, policy := range RetentionPolicies(ctx.Context) {
+			result, err := store.ApplyRetention(policy)
+			total.Add(result)
+			ctx.History.AddDetails(string(policy.Owner), result)
+			if err != nil {
+				ctx.History.AddErrorf("failed to apply retention %s: %v", policy, err)
+			}
+		}
+
+		ctx.History.AddDetails("total", total)
+		ctx.History.SuccessCount = total.Deleted
+		return nil
+	},
+}
+
+// migrationBackend returns the filesystem and connection ID of an artifact backend.
+// An empty connection URL refers to the inline store.
+func migrationBackend(ctx context.Context, connURL string) (artifact.FilesystemRW, uuid.UUID, error) {
+	if connURL == "" {
+		return artifact.NewInlineStore(ctx.DB()), uuid.Nil, nil
+	}
+
+	conn, err := ctx.HydrateConnectionByURL(connURL)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("resolving artifact connection %q: %w", connURL, err)
+	} else if conn == nil {
+		return nil, uuid.Nil, fmt.Errorf("artifact connection %q not found", connURL)
+	}
+
+	fs, err := connection.GetFSForConnection(ctx, *conn)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	return fs, conn.ID, nil
+}
+
+// MigrateArtifacts copies the artifacts stored in the source backend to the destination
+// and repoints them to it. Empty connection URLs refer to the inline store.
+// It can be run again after an interruption to migrate the remaining artifacts.
+// Inline content is kept until artifacts.connection is switched over to the destination.
+func MigrateArtifacts(ctx context.Context, sourceURL, destinationURL string, batchSize int) (artifact.MigrationResult, error) {
+	source, sourceID, err := migrationBackend(ctx, sourceURL)
+	if err != nil {
+		return artifact.MigrationResult{}, err
+	}
+	defer func() { _ = source.Close() }()
+
+	destination, destinationID, err := migrationBackend(ctx, destinationURL)
+	if err != nil {
+		return artifact.MigrationResult{}, err
+	}
+	defer func() { _ = destination.Close() }()
+
+	migration := artifact.Migration{
+		Source:                  source,
+		Destination:             destination,
+		SourceConnectionID:      sourceID,
+		DestinationConnectionID: destinationID,
+		BatchSize:               batchSize,
+	}
+	result, err := migration.Run(ctx.DB())
+	if err != nil || destinationURL != ctx.Properties().String("artifacts.connection", "") {
+		return result, err
+	}
+
+	result.Released, err = migration.ReleaseInlineContent(ctx.DB())
+	return result, err
+}
+
+// MigrateArtifactStorage migrates artifacts from artifacts.migration.source to
+// artifacts.migration.destination. It does nothing unless a destination is configured.
+var MigrateArtifactStorage = &job.Job{
+	Name:       "MigrateArtifactStorage",
+	Schedule:   "@every 15m",
+	Singleton:  true,
+	JobHistory: true,
+	Retention:  job.RetentionFew,
+	Fn: func(ctx job.JobRuntime) error {
+		destination := ctx.Properties().String("artifacts.migration.destination", "")
+		if destination == "" {
+			return nil
+		}
+
+		source := ctx.Properties().String("artifacts.migration.source", "")
+		batchSize := ctx.Properties().Int("artifacts.migration.batch_size", 100)
+		result, err := MigrateArtifacts(ctx.Context, source, destination, batchSize)
+		ctx.History.AddDetails("migration", result)
+		ctx.History.SuccessCount = result.Migrated
+		for _, e := range result.Errors {
+			ctx.History.AddError(e)
+		}
+		return err
+	},
+}
+
+var Jobs = []*job.Job{
+	SweepOrphanedBlobs,
+	CleanupArtifacts,
+	MigrateArtifactStorage,
+}
@@ -46,6 +46,53 @@ func (l *LoggedBlobStore) Read(artifactID uuid.UUID) (*Data, error) {
 	return data, err
 }
 
+func (l *LoggedBlobStore) ReadRange(artifactID uuid.UUID, offset, length int64) (*Data, error) {
+	l.logger.Debugf("%s", l.formatOp("ReadRange", fmt.Sprintf("%s (offset=%d, length=%d)", artifactID, offset, length)))
+	return l.inner.ReadRange(artifactID, offset, length)
+}
+
+func (l *LoggedBlobStore) Upload(artifactID uuid.UUID, data Data) (*models.Artifact, error) {
+	l.logger.Debugf("%s", l.formatOp("Upload", artifactID.String()))
+	start := time.Now()
+	result, err := l.inner.Upload(artifactID, data)
+	if v := l.logger.V(2); err == nil && result != nil {
+		v.Infof("%s", l.formatResult("Upload", result.Filename, result.Size, result.Checksum, time.Since(start)))
+	}
+	return result, err
+}
+
+func (l *LoggedBlobStore) SignedURL(artifactID uuid.UUID, expiry time.Duration, method string) (string, error) {
+	l.logger.Debugf("%s", l.formatOp("SignedURL", fmt.Sprintf("%s %s (%s)", method, artifactID, expiry)))
+	return l.inner.SignedURL(artifactID, expiry, method)
+}
+
+func (l *LoggedBlobStore) DeleteOrphans(olderThan time.Duration) (int, error) {
+	l.logger.Debugf("%s", l.formatOp("DeleteOrphans", olderThan.String()))
+	freed, err := l.inner.DeleteOrphans(olderThan)
+	if v := l.logger.V(2); err == nil {
+		v.Infof("%s", l.formatOp("DeleteOrphans", fmt.Sprintf("freed %d blobs", freed)))
+	}
+	return freed, err
+}
+
+func (l *LoggedBlobStore) ApplyRetention(policy RetentionPolicy) (RetentionResult, error) {
+	l.logger.Debugf("%s", l.formatOp("ApplyRetention", policy.String()))
+	result, err := l.inner.ApplyRetention(policy)
+	if v := l.logger.V(2); err == nil {
+		v.Infof("%s", l.formatRetention("ApplyRetention", result))
+	}
+	return result, err
+}
+
+func (l *LoggedBlobStore) DeleteExpired() (RetentionResult, error) {
+	l.logger.Debugf("%s", l.formatOp("DeleteExpired", ""))
+	result, err := l.inner.DeleteExpired()
+	if v := l.logger.V(2); err == nil {
+		v.Infof("%s", l.formatRetention("DeleteExpired", result))
+	}
+	return result, err
+}
+
 func (l *LoggedBlobStore) Close() error {
 	l.logger.V(2).Infof("%s", l.formatOp("Close", ""))
 	return l.inner.Close()
@@ -76,6 +123,10 @@ func (l *LoggedBlobStore) formatResult(op, filename string, size int64, checksum
 	return s.String()
 }
 
+func (l *LoggedBlobStore) formatRetention(op string, result RetentionResult) string {
+	return l.formatOp(op, fmt.Sprintf("deleted %d artifacts, %d blobs (%s)", result.Deleted, result.BlobsDeleted, formatBytes(result.FreedBytes)))
+}
+
 // LoggedFS wraps a FilesystemRW with structured logging (used by e2e tests).
 type LoggedFS struct {
 	inner   FilesystemRW
@@ -106,6 +157,20 @@ func (l *LoggedFS) Read(ctx gocontext.Context, path string) (io.ReadCloser, erro
 	return r, nil
 }
 
+func (l *LoggedFS) ReadRange(ctx gocontext.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	l.logger.Debugf("[%s] ReadRange %s (offset=%d, length=%d)", l.backend, path, offset, length)
+	return l.inner.ReadRange(ctx, path, offset, length)
+}
+
+func (l *LoggedFS) SignedURL(ctx gocontext.Context, path string, expiry time.Duration) (string, error) {
+	signer, ok := l.inner.(URLSigner)
+	if !ok {
+		return "", ErrSigningNotSupported
+	}
+	l.logger.Debugf("[%s] SignedURL %s (%s)", l.backend, path, expiry)
+	return signer.SignedURL(ctx, path, expiry)
+}
+
 func (l *LoggedFS) Write(ctx gocontext.Context, path string, data io.Reader) (os.FileInfo, error) {
 	l.logger.Debugf("[%s] Write %s", l.backend, path)
 	start := time.Now()
@@ -135,6 +200,11 @@ func (l *LoggedFS) Stat(name string) (os.FileInfo, error) {
 	return info, err
 }
 
+func (l *LoggedFS) Delete(ctx gocontext.Context, path string) error {
+	l.logger.Debugf("[%s] Delete %s", l.backend, path)
+	return l.inner.Delete(ctx, path)
+}
+
 func (l *LoggedFS) Close() error {
 	l.logger.V(2).Infof("[%s] Close", l.backend)
 	return l.inner.Close()
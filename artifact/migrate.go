@@ -0,0 +1,218 @@
+package artifact
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const defaultMigrationBatchSize = 100
+
+// Migration copies artifacts from one storage backend to another.
+//
+// Each artifact is streamed from Source to Destination, verified against its checksum
+// and then repointed to the destination in a single update, so an interrupted migration
+// resumes with the artifacts that still reference the source.
+// Blobs in an external Source and inline content are left in place so that the old backend
+// keeps serving until it is switched over; ReleaseInlineContent clears the inline content after that.
+type Migration struct {
+	Source      FilesystemRW
+	Destination FilesystemRW
+
+	// SourceConnectionID and DestinationConnectionID are the connections of the backends.
+	// uuid.Nil refers to the inline store.
+	SourceConnectionID      uuid.UUID
+	DestinationConnectionID uuid.UUID
+
+	// BatchSize is the number of artifacts loaded at a time. Defaults to 100.
+	BatchSize int
+}
+
+// MigrationResult summarizes the progress of a migration run.
+type MigrationResult struct {
+	// Migrated is the number of artifacts repointed to the destination.
+	Migrated int `json:"migrated"`
+
+	// Failed is the number of artifacts that could not be copied or verified.
+	// They still reference the source and are retried by the next run.
+	Failed int `json:"failed"`
+
+	// Bytes is the number of bytes copied to the destination.
+	Bytes int64 `json:"bytes"`
+
+	// Remaining is the number of artifacts that still reference the source after the run.
+	Remaining int64 `json:"remaining"`
+
+	// Released is the number of migrated artifacts whose inline content was cleared.
+	Released int64 `json:"released,omitempty"`
+
+	Errors []string `json:"errors,omitempty"`
+}
+
+// inlineContentExists matches artifacts whose content is stored inline,
+// including content-addressed artifacts that share the inline content of another artifact.
+const inlineContentExists = "EXISTS (SELECT 1 FROM artifacts inline WHERE inline.path = artifacts.path AND inline.content IS NOT NULL)"
+
+func (m Migration) sourceScope(db *gorm.DB) *gorm.DB {
+	db = db.Model(&models.Artifact{}).Where("deleted_at IS NULL")
+	noConnection := db.Session(&gorm.Session{NewDB: true}).Where("connection_id IS NULL OR connection_id = ?", uuid.Nil)
+	if m.SourceConnectionID == uuid.Nil {
+		return db.Where(noConnection).Where(inlineContentExists)
+	}
+
+	// Artifacts written before the blob store recorded its connection have none,
+	// those that are not inline were written to the configured external backend.
+	return db.Where(db.Session(&gorm.Session{NewDB: true}).
+		Where("connection_id = ?", m.SourceConnectionID).
+		Or(noConnection.Where("NOT " + inlineContentExists)))
+}
+
+// ReleaseInlineContent clears the inline content that was kept for artifacts migrated
+// to the destination. It must only be called once the destination serves the artifacts.
+// It returns the number of artifacts released.
+func (m Migration) ReleaseInlineContent(db *gorm.DB) (int64, error) {
+	if m.DestinationConnectionID == uuid.Nil {
+		return 0, nil
+	}
+
+	tx := db.Model(&models.Artifact{}).
+		Where("connection_id = ? AND content IS NOT NULL", m.DestinationConnectionID).
+		Updates(map[string]any{"content": nil, "compression_type": nil})
+	if tx.Error != nil {
+		return 0, fmt.Errorf("releasing inline content of migrated artifacts: %w", tx.Error)
+	}
+	return tx.RowsAffected, nil
+}
+
+// Run migrates every live artifact that references the source.
+// It only returns an error if the migration cannot proceed; failures of
+// individual artifacts are recorded on the result.
+func (m Migration) Run(db *gorm.DB) (MigrationResult, error) {
+	var result MigrationResult
+	if m.SourceConnectionID == m.DestinationConnectionID {
+		return result, fmt.Errorf("artifact migration source and destination are the same connection (%s)", m.SourceConnectionID)
+	}
+
+	batchSize := m.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultMigrationBatchSize
+	}
+
+	// Failed artifacts keep referencing the source, so the cursor skips past them.
+	var cursor uuid.UUID
+	for {
+		var batch []models.Artifact
+		if err := m.sourceScope(db).Where("id > ?", cursor).Order("id").Limit(batchSize).Find(&batch).Error; err != nil {
+			return result, fmt.Errorf("listing artifacts to migrate: %w", err)
+		}
+
+		for _, a := range batch {
+			cursor = a.ID
+			copied, err := m.migrate(db, a)
+			if err != nil {
+				result.Failed++
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", a.ID, err))
+				continue
+			}
+			result.Migrated++
+			result.Bytes += copied
+		}
+
+		if len(batch) < batchSize {
+			break
+		}
+	}
+
+	if err := m.sourceScope(db).Count(&result.Remaining).Error; err != nil {
+		return result, fmt.Errorf("counting remaining artifacts: %w", err)
+	}
+	return result, nil
+}
+
+// migrate copies a single artifact and repoints it to the destination.
+// It returns the number of bytes copied.
+func (m Migration) migrate(db *gorm.DB, a models.Artifact) (int64, error) {
+	ctx := db.Statement.Context
+	destPath := strings.TrimPrefix(a.Path, "/")
+	update := map[string]any{
+		"path":          destPath,
+		"connection_id": m.DestinationConnectionID,
+	}
+	if m.DestinationConnectionID == uuid.Nil {
+		update["connection_id"] = nil
+	}
+
+	var copied int64
+	_, statErr := m.Destination.Stat(destPath)
+	if !strings.HasPrefix(destPath, ContentAddressedPrefix) || statErr != nil {
+		var src io.ReadCloser
+		var err error
+		if a.IsInline() {
+			src, err = a.GetContentReader()
+		} else {
+			src, err = m.Source.Read(ctx, a.Path)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("reading %s from source: %w", a.Path, err)
+		}
+		defer func() { _ = src.Close() }()
+
+		sourceSum := sha256.New()
+		counter := new(byteCounter)
+		info, err := m.Destination.Write(ctx, destPath, io.TeeReader(src, io.MultiWriter(sourceSum, counter)))
+		if err != nil {
+			return 0, fmt.Errorf("writing %s to destination: %w", destPath, err)
+		}
+		copied = int64(*counter)
+
+		// Encrypted blobs are checksummed before encryption, so they are only verified against the copy.
+		if !a.IsEncrypted() && hex.EncodeToString(sourceSum.Sum(nil)) != a.Checksum {
+			return copied, fmt.Errorf("checksum mismatch reading %s from source", a.Path)
+		}
+
+		var written io.ReadCloser
+		if inlineArt := InlineArtifact(info); inlineArt != nil {
+			update["content"] = inlineArt.Content
+			update["compression_type"] = inlineArt.CompressionType
+			written, err = inlineArt.GetContentReader()
+		} else {
+			written, err = m.Destination.Read(ctx, destPath)
+		}
+		if err != nil {
+			return copied, fmt.Errorf("reading back %s from destination: %w", destPath, err)
+		}
+		defer func() { _ = written.Close() }()
+
+		if err := verifyChecksum(written, sourceSum); err != nil {
+			return copied, fmt.Errorf("verifying %s: %w", destPath, err)
+		}
+	}
+
+	// Only repoint the artifact if it was not migrated or modified concurrently.
+	tx := m.sourceScope(db).Where("id = ? AND path = ?", a.ID, a.Path).Updates(update)
+	if tx.Error != nil {
+		return copied, fmt.Errorf("updating artifact: %w", tx.Error)
+	} else if tx.RowsAffected == 0 {
+		return copied, fmt.Errorf("artifact changed during migration")
+	}
+	return copied, nil
+}
+
+func verifyChecksum(r io.Reader, expected hash.Hash) error {
+	actual := sha256.New()
+	if _, err := io.Copy(actual, r); err != nil {
+		return err
+	}
+	if !bytes.Equal(actual.Sum(nil), expected.Sum(nil)) {
+		return fmt.Errorf("checksum mismatch")
+	}
+	return nil
+}
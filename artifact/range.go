@@ -0,0 +1,55 @@
+package artifact
+
+import (
+	"fmt"
+	"io"
+)
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// LimitReadCloser returns a reader that reads at most length bytes from rc.
+// A length <= 0 reads until EOF.
+func LimitReadCloser(rc io.ReadCloser, length int64) io.ReadCloser {
+	if length <= 0 {
+		return rc
+	}
+	return readCloser{Reader: io.LimitReader(rc, length), Closer: rc}
+}
+
+// SeekRange seeks f to offset and limits it to length bytes.
+// f is closed if seeking fails.
+func SeekRange(f io.ReadSeekCloser, offset, length int64) (io.ReadCloser, error) {
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("seeking to offset %d: %w", offset, err)
+		}
+	}
+	return LimitReadCloser(f, length), nil
+}
+
+// SkipRange discards the first offset bytes of rc and limits it to length bytes.
+// It is used for streams that cannot seek, such as decompressed content.
+// rc is closed if skipping fails.
+func SkipRange(rc io.ReadCloser, offset, length int64) (io.ReadCloser, error) {
+	if offset > 0 {
+		if _, err := io.CopyN(io.Discard, rc, offset); err != nil && err != io.EOF {
+			_ = rc.Close()
+			return nil, fmt.Errorf("skipping to offset %d: %w", offset, err)
+		}
+	}
+	return LimitReadCloser(rc, length), nil
+}
+
+func validateRange(offset, length int64) error {
+	if offset < 0 {
+		return fmt.Errorf("invalid range offset %d", offset)
+	}
+	if length < 0 {
+		return fmt.Errorf("invalid range length %d", length)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package artifact
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RetentionOwner identifies the kind of resource a set of artifacts belongs to.
+type RetentionOwner string
+
+const (
+	RetentionOwnerCheck        RetentionOwner = "check"
+	RetentionOwnerPlaybookRun  RetentionOwner = "playbook_run"
+	RetentionOwnerConfigChange RetentionOwner = "config_change"
+)
+
+// RetentionOwners lists every owner a RetentionPolicy can be keyed on.
+var RetentionOwners = []RetentionOwner{RetentionOwnerCheck, RetentionOwnerPlaybookRun, RetentionOwnerConfigChange}
+
+// ownerKey returns the join clause and the column that groups artifacts by owner.
+func (o RetentionOwner) ownerKey() (string, string, error) {
+	switch o {
+	case RetentionOwnerCheck:
+		return "", "artifacts.check_id", nil
+	case RetentionOwnerPlaybookRun:
+		return "JOIN playbook_run_actions ON playbook_run_actions.id = artifacts.playbook_run_action_id", "playbook_run_actions.playbook_run_id", nil
+	case RetentionOwnerConfigChange:
+		return "", "artifacts.config_change_id", nil
+	default:
+		return "", "", fmt.Errorf("unknown artifact retention owner %q", o)
+	}
+}
+
+// RetentionPolicy decides which artifacts of an owner kind are expired.
+// An artifact is expired when it is older than MaxAge, or when it and the newer
+// artifacts of the same owner add up to more than MaxSize bytes.
+// A zero MaxAge or MaxSize disables that limit.
+type RetentionPolicy struct {
+	Owner   RetentionOwner `json:"owner"`
+	MaxAge  time.Duration  `json:"max_age,omitempty"`
+	MaxSize int64          `json:"max_size,omitempty"`
+}
+
+func (p RetentionPolicy) Empty() bool {
+	return p.MaxAge <= 0 && p.MaxSize <= 0
+}
+
+func (p RetentionPolicy) String() string {
+	return fmt.Sprintf("%s{age=%v, size=%d}", p.Owner, p.MaxAge, p.MaxSize)
+}
+
+// RetentionResult summarizes what a retention run freed.
+type RetentionResult struct {
+	// Deleted is the number of artifact rows that were soft-deleted.
+	Deleted int `json:"deleted"`
+
+	// BlobsDeleted is the number of stored objects removed from the backend.
+	BlobsDeleted int `json:"blobs_deleted"`
+
+	// FreedBytes is the total size of the soft-deleted artifacts.
+	FreedBytes int64 `json:"freed_bytes"`
+}
+
+func (r *RetentionResult) Add(other RetentionResult) {
+	r.Deleted += other.Deleted
+	r.BlobsDeleted += other.BlobsDeleted
+	r.FreedBytes += other.FreedBytes
+}
+
+// storedOn matches the artifacts whose content this store deletes: inline content, and blobs
+// written to its connection. Artifacts written before the blob store recorded its connection have none.
+func (s *blobStore) storedOn() (string, []any) {
+	return "(content IS NOT NULL OR connection_id IS NULL OR connection_id IN (?, ?))", []any{uuid.Nil, s.connectionID}
+}
+
+type expiredArtifact struct {
+	ID     uuid.UUID
+	Path   string
+	Size   int64
+	Inline bool
+}
+
+func (s *blobStore) ApplyRetention(policy RetentionPolicy) (RetentionResult, error) {
+	var result RetentionResult
+	if policy.Empty() {
+		return result, nil
+	}
+
+	join, key, err := policy.Owner.ownerKey()
+	if err != nil {
+		return result, err
+	}
+
+	var conditions []string
+	var args []any
+	if policy.MaxAge > 0 {
+		conditions = append(conditions, "created_at < NOW() - INTERVAL '1 SECOND' * ?")
+		args = append(args, int64(policy.MaxAge.Seconds()))
+	}
+	if policy.MaxSize > 0 {
+		conditions = append(conditions, "retained_size > ?")
+		args = append(args, policy.MaxSize)
+	}
+
+	// The size of an owner includes the artifacts of other backends, which their own stores delete
+	stored, storedArgs := s.storedOn()
+	args = append(args, storedArgs...)
+
+	query := fmt.Sprintf(`
+		SELECT id, path, size, inline FROM (
+			SELECT
+				artifacts.id,
+				artifacts.path,
+				artifacts.size,
+				artifacts.created_at,
+				artifacts.content,
+				artifacts.connection_id,
+				artifacts.content IS NOT NULL AS inline,
+				SUM(artifacts.size) OVER (PARTITION BY %[2]s ORDER BY artifacts.created_at DESC, artifacts.id) AS retained_size
+			FROM artifacts %[1]s
+			WHERE artifacts.deleted_at IS NULL AND %[2]s IS NOT NULL
+		) owned
+		WHERE (%[3]s) AND %[4]s`, join, key, strings.Join(conditions, " OR "), stored)
+
+	var expired []expiredArtifact
+	if err := s.db.Raw(query, args...).Scan(&expired).Error; err != nil {
+		return result, fmt.Errorf("finding expired %s artifacts: %w", policy.Owner, err)
+	}
+
+	return s.deleteArtifacts(expired)
+}
+
+func (s *blobStore) DeleteExpired() (RetentionResult, error) {
+	stored, args := s.storedOn()
+
+	var expired []expiredArtifact
+	if err := s.db.Raw(`
+		SELECT id, path, size, content IS NOT NULL AS inline FROM artifacts
+		WHERE deleted_at IS NULL AND expires_at < NOW() AND `+stored, args...,
+	).Scan(&expired).Error; err != nil {
+		return RetentionResult{}, fmt.Errorf("finding expired artifacts: %w", err)
+	}
+
+	return s.deleteArtifacts(expired)
+}
+
+// deleteArtifacts soft-deletes the given artifacts and removes their blobs
+// once no live artifact references the same path.
+// Content-addressed blobs are left for DeleteOrphans to free.
+func (s *blobStore) deleteArtifacts(artifacts []expiredArtifact) (RetentionResult, error) {
+	var result RetentionResult
+	for _, a := range artifacts {
+		contentAddressed := strings.HasPrefix(a.Path, ContentAddressedPrefix)
+
+		update := map[string]any{"deleted_at": time.Now()}
+		if a.Inline && !contentAddressed {
+			update["content"] = nil
+		}
+
+		if err := s.db.Table("artifacts").Where("id = ?", a.ID).Updates(update).Error; err != nil {
+			return result, fmt.Errorf("deleting artifact %s: %w", a.ID, err)
+		}
+		result.Deleted++
+		result.FreedBytes += a.Size
+
+		if contentAddressed {
+			continue
+		} else if a.Inline {
+			result.BlobsDeleted++
+			continue
+		}
+
+		var references int64
+		if err := s.db.Table("artifacts").Where("path = ? AND deleted_at IS NULL", a.Path).Count(&references).Error; err != nil {
+			return result, fmt.Errorf("counting references to %s: %w", a.Path, err)
+		} else if references > 0 {
+			continue
+		}
+
+		if err := s.fs.Delete(s.db.Statement.Context, a.Path); err != nil {
+			return result, fmt.Errorf("deleting blob %s of artifact %s: %w", a.Path, a.ID, err)
+		}
+		result.BlobsDeleted++
+	}
+
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package artifact
+
+import (
+	gocontext "context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+)
+
+// URLSigner is implemented by filesystems that can presign download URLs
+// for direct access to an object, e.g. S3, GCS and Azure.
+//
+// Uploads are never presigned: the object may be a content addressed blob shared by other
+// artifacts, and the artifact's size, checksum and content type must be updated with it.
+type URLSigner interface {
+	SignedURL(ctx gocontext.Context, path string, expiry time.Duration) (string, error)
+}
+
+var (
+	// ErrSigningNotSupported is returned by a URLSigner that cannot sign URLs for its backend.
+	ErrSigningNotSupported = errors.New("signed URLs are not supported")
+
+	ErrSignatureInvalid = errors.New("invalid signature")
+	ErrSignatureExpired = errors.New("signature expired")
+)
+
+// HMACSigner signs artifact URLs served by the API for backends
+// that cannot presign URLs themselves, e.g. local and SMB.
+type HMACSigner struct {
+	Key []byte
+
+	// BaseURL is the URL of the handler that serves signed artifacts.
+	// The artifact ID is appended as the last path segment.
+	BaseURL string
+}
+
+func NewHMACSigner(key []byte, baseURL string) *HMACSigner {
+	return &HMACSigner{Key: key, BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (h HMACSigner) signature(artifactID uuid.UUID, method string, expires int64) string {
+	mac := hmac.New(sha256.New, h.Key)
+	fmt.Fprintf(mac, "%s\n%s\n%d", artifactID, method, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns a URL that grants method access to the artifact until expiry elapses.
+func (h HMACSigner) Sign(artifactID uuid.UUID, expiry time.Duration, method string) string {
+	method = strings.ToUpper(method)
+	expires := time.Now().Add(expiry).Unix()
+
+	query := url.Values{}
+	query.Set("method", method)
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", h.signature(artifactID, method, expires))
+	return fmt.Sprintf("%s/%s?%s", h.BaseURL, artifactID, query.Encode())
+}
+
+// Verify checks the signature in the query parameters of a URL returned by Sign.
+func (h HMACSigner) Verify(artifactID uuid.UUID, method string, query url.Values) error {
+	method = strings.ToUpper(method)
+	if !strings.EqualFold(query.Get("method"), method) {
+		return ErrSignatureInvalid
+	}
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+
+	expected := h.signature(artifactID, method, expires)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+		return ErrSignatureInvalid
+	}
+
+	if time.Now().Unix() > expires {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+// WithURLSigner sets the signer used for backends that cannot presign URLs.
+func WithURLSigner(signer *HMACSigner) BlobStoreOption {
+	return func(s *blobStore) {
+		s.signer = signer
+	}
+}
+
+func (s *blobStore) SignedURL(artifactID uuid.UUID, expiry time.Duration, method string) (string, error) {
+	method = strings.ToUpper(method)
+	if method != http.MethodGet && method != http.MethodPut {
+		return "", fmt.Errorf("unsupported signed URL method %q", method)
+	}
+
+	var a models.Artifact
+	if err := s.db.Select("id", "path", "content", "encryption_algorithm").Where("id = ?", artifactID).First(&a).Error; err != nil {
+		return "", fmt.Errorf("finding artifact %s: %w", artifactID, err)
+	}
+
+	// Encrypted artifacts are served through the API so that they can be decrypted,
+	// and uploads go through the API so that they are written with Upload.
+	if signer, ok := s.fs.(URLSigner); ok && method == http.MethodGet && !a.IsInline() && !a.IsEncrypted() {
+		signed, err := signer.SignedURL(s.db.Statement.Context, a.Path, expiry)
+		if !errors.Is(err, ErrSigningNotSupported) {
+			return signed, err
+		}
+	}
+
+	if s.signer == nil {
+		return "", fmt.Errorf("%s blob store does not support signed URLs", s.backend)
+	}
+	return s.signer.Sign(artifactID, expiry, method), nil
+}
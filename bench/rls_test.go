@@ -39,6 +39,16 @@ var benchConfigs = []DistinctBenchConfig{
 	{"change_types", ""},
 	{"config_classes", ""},
 	{"config_types", ""},
+
+	// Inherit the RLS of config_items
+	{"config_analysis", "analysis_type"},
+	{"config_analysis_items", "analysis_type"},
+}
+
+var checkBenchConfigs = []DistinctBenchConfig{
+	{"checks", "type"},
+	{"check_statuses", "check_id"},
+	{"check_status_summary_hour", "check_id"},
 }
 
 func benchSizes() []int {
@@ -137,3 +147,53 @@ func runBenchmark(b *testing.B, config DistinctBenchConfig) {
 		}
 	})
 }
+
+// BenchmarkRLSChecks compares checks scoped through their canaries with checks scoped by the check claim.
+func BenchmarkRLSChecks(b *testing.B) {
+	for _, size := range benchSizes() {
+		resetPG(b, true)
+		canaryNames, err := seedChecks(testCtx, size)
+		if err != nil {
+			b.Fatalf("failed to seed checks for size %d: %v", size, err)
+		}
+
+		payloads := map[string]func(i int) pkgRLS.Payload{
+			"Without RLS": nil,
+			"Canary Scope": func(i int) pkgRLS.Payload {
+				return pkgRLS.Payload{Canary: []pkgRLS.Scope{{Names: []string{canaryNames[i%len(canaryNames)]}}}}
+			},
+			"Check Scope": func(i int) pkgRLS.Payload {
+				return pkgRLS.Payload{Check: []pkgRLS.Scope{{Tags: sampleTags[i%len(sampleTags)]}}}
+			},
+		}
+
+		b.Run(fmt.Sprintf("Sample-%d", size), func(b *testing.B) {
+			for _, config := range checkBenchConfigs {
+				b.Run(config.relation, func(b *testing.B) {
+					for _, name := range []string{"Without RLS", "Canary Scope", "Check Scope"} {
+						payload := payloads[name]
+						b.Run(name, func(b *testing.B) {
+							for i := 0; i < b.N; i++ {
+								b.StopTimer()
+								if payload == nil {
+									if err := testCtx.DB().Exec("RESET ROLE").Error; err != nil {
+										b.Fatalf("failed to reset role: %v", err)
+									}
+								} else if err := payload(i).SetGlobalPostgresSessionRLS(testCtx.DB()); err != nil {
+									b.Fatalf("failed to setup rls payload: %v", err)
+								}
+								b.StartTimer()
+
+								if result, err := fetchView(testCtx, config.relation, config.column, nil); err != nil {
+									b.Fatalf("%v", err)
+								} else if result == 0 {
+									b.Fatalf("[%s] got 0 results which doesn't seem right", name)
+								}
+							}
+						})
+					}
+				})
+			}
+		})
+	}
+}
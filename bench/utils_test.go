@@ -167,6 +167,58 @@ func seedResourceSelectorConfigItems(ctx context.Context, size int) error {
 	return nil
 }
 
+// seedChecks creates size checks, spread across canaries and labelled with the sample tags,
+// each with a few statuses. It returns the canary names.
+func seedChecks(ctx context.Context, size int) ([]string, error) {
+	logf("seeding %d checks ...", size)
+	start := time.Now()
+
+	const checksPerCanary = 100
+	var canaryNames []string
+	for i := 0; i*checksPerCanary < size; i++ {
+		canary := models.Canary{
+			Name:      fmt.Sprintf("bench-canary-%d", i),
+			Namespace: "bench",
+			Spec:      []byte("{}"),
+		}
+		if err := ctx.DB().Create(&canary).Error; err != nil {
+			return nil, err
+		}
+		canaryNames = append(canaryNames, canary.Name)
+
+		checks := make([]models.Check, 0, checksPerCanary)
+		for j := 0; j < checksPerCanary && i*checksPerCanary+j < size; j++ {
+			checks = append(checks, models.Check{
+				CanaryID:  canary.ID,
+				Type:      []string{"http", "tcp", "dns"}[j%3],
+				Name:      fmt.Sprintf("bench-check-%d-%d", i, j),
+				Namespace: "bench",
+				Labels:    sampleTags[j%len(sampleTags)],
+			})
+		}
+		if err := ctx.DB().CreateInBatches(&checks, 500).Error; err != nil {
+			return nil, err
+		}
+
+		var statuses []models.CheckStatus
+		for _, check := range checks {
+			for k := 0; k < 3; k++ {
+				statuses = append(statuses, models.CheckStatus{
+					CheckID: check.ID,
+					Status:  k%2 == 0,
+					Time:    start.Add(-time.Duration(k) * time.Minute).UTC().Format(time.DateTime),
+				})
+			}
+		}
+		if err := ctx.DB().CreateInBatches(&statuses, 1000).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	logf("seeded %d checks in %s", size, time.Since(start).Round(time.Millisecond))
+	return canaryNames, nil
+}
+
 func fetchView(ctx context.Context, view, column string, tags map[string]string) (int, error) {
 	selectColumns := "*"
 	if column != "" {
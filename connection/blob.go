@@ -38,8 +38,14 @@ func getBlobStore(ctx context.Context, connURL string) (artifact.BlobStore, erro
 		return nil, err
 	}
 
+	opts, err := ctx.BlobStoreOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, artifact.WithConnectionID(conn.ID))
+
 	blobsLogger.Infof("Initializing %s blob store", backend)
-	store := artifact.NewBlobStore(fs, ctx.DB(), backend)
+	store := artifact.NewBlobStore(fs, ctx.DB(), backend, opts...)
 	return artifact.NewLoggedBlobStore(store, blobsLogger, backend), nil
 }
 
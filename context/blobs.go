@@ -13,14 +13,60 @@ var blobsLogger = logger.GetLogger("blobs")
 // It is set by the connection package during init().
 var BlobStoreProvider func(ctx Context, connURL string) (artifact.BlobStore, error)
 
+// ArtifactKeeperProvider returns the keeper that wraps the data keys of encrypted artifacts,
+// or nil if artifact encryption is not configured.
+// It is set by the secret package during init().
+var ArtifactKeeperProvider func(ctx Context) (artifact.Keeper, error)
+
+// BlobStoreOptions returns the blob store options configured via properties.
+func (k Context) BlobStoreOptions() ([]artifact.BlobStoreOption, error) {
+	var opts []artifact.BlobStoreOption
+	if k.Properties().On(false, "artifacts.content_addressed") {
+		opts = append(opts, artifact.WithContentAddressing())
+	}
+	if signer := k.ArtifactURLSigner(); signer != nil {
+		opts = append(opts, artifact.WithURLSigner(signer))
+	}
+
+	if ArtifactKeeperProvider == nil {
+		if k.Properties().String("artifacts.encryption.connection", "") != "" || k.Properties().String("artifacts.encryption.local_key", "") != "" {
+			return nil, fmt.Errorf("artifact encryption is configured but no keeper provider is registered")
+		}
+		return opts, nil
+	}
+
+	keeper, err := ArtifactKeeperProvider(k)
+	if err != nil {
+		return nil, err
+	} else if keeper != nil {
+		opts = append(opts, artifact.WithEncryption(keeper))
+	}
+	return opts, nil
+}
+
+// ArtifactURLSigner returns the HMAC signer for artifact URLs served by the API,
+// or nil if artifacts.url.signing_key is not configured.
+func (k Context) ArtifactURLSigner() *artifact.HMACSigner {
+	key := k.Properties().String("artifacts.url.signing_key", "")
+	if key == "" {
+		return nil
+	}
+	return artifact.NewHMACSigner([]byte(key), k.Properties().String("artifacts.url.base", "/artifacts/signed"))
+}
+
 // Blobs returns the appropriate blob store for this context.
 // If an artifacts.connection property is configured and a provider is registered,
 // it returns the external backend. Otherwise it returns the inline DB-backed store.
 func (k Context) Blobs() (artifact.BlobStore, error) {
 	connURL := k.Properties().String("artifacts.connection", "")
 	if connURL == "" {
+		opts, err := k.BlobStoreOptions()
+		if err != nil {
+			return nil, err
+		}
+
 		blobsLogger.Infof("Initializing inline blob store")
-		store := artifact.NewBlobStore(artifact.NewInlineStore(k.DB()), k.DB(), "inline")
+		store := artifact.NewBlobStore(artifact.NewInlineStore(k.DB()), k.DB(), "inline", opts...)
 		return artifact.NewLoggedBlobStore(store, blobsLogger, "inline"), nil
 	}
 	if BlobStoreProvider == nil {
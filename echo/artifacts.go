@@ -0,0 +1,71 @@
+package echo
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"github.com/flanksource/duty/api"
+	"github.com/flanksource/duty/artifact"
+	"github.com/flanksource/duty/context"
+	"github.com/google/uuid"
+	echov4 "github.com/labstack/echo/v4"
+)
+
+// SignedArtifact serves downloads (GET) and uploads (PUT) of an artifact
+// authorized by a URL signed with the context's artifact URL signer.
+// It should be mounted at artifacts.url.base with an ":id" path parameter.
+func SignedArtifact(c echov4.Context) error {
+	ctx := c.Request().Context().(context.Context)
+
+	signer := ctx.ArtifactURLSigner()
+	if signer == nil {
+		return c.JSON(http.StatusNotFound, api.HTTPError{Err: "signed artifact URLs are not enabled"})
+	}
+
+	artifactID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, api.HTTPError{Err: "invalid artifact id"})
+	}
+
+	if err := signer.Verify(artifactID, c.Request().Method, c.QueryParams()); err != nil {
+		status := http.StatusForbidden
+		if errors.Is(err, artifact.ErrSignatureExpired) {
+			status = http.StatusGone
+		}
+		return c.JSON(status, api.HTTPError{Err: err.Error()})
+	}
+
+	store, err := ctx.Blobs()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ctx.Oops().Wrap(err))
+	}
+	defer func() { _ = store.Close() }()
+
+	switch c.Request().Method {
+	case http.MethodGet:
+		data, err := store.Read(artifactID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ctx.Oops().Wrap(err))
+		}
+		defer func() { _ = data.Content.Close() }()
+
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(data.Filename)})
+		c.Response().Header().Set(echov4.HeaderContentDisposition, disposition)
+		return c.Stream(http.StatusOK, data.ContentType, data.Content)
+
+	case http.MethodPut:
+		a, err := store.Upload(artifactID, artifact.Data{
+			Content:     c.Request().Body,
+			ContentType: c.Request().Header.Get(echov4.HeaderContentType),
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ctx.Oops().Wrap(err))
+		}
+		return c.JSON(http.StatusOK, a)
+
+	default:
+		return c.JSON(http.StatusMethodNotAllowed, api.HTTPError{Err: "method not allowed"})
+	}
+}
@@ -0,0 +1,69 @@
+// Package jobs serves the job registry over HTTP.
+// It is separate from the echo package, which the job package imports to register its crons.
+package jobs
+
+import (
+	"net/http"
+
+	"github.com/flanksource/duty/api"
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/job"
+	echov4 "github.com/labstack/echo/v4"
+)
+
+// AddHandlers mounts the job registry API under /jobs.
+// Job IDs can contain slashes, so they are passed in the "id" query or form parameter.
+//
+//	GET  /jobs         list the scheduled jobs
+//	GET  /jobs/status  get a job
+//	POST /jobs/pause   pause a job
+//	POST /jobs/resume  resume a job
+//	POST /jobs/run     trigger a job
+func AddHandlers(e *echov4.Echo, rbac echov4.MiddlewareFunc) {
+	jobs := e.Group("/jobs", rbac)
+
+	jobs.GET("", func(c echov4.Context) error {
+		return c.JSON(http.StatusOK, job.ListJobs())
+	})
+
+	jobs.GET("/status", func(c echov4.Context) error {
+		status, err := job.GetJob(c.QueryParam("id"))
+		if err != nil {
+			return c.JSON(http.StatusNotFound, api.HTTPError{Err: err.Error()})
+		}
+		return c.JSON(http.StatusOK, status)
+	})
+
+	jobs.POST("/pause", func(c echov4.Context) error {
+		return updateJob(c, job.PauseJob)
+	})
+
+	jobs.POST("/resume", func(c echov4.Context) error {
+		return updateJob(c, job.ResumeJob)
+	})
+
+	jobs.POST("/run", func(c echov4.Context) error {
+		if err := job.TriggerJob(c.FormValue("id")); err != nil {
+			return c.JSON(http.StatusNotFound, api.HTTPError{Err: err.Error()})
+		}
+		return c.NoContent(http.StatusAccepted)
+	})
+}
+
+func updateJob(c echov4.Context, fn func(ctx context.Context, id string) error) error {
+	ctx := c.Request().Context().(context.Context)
+
+	id := c.FormValue("id")
+	if _, err := job.GetJob(id); err != nil {
+		return c.JSON(http.StatusNotFound, api.HTTPError{Err: err.Error()})
+	}
+	if err := fn(ctx, id); err != nil {
+		return c.JSON(http.StatusInternalServerError, ctx.Oops().Wrap(err))
+	}
+
+	status, err := job.GetJob(id)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, api.HTTPError{Err: err.Error()})
+	}
+	return c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,127 @@
+-- Config masks redact fields of the config of config items, see rls.Payload.MaskConfig
+-- and config_items_masked in views/006_config_views.sql
+
+-- Matches a pattern like matchPattern() in github.com/flanksource/commons/collections:
+-- case-insensitively, with * as a prefix and/or suffix wildcard.
+CREATE OR REPLACE FUNCTION config_mask_match_pattern(item text, pattern text)
+RETURNS boolean AS $$
+  SELECT pattern = '*'
+    OR lower(item) = lower(pattern)
+    OR (starts_with(pattern, '*') AND right(pattern, 1) = '*'
+      AND strpos(lower(item), lower(substr(pattern, 2, greatest(length(pattern) - 2, 0)))) > 0)
+    OR (starts_with(pattern, '*') AND right(lower(item), length(pattern) - 1) = lower(substr(pattern, 2)))
+    OR (right(pattern, 1) = '*' AND starts_with(lower(item), lower(left(pattern, -1))))
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Whether a config mask applies to a config type, see ConfigMask.Matches() in rls/mask.go.
+-- Types are comma separated patterns with ! exclusions, matched like collections.MatchItems.
+-- Both are tested against rls/testdata/config_masks.yaml
+CREATE OR REPLACE FUNCTION config_mask_matches_type(config_type text, types jsonb)
+RETURNS boolean AS $$
+  WITH patterns AS (
+    SELECT btrim(u.part) AS p
+    FROM jsonb_array_elements_text(CASE WHEN jsonb_typeof(types) = 'array' THEN types ELSE '[]'::jsonb END) AS t(value)
+      CROSS JOIN LATERAL (SELECT string_to_array(t.value, ',') AS parts) AS s
+      CROSS JOIN LATERAL unnest(s.parts) AS u(part)
+    -- empty patterns are only dropped from a comma separated list
+    WHERE btrim(u.part) <> '' OR cardinality(s.parts) = 1
+  ),
+  matches AS (
+    SELECT p, starts_with(p, '!') AS excluded,
+      config_mask_match_pattern(config_type, CASE WHEN starts_with(p, '!') THEN substr(p, 2) ELSE p END) AS matched
+    FROM patterns
+  )
+  SELECT CASE
+    WHEN jsonb_typeof(types) IS DISTINCT FROM 'array' THEN TRUE
+    WHEN jsonb_array_length(types) = 0 THEN TRUE
+    WHEN NOT EXISTS (SELECT 1 FROM matches) THEN FALSE
+    WHEN EXISTS (SELECT 1 FROM matches WHERE excluded AND matched) THEN FALSE
+    WHEN EXISTS (SELECT 1 FROM matches WHERE NOT excluded AND matched) THEN TRUE
+    -- a list of exclusions only matches everything it doesn't exclude
+    ELSE NOT EXISTS (SELECT 1 FROM matches WHERE NOT excluded)
+  END
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Splits a JSONPath like $.spec.containers[*].env[*].value into its keys, see maskPathKeys() in rls/mask.go.
+-- Both are tested against rls/testdata/config_masks.yaml
+CREATE OR REPLACE FUNCTION config_mask_path_keys(path text)
+RETURNS text[] AS $$
+  SELECT CASE WHEN normalized = '' THEN '{}'::text[] ELSE string_to_array(normalized, '.') END
+  FROM (
+    SELECT btrim(
+      regexp_replace(regexp_replace(regexp_replace(path, '^\$', ''), '\[\*\]', '.*', 'g'), '\[(\d+)\]', '.\1', 'g'),
+      '.'
+    ) AS normalized
+  ) AS p
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Replaces the values at the keys of a document with '***'.
+-- A * key matches every field of an object and every element of an array.
+CREATE OR REPLACE FUNCTION mask_jsonb(doc jsonb, keys text[], prefix text, OUT masked jsonb, OUT paths text[])
+AS $$
+DECLARE
+  segment text := keys[1];
+  rest text[] := keys[2:];
+  child record;
+  nested record;
+BEGIN
+  masked := doc;
+  paths := '{}';
+
+  IF doc IS NULL OR segment IS NULL OR jsonb_typeof(doc) NOT IN ('object', 'array') THEN
+    RETURN;
+  END IF;
+
+  FOR child IN
+    SELECT e.key AS k, e.value AS v FROM jsonb_each(CASE WHEN jsonb_typeof(doc) = 'object' THEN doc ELSE '{}'::jsonb END) e
+    UNION ALL
+    SELECT (e.ordinality - 1)::text AS k, e.value AS v FROM jsonb_array_elements(CASE WHEN jsonb_typeof(doc) = 'array' THEN doc ELSE '[]'::jsonb END) WITH ORDINALITY e
+  LOOP
+    IF segment <> '*' AND segment <> child.k THEN
+      CONTINUE;
+    END IF;
+
+    IF cardinality(rest) = 0 THEN
+      masked := jsonb_set(masked, ARRAY[child.k], '"***"'::jsonb);
+      paths := paths || (prefix || child.k);
+    ELSE
+      SELECT * INTO nested FROM mask_jsonb(child.v, rest, prefix || child.k || '.');
+      masked := jsonb_set(masked, ARRAY[child.k], nested.masked);
+      paths := paths || nested.paths;
+    END IF;
+  END LOOP;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- Redacts a config with the config_masks of the RLS payload, see rls.Payload.MaskConfig
+CREATE OR REPLACE FUNCTION mask_config(config_type text, config jsonb, OUT masked jsonb, OUT paths text[])
+AS $$
+DECLARE
+  mask jsonb;
+  mask_path text;
+  result record;
+BEGIN
+  masked := config;
+  paths := '{}';
+
+  IF config IS NULL OR (SELECT is_rls_disabled()) THEN
+    RETURN;
+  END IF;
+
+  FOR mask IN SELECT * FROM jsonb_array_elements(COALESCE(current_setting('request.jwt.claims', TRUE)::jsonb -> 'config_masks', '[]'::jsonb))
+  LOOP
+    IF NOT config_mask_matches_type(config_type, mask -> 'types') THEN
+      CONTINUE;
+    END IF;
+
+    FOR mask_path IN SELECT * FROM jsonb_array_elements_text(COALESCE(mask -> 'paths', '[]'::jsonb))
+    LOOP
+      SELECT * INTO result FROM mask_jsonb(masked, config_mask_path_keys(mask_path), '');
+      masked := result.masked;
+      paths := paths || result.paths;
+    END LOOP;
+  END LOOP;
+
+  paths := ARRAY(SELECT DISTINCT p FROM unnest(paths) AS p ORDER BY p COLLATE "C");
+END;
+$$ LANGUAGE plpgsql STABLE;
@@ -20,6 +20,10 @@ DROP VIEW IF EXISTS config_detail CASCADE;
 
 DROP VIEW IF EXISTS config_tags CASCADE;
 
+DROP VIEW IF EXISTS config_items_masked CASCADE;
+
+DROP FUNCTION IF EXISTS config_item_visible;
+
 DROP VIEW IF EXISTS config_detail CASCADE;
 
 DROP VIEW IF EXISTS notification_send_history_resource_tags;
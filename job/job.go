@@ -4,7 +4,9 @@ import (
 	"container/ring"
 	gocontext "context"
 	"fmt"
+	"maps"
 	"math/rand"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -110,6 +112,7 @@ var RetentionHigh = Retention{
 type Job struct {
 	context.Context
 	entryID     *cron.EntryID
+	cron        *cron.Cron
 	initialized bool
 	unschedule  func()
 	statusRing  StatusRing
@@ -130,6 +133,18 @@ type Job struct {
 	Retention                Retention
 	LastJob                  *models.JobHistory
 
+	// lastRun is a copy of the history of the last run, see LastRun
+	lastRun     *models.JobHistory
+	lastRunLock sync.RWMutex
+
+	// Distributed extends Singleton across replicas with a lease in the job_locks table,
+	// so that only one replica runs the job at a time.
+	Distributed bool
+
+	// DependsOn lists the jobs that must succeed before this job runs in a pipeline.
+	// It has no effect when the job is scheduled on its own.
+	DependsOn []*Job
+
 	// Semaphores control concurrent execution of related jobs.
 	// They are acquired sequentially and released in reverse order.
 	// Hence, they should be ordered from most specific to most general
@@ -246,6 +261,7 @@ type JobRuntime struct {
 	History   *models.JobHistory
 	Table, Id string
 	runId     string
+	parentID  *uuid.UUID
 }
 
 func New(ctx context.Context) JobRuntime {
@@ -264,6 +280,7 @@ func (j *JobRuntime) start() {
 	j.Tracef("starting")
 	j.Context.Counter("job_started", "name", j.Job.Name, "id", j.Job.ResourceID, "resource", j.Job.ResourceType).Add(1)
 	j.History = models.NewJobHistory(j.Logger, j.Job.Name, "", "").Start()
+	j.History.ParentID = j.parentID
 	j.Job.LastJob = j.History
 	if j.Job.ResourceID != "" {
 		j.History.ResourceID = j.Job.ResourceID
@@ -271,6 +288,7 @@ func (j *JobRuntime) start() {
 	if j.Job.ResourceType != "" {
 		j.History.ResourceType = j.Job.ResourceType
 	}
+	j.Job.setLastRun(j.History)
 	if j.Job.JobHistory && j.Job.Retention.Success > 0 && !j.Job.IgnoreSuccessHistory {
 		if err := j.History.Persist(j.VerboseDB()); err != nil {
 			j.Warnf("failed to persist history: %v", err)
@@ -290,6 +308,7 @@ func (j *JobRuntime) end() {
 		}
 	}
 	j.Job.statusRing.Add(j.History)
+	j.Job.setLastRun(j.History)
 
 	j.Context.Counter("job", "name", j.Job.Name, "id", j.Job.ResourceID, "resource", j.Job.ResourceType, "status", j.History.Status).
 		Add(1)
@@ -297,6 +316,25 @@ func (j *JobRuntime) end() {
 		Since(j.History.TimeStart)
 }
 
+// setLastRun saves a copy of the history, as the history itself is updated by the run without a lock.
+func (j *Job) setLastRun(history *models.JobHistory) {
+	last := *history
+	last.Details = maps.Clone(history.Details)
+	last.Errors = slices.Clone(history.Errors)
+
+	j.lastRunLock.Lock()
+	defer j.lastRunLock.Unlock()
+	j.lastRun = &last
+}
+
+// LastRun returns the history of the last run as of its start or its end,
+// and can be called while the job runs, unlike LastJob.
+func (j *Job) LastRun() *models.JobHistory {
+	j.lastRunLock.RLock()
+	defer j.lastRunLock.RUnlock()
+	return j.lastRun
+}
+
 func (j *JobRuntime) Failf(message string, args ...interface{}) {
 	err := fmt.Sprintf(message, args...)
 	j.Logger.WithSkipReportLevel(1).Debugf(err)
@@ -363,7 +401,22 @@ func (j *Job) SetAliases(aliases ...string) *Job {
 }
 
 func (j *Job) Run() {
-	if !j.Context.Properties().On(false, "job.jitter.disable") && !j.JitterDisable && j.Schedule != "" {
+	j.run(runOptions{})
+}
+
+type runOptions struct {
+	// triggered runs are not skipped when the job is paused
+	triggered bool
+
+	// parent is the history of the pipeline run that the job runs in
+	parent *models.JobHistory
+}
+
+// run runs the job and returns its history,
+// or nil if the job did not start because it is disabled or paused.
+func (j *Job) run(opts runOptions) (history *models.JobHistory) {
+	// Triggered runs and the jobs of a pipeline run right away
+	if !j.Context.Properties().On(false, "job.jitter.disable") && !j.JitterDisable && j.Schedule != "" && opts.parent == nil && !opts.triggered {
 		// Attempt to get a fixed interval from the schedule to measure the appropriate jitter.
 		// NOTE: Only works for fixed interval schedules.
 		parsedSchedule, err := cron.ParseStandard(j.Schedule)
@@ -392,6 +445,9 @@ func (j *Job) Run() {
 		Span:    span,
 		Job:     j,
 	}
+	if opts.parent != nil {
+		r.parentID = &opts.parent.ID
+	}
 	if span.SpanContext().HasSpanID() {
 		r.runId = span.SpanContext().SpanID().String()[0:8]
 	} else {
@@ -408,9 +464,15 @@ func (j *Job) Run() {
 		return
 	}
 
+	if !opts.triggered && j.Paused() {
+		ctx.Tracef("job paused")
+		return
+	}
+
 	r.start()
 	defer r.end()
-	if j.Singleton {
+	history = r.History
+	if j.Singleton || j.Distributed {
 		key := j.ID()
 		ctx.Logger.V(4).Infof("acquiring lock %s", key)
 
@@ -424,6 +486,33 @@ func (j *Job) Run() {
 		defer unlock()
 	}
 
+	if j.Distributed {
+		lease, err := AcquireLease(ctx, j.ID(), LockHolder, ctx.Properties().Duration("job.lock.ttl", defaultLockTTL))
+		if err != nil {
+			r.Failf("failed to acquire lease: %v", err)
+			return
+		} else if lease == nil {
+			r.Skipped("job running on another replica, skipping")
+			return
+		}
+		if lease.PreviousHolder != nil && *lease.PreviousHolder != lease.Holder {
+			ctx.Warnf("took over stale lock %s from %s", lease.JobID, *lease.PreviousHolder)
+		}
+
+		parent := r.Context
+		var cancel gocontext.CancelFunc
+		r.Context, cancel = parent.WithCancel()
+		defer func() {
+			cancel()
+			// the history is persisted with the parent context once the job ends
+			r.Context = parent
+		}()
+		defer lease.keepAlive(ctx, func(err error) {
+			ctx.Errorf("lost lease %s, cancelling job: %v", lease.JobID, err)
+			cancel()
+		})()
+	}
+
 	for i, lock := range j.Semaphores {
 		ctx.Logger.V(6).Infof("[%s] acquiring sempahore [%d/%d]", j.ID(), i+1, len(j.Semaphores))
 		if err := lock.Acquire(ctx, 1); err != nil {
@@ -450,6 +539,7 @@ func (j *Job) Run() {
 	} else {
 		ctx.Tracef("finished duration=%s", text.HumanizeDuration(time.Since(r.History.TimeStart)))
 	}
+	return
 }
 
 func (j *Job) getPropertyNames(key string) []string {
@@ -542,6 +632,7 @@ func (j *Job) init() error {
 	j.Trace = j.Properties().On(false, j.getPropertyNames("trace")...)
 	j.Debug = j.Properties().On(false, j.getPropertyNames("debug")...)
 	j.Singleton = j.Properties().On(j.Singleton, j.getPropertyNames("singleton")...)
+	j.Distributed = j.Properties().On(j.Distributed, j.getPropertyNames("distributed")...)
 
 	// Set default retention if it is unset
 	if j.Retention.Empty() {
@@ -651,6 +742,8 @@ func (j *Job) AddToScheduler(cronRunner *cron.Cron) error {
 		return fmt.Errorf("[%s] failed to schedule job: %s", j.Label(), err)
 	}
 	j.entryID = &entryID
+	j.cron = cronRunner
+	registry.add(j)
 
 	if j.RunNow {
 		// Run in a goroutine since AddToScheduler should be non-blocking
@@ -659,6 +752,7 @@ func (j *Job) AddToScheduler(cronRunner *cron.Cron) error {
 
 	j.unschedule = func() {
 		cronRunner.Remove(*j.entryID)
+		registry.remove(j)
 	}
 
 	return nil
@@ -694,6 +788,7 @@ func (j *Job) RemoveFromScheduler(cronRunner *cron.Cron) {
 		return
 	}
 	cronRunner.Remove(*j.entryID)
+	registry.remove(j)
 }
 
 func init() {
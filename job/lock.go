@@ -0,0 +1,125 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+)
+
+// LockHolder identifies this process in the job_locks table.
+// It defaults to the hostname, pid and a random suffix, and can be overridden
+// before any job runs, e.g. with the name of the pod.
+var LockHolder = defaultLockHolder()
+
+func defaultLockHolder() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s/%d/%s", hostname, os.Getpid(), uuid.NewString()[0:8])
+}
+
+const defaultLockTTL = time.Minute
+
+// ErrLeaseLost is returned when a lease has expired and been taken over by another holder.
+var ErrLeaseLost = errors.New("job lease lost")
+
+// Lease is a lock on a job in the job_locks table.
+// It expires unless renewed, so a replica that dies while holding it blocks the
+// job on the other replicas for no longer than the TTL.
+type Lease struct {
+	models.JobLock
+	TTL time.Duration
+}
+
+const acquireLeaseQuery = `
+INSERT INTO job_locks (job_id, holder, acquired_at, renewed_at, expires_at)
+VALUES (?, ?, NOW(), NOW(), NOW() + (? * INTERVAL '1 millisecond'))
+ON CONFLICT (job_id) DO UPDATE SET
+	holder = EXCLUDED.holder,
+	previous_holder = CASE WHEN job_locks.holder = EXCLUDED.holder THEN job_locks.previous_holder ELSE job_locks.holder END,
+	acquired_at = EXCLUDED.acquired_at,
+	renewed_at = EXCLUDED.renewed_at,
+	expires_at = EXCLUDED.expires_at
+WHERE job_locks.expires_at < NOW() OR job_locks.holder = EXCLUDED.holder
+RETURNING *`
+
+// AcquireLease takes the lock of a job for holder.
+// It returns nil if another holder has a lease that has not expired yet.
+// An expired lease is taken over, and its holder recorded as the previous holder.
+func AcquireLease(ctx context.Context, jobID, holder string, ttl time.Duration) (*Lease, error) {
+	var locks []models.JobLock
+	if err := ctx.DB().Raw(acquireLeaseQuery, jobID, holder, ttl.Milliseconds()).Scan(&locks).Error; err != nil {
+		return nil, fmt.Errorf("acquiring lease for %s: %w", jobID, err)
+	}
+	if len(locks) == 0 {
+		return nil, nil
+	}
+	return &Lease{JobLock: locks[0], TTL: ttl}, nil
+}
+
+// Renew extends the lease by its TTL.
+// It returns ErrLeaseLost if the lease is no longer held.
+func (l *Lease) Renew(ctx context.Context) error {
+	var locks []models.JobLock
+	err := ctx.DB().Raw(`UPDATE job_locks SET renewed_at = NOW(), expires_at = NOW() + (? * INTERVAL '1 millisecond')
+		WHERE job_id = ? AND holder = ? RETURNING *`, l.TTL.Milliseconds(), l.JobID, l.Holder).Scan(&locks).Error
+	if err != nil {
+		return fmt.Errorf("renewing lease for %s: %w", l.JobID, err)
+	}
+	if len(locks) == 0 {
+		return ErrLeaseLost
+	}
+	l.JobLock = locks[0]
+	return nil
+}
+
+// Release gives up the lease, unless it has already been taken over.
+func (l *Lease) Release(ctx context.Context) error {
+	if err := ctx.DB().Where("job_id = ? AND holder = ?", l.JobID, l.Holder).Delete(&models.JobLock{}).Error; err != nil {
+		return fmt.Errorf("releasing lease for %s: %w", l.JobID, err)
+	}
+	return nil
+}
+
+// keepAlive renews the lease in the background until the returned function is called,
+// which stops the renewals and releases the lease.
+// lost is called once if the lease expires or is taken over in the meantime.
+func (l *Lease) keepAlive(ctx context.Context, lost func(error)) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(max(l.TTL/3, time.Second))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			err := l.Renew(ctx)
+			if err == nil {
+				continue
+			}
+			if errors.Is(err, ErrLeaseLost) || time.Now().After(l.ExpiresAt) {
+				lost(err)
+				return
+			}
+			ctx.Warnf("%v", err)
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		if err := l.Release(ctx); err != nil {
+			ctx.Warnf("%v", err)
+		}
+	}
+}
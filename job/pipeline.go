@@ -0,0 +1,172 @@
+package job
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+)
+
+// NewPipeline returns a job that runs a DAG of jobs, each after the jobs it DependsOn.
+//
+// Jobs whose dependencies are met run concurrently. A job runs once all of its
+// dependencies have finished with a success or warning; otherwise it is skipped, and so
+// are the jobs that depend on it. Every job records its own history with the pipeline
+// run as its parent, and the pipeline run fails if any of its jobs fails.
+func NewPipeline(ctx context.Context, name, schedule string, jobs ...*Job) (*Job, error) {
+	if err := validatePipeline(jobs); err != nil {
+		return nil, fmt.Errorf("invalid pipeline %s: %w", name, err)
+	}
+
+	for _, j := range jobs {
+		if j.Context.Context.Context == nil {
+			j.Context = ctx
+		}
+	}
+
+	return &Job{
+		Name:       name,
+		Schedule:   schedule,
+		Context:    ctx,
+		Singleton:  true,
+		JobHistory: true,
+		Retention:  RetentionBalanced,
+		Fn: func(ctx JobRuntime) error {
+			return runPipeline(ctx, jobs)
+		},
+	}, nil
+}
+
+// validatePipeline checks that every dependency is part of the pipeline exactly once and that there are no cycles.
+func validatePipeline(jobs []*Job) error {
+	members := make(map[*Job]bool, len(jobs))
+	for _, j := range jobs {
+		if members[j] {
+			return fmt.Errorf("%s is added more than once", j.ID())
+		}
+		members[j] = true
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[*Job]int, len(jobs))
+	var visit func(j *Job, path []string) error
+	visit = func(j *Job, path []string) error {
+		path = append(path, j.ID())
+		switch state[j] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+
+		state[j] = visiting
+		for _, upstream := range j.DependsOn {
+			if !members[upstream] {
+				return fmt.Errorf("%s depends on %s, which is not part of the pipeline", j.ID(), upstream.ID())
+			}
+			if err := visit(upstream, path); err != nil {
+				return err
+			}
+		}
+		state[j] = visited
+		return nil
+	}
+
+	for _, j := range jobs {
+		if err := visit(j, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runPipeline(ctx JobRuntime, jobs []*Job) error {
+	if ctx.History.ID == uuid.Nil {
+		ctx.History.ID = uuid.New()
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		statuses = make(map[*Job]string, len(jobs))
+		done     = make(map[*Job]chan struct{}, len(jobs))
+	)
+	for _, j := range jobs {
+		done[j] = make(chan struct{})
+	}
+
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *Job) {
+			defer wg.Done()
+			defer close(done[j])
+
+			var blocked []string
+			for _, upstream := range j.DependsOn {
+				<-done[upstream]
+				mu.Lock()
+				status := statuses[upstream]
+				mu.Unlock()
+				if status != models.StatusSuccess && status != models.StatusWarning {
+					blocked = append(blocked, fmt.Sprintf("%s (%s)", upstream.ID(), strings.ToLower(status)))
+				}
+			}
+
+			var status string
+			if len(blocked) > 0 {
+				status = skipPipelineJob(ctx, j, blocked)
+			} else if history := j.run(runOptions{parent: ctx.History}); history != nil {
+				status = history.Status
+			} else {
+				status = models.StatusSkipped
+			}
+
+			mu.Lock()
+			statuses[j] = status
+			mu.Unlock()
+		}(j)
+	}
+	wg.Wait()
+
+	summary := make(map[string]string, len(jobs))
+	var failed []string
+	for _, j := range jobs {
+		summary[j.ID()] = statuses[j]
+		switch statuses[j] {
+		case models.StatusFailed:
+			failed = append(failed, j.ID())
+		case models.StatusSuccess, models.StatusWarning:
+			ctx.History.IncrSuccess()
+		}
+	}
+	ctx.History.AddDetails("jobs", summary)
+
+	if len(failed) > 0 {
+		// The run fails instead of ending as a warning for the jobs that succeeded
+		ctx.History.Status = models.StatusFailed
+		return fmt.Errorf("pipeline jobs failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// skipPipelineJob records a skipped run of a job whose dependencies did not succeed.
+func skipPipelineJob(ctx JobRuntime, j *Job, blocked []string) string {
+	history := models.NewJobHistory(ctx.Logger, j.Name, j.ResourceType, j.ResourceID).Start()
+	history.ParentID = &ctx.History.ID
+	history.Status = models.StatusSkipped
+	history.AddDetails("skipped", fmt.Sprintf("upstream jobs did not succeed: %s", strings.Join(blocked, ", ")))
+	history.End()
+
+	if err := history.Persist(ctx.DB()); err != nil {
+		ctx.Warnf("failed to persist history of %s: %v", j.ID(), err)
+	} else if j.initialized {
+		j.statusRing.Add(history)
+	}
+	return history.Status
+}
@@ -0,0 +1,139 @@
+package job
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/models"
+)
+
+// registry tracks the jobs added to a scheduler by their ID.
+var registry = &jobRegistry{jobs: make(map[string]*Job)}
+
+type jobRegistry struct {
+	mu   sync.RWMutex
+	jobs map[string]*Job
+}
+
+func (r *jobRegistry) add(j *Job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[j.ID()] = j
+}
+
+func (r *jobRegistry) remove(j *Job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.jobs[j.ID()] == j {
+		delete(r.jobs, j.ID())
+	}
+}
+
+func (r *jobRegistry) get(id string) (*Job, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	j, ok := r.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("job %s is not scheduled", id)
+	}
+	return j, nil
+}
+
+// Status describes a scheduled job.
+type Status struct {
+	ID           string             `json:"id"`
+	Name         string             `json:"name"`
+	Aliases      []string           `json:"aliases,omitempty"`
+	ResourceType string             `json:"resource_type,omitempty"`
+	ResourceID   string             `json:"resource_id,omitempty"`
+	Schedule     string             `json:"schedule"`
+	Paused       bool               `json:"paused"`
+	NextRun      *time.Time         `json:"next_run,omitempty"`
+	LastRun      *models.JobHistory `json:"last_run,omitempty"`
+}
+
+func (j *Job) status() Status {
+	status := Status{
+		ID:           j.ID(),
+		Name:         j.Name,
+		Aliases:      j.Aliases,
+		ResourceType: j.ResourceType,
+		ResourceID:   j.ResourceID,
+		Schedule:     j.Schedule,
+		Paused:       j.Paused(),
+		LastRun:      j.LastRun(),
+	}
+	if j.cron != nil {
+		if entry := j.GetEntry(j.cron); entry != nil && !entry.Next.IsZero() {
+			status.NextRun = &entry.Next
+		}
+	}
+	return status
+}
+
+// ListJobs returns the status of every scheduled job, ordered by ID.
+func ListJobs() []Status {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(registry.jobs))
+	for _, j := range registry.jobs {
+		statuses = append(statuses, j.status())
+	}
+	sort.Slice(statuses, func(i, k int) bool { return statuses[i].ID < statuses[k].ID })
+	return statuses
+}
+
+// GetJob returns the status of a scheduled job.
+func GetJob(id string) (*Status, error) {
+	j, err := registry.get(id)
+	if err != nil {
+		return nil, err
+	}
+	status := j.status()
+	return &status, nil
+}
+
+// pausedProperty is the property that pauses a single job.
+func (j *Job) pausedProperty() string {
+	return fmt.Sprintf("jobs.%s.paused", j.ID())
+}
+
+// Paused returns whether scheduled runs of the job are skipped.
+// Jobs can be paused by ID, or by name and alias like any other job property.
+func (j *Job) Paused() bool {
+	return j.Properties().On(false, append([]string{j.pausedProperty()}, j.getPropertyNames("paused")...)...)
+}
+
+// PauseJob skips the scheduled runs of a job until it is resumed.
+// The paused state is stored in the properties table so that it survives restarts.
+func PauseJob(ctx context.Context, id string) error {
+	j, err := registry.get(id)
+	if err != nil {
+		return err
+	}
+	return context.UpdateProperty(ctx, j.pausedProperty(), "true")
+}
+
+// ResumeJob resumes the scheduled runs of a paused job.
+func ResumeJob(ctx context.Context, id string) error {
+	j, err := registry.get(id)
+	if err != nil {
+		return err
+	}
+	return context.UpdateProperty(ctx, j.pausedProperty(), "false")
+}
+
+// TriggerJob runs a job in the background, whether or not it is paused.
+// Singleton jobs that are already running are skipped as usual.
+func TriggerJob(id string) error {
+	j, err := registry.get(id)
+	if err != nil {
+		return err
+	}
+	go j.run(runOptions{triggered: true})
+	return nil
+}
@@ -0,0 +1,21 @@
+package azureloganalytics
+
+import (
+	"github.com/flanksource/duty/connection"
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/logs"
+	"github.com/flanksource/duty/models"
+)
+
+func init() {
+	logs.RegisterSearcher(models.ConnectionTypeAzure, func(ctx context.Context, conn string, mappingConfig *logs.FieldMappingConfig) (logs.Searcher, error) {
+		searcher := New(connection.AzureConnection{ConnectionName: conn}, mappingConfig)
+		return logs.SearcherFunc(func(ctx context.Context, request logs.SearchRequest) (*logs.LogResult, error) {
+			return searcher.Search(ctx, Request{
+				LogsRequestBase: request.LogsRequestBase,
+				WorkspaceID:     request.Params["workspaceID"],
+				Query:           request.Query,
+			})
+		}), nil
+	})
+}
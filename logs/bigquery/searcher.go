@@ -0,0 +1,23 @@
+package bigquery
+
+import (
+	"github.com/flanksource/duty/connection"
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/logs"
+)
+
+// SearcherType is the type bigquery is registered as with logs.RegisterSearcher.
+// It searches with a google_cloud connection, whose type is taken by cloud logging.
+const SearcherType = "bigquery"
+
+func init() {
+	logs.RegisterSearcher(SearcherType, func(_ context.Context, conn string, mappingConfig *logs.FieldMappingConfig) (logs.Searcher, error) {
+		// The query has to filter by the time window itself
+		return logs.SearcherFunc(func(ctx context.Context, request logs.SearchRequest) (*logs.LogResult, error) {
+			searcher := New(connection.GCPConnection{ConnectionName: conn, Project: request.Params["project"]}, mappingConfig)
+			defer searcher.Close()
+
+			return searcher.Search(ctx, Request{Query: request.Query})
+		}), nil
+	})
+}
@@ -0,0 +1,35 @@
+package cloudwatch
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+
+	"github.com/flanksource/duty/connection"
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/logs"
+	"github.com/flanksource/duty/models"
+)
+
+func init() {
+	logs.RegisterSearcher(models.ConnectionTypeAWS, func(ctx context.Context, conn string, mappingConfig *logs.FieldMappingConfig) (logs.Searcher, error) {
+		awsConn := connection.AWSConnection{ConnectionName: conn}
+		if err := awsConn.Populate(ctx); err != nil {
+			return nil, fmt.Errorf("failed to populate aws connection: %w", err)
+		}
+
+		cfg, err := awsConn.Client(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create aws client: %w", err)
+		}
+
+		searcher := New(cloudwatchlogs.NewFromConfig(cfg), mappingConfig)
+		return logs.SearcherFunc(func(ctx context.Context, request logs.SearchRequest) (*logs.LogResult, error) {
+			return searcher.Search(ctx, Request{
+				LogsRequestBase: request.LogsRequestBase,
+				LogGroup:        request.Params["logGroup"],
+				Query:           request.Query,
+			})
+		}), nil
+	})
+}
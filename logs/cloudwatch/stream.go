@@ -0,0 +1,88 @@
+package cloudwatch
+
+import (
+	gocontext "context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/samber/lo"
+	"github.com/timberio/go-datemath"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/logs"
+)
+
+type filterLogEventsAPI interface {
+	FilterLogEvents(ctx gocontext.Context, params *cloudwatchlogs.FilterLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error)
+}
+
+// Stream follows the log group, polling for events since the last one sent.
+// Events ingested with a timestamp older than the last event sent are not streamed.
+func (t *Searcher) Stream(ctx context.Context, request StreamRequest) (<-chan logs.StreamItem, error) {
+	return stream(ctx, t.client, request)
+}
+
+func stream(ctx context.Context, client filterLogEventsAPI, request StreamRequest) (<-chan logs.StreamItem, error) {
+	if request.LogGroup == "" {
+		return nil, fmt.Errorf("log group is required")
+	}
+
+	start := time.Now()
+	if request.Start != "" {
+		var err error
+		if start, err = datemath.ParseAndEvaluate(request.Start, datemath.WithNow(time.Now())); err != nil {
+			return nil, fmt.Errorf("invalid start %q: %w", request.Start, err)
+		}
+	}
+
+	writer := logs.NewStreamWriter(ctx, request.StreamOptions)
+	writer.Go(start, func(cursor *logs.StreamCursor) error {
+		for {
+			// Events at the millisecond of the last event are fetched again and skipped by the cursor
+			cursor.Resume()
+			input := &cloudwatchlogs.FilterLogEventsInput{
+				LogGroupName: &request.LogGroup,
+				StartTime:    lo.ToPtr(cursor.Since().UnixMilli()),
+			}
+			if request.FilterPattern != "" {
+				input.FilterPattern = &request.FilterPattern
+			}
+
+			for {
+				output, err := client.FilterLogEvents(ctx, input)
+				if err != nil {
+					return fmt.Errorf("failed to filter log events of %s: %w", request.LogGroup, err)
+				}
+
+				for _, event := range output.Events {
+					line := &logs.LogLine{
+						ID:            lo.FromPtr(event.EventId),
+						Count:         1,
+						FirstObserved: time.UnixMilli(lo.FromPtr(event.Timestamp)),
+						Message:       lo.FromPtr(event.Message),
+						Source:        lo.FromPtr(event.LogStreamName),
+					}
+					line.SetHash()
+					if !cursor.Send(line) {
+						return nil
+					}
+				}
+
+				if output.NextToken == nil {
+					break
+				}
+				input.NextToken = output.NextToken
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(request.GetPollInterval()):
+			}
+		}
+	})
+	go writer.Close()
+
+	return writer.Items(), nil
+}
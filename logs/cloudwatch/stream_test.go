@@ -0,0 +1,96 @@
+package cloudwatch
+
+import (
+	gocontext "context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/onsi/gomega"
+	"github.com/samber/lo"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/logs"
+)
+
+// fakeLogGroup returns its events since the start time, two per page
+type fakeLogGroup struct {
+	lock   sync.Mutex
+	events []types.FilteredLogEvent
+	calls  int
+}
+
+func (f *fakeLogGroup) add(events ...types.FilteredLogEvent) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.events = append(f.events, events...)
+}
+
+func (f *fakeLogGroup) FilterLogEvents(_ gocontext.Context, input *cloudwatchlogs.FilterLogEventsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls++
+
+	var matched []types.FilteredLogEvent
+	for _, event := range f.events {
+		if *event.Timestamp >= *input.StartTime {
+			matched = append(matched, event)
+		}
+	}
+
+	offset := 0
+	if input.NextToken != nil {
+		offset = 2
+	}
+	output := &cloudwatchlogs.FilterLogEventsOutput{Events: matched[offset:min(offset+2, len(matched))]}
+	if offset == 0 && len(matched) > 2 {
+		output.NextToken = lo.ToPtr("page-2")
+	}
+	return output, nil
+}
+
+func TestStream(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	event := func(id string, millis int64) types.FilteredLogEvent {
+		return types.FilteredLogEvent{
+			EventId:       lo.ToPtr(id),
+			Timestamp:     lo.ToPtr(start.UnixMilli() + millis),
+			Message:       lo.ToPtr("message " + id),
+			LogStreamName: lo.ToPtr("stream-1"),
+		}
+	}
+
+	api := &fakeLogGroup{events: []types.FilteredLogEvent{event("before", -1), event("1", 0), event("2", 5), event("3", 5)}}
+
+	ctx, cancel := context.New().WithTimeout(5 * time.Second)
+	defer cancel()
+
+	items, err := stream(ctx, api, StreamRequest{
+		LogGroup:      "app",
+		Start:         start.Format(time.RFC3339),
+		StreamOptions: logs.StreamOptions{PollInterval: "10ms"},
+	})
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+
+	var ids []string
+	for item := range items {
+		g.Expect(item.Error).ToNot(gomega.HaveOccurred())
+		ids = append(ids, item.LogLine.ID)
+		g.Expect(item.LogLine.Source).To(gomega.Equal("stream-1"))
+
+		if len(ids) == 3 {
+			// Events are polled again from the last timestamp, and only the new ones are streamed
+			api.add(event("4", 5), event("5", 9))
+		}
+		if len(ids) == 5 {
+			cancel()
+		}
+	}
+
+	g.Expect(ids).To(gomega.Equal([]string{"1", "2", "3", "4", "5"}))
+	g.Expect(api.calls).To(gomega.BeNumerically(">", 2))
+}
@@ -15,6 +15,24 @@ type Request struct {
 	Query string `json:"query" template:"true"`
 }
 
+// StreamRequest represents the parameters to follow a log group.
+//
+// +kubebuilder:object:generate=true
+type StreamRequest struct {
+	// The log group to follow.
+	LogGroup string `json:"logGroup" template:"true"`
+
+	// FilterPattern filters the log events, see
+	// https://docs.aws.amazon.com/AmazonCloudWatch/latest/logs/FilterAndPatternSyntax.html
+	FilterPattern string `json:"filterPattern,omitempty" template:"true"`
+
+	// Start is the time to follow the log group from (default now)
+	// Supports Datemath
+	Start string `json:"start,omitempty"`
+
+	logs.StreamOptions `json:",inline"`
+}
+
 type Event struct {
 	ID      string            `json:"id,omitempty"`
 	Time    string            `json:"timestamp,omitempty"`
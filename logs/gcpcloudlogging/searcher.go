@@ -0,0 +1,26 @@
+package gcpcloudlogging
+
+import (
+	"github.com/flanksource/duty/connection"
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/logs"
+	"github.com/flanksource/duty/models"
+)
+
+func init() {
+	logs.RegisterSearcher(models.ConnectionTypeGCP, func(_ context.Context, conn string, mappingConfig *logs.FieldMappingConfig) (logs.Searcher, error) {
+		// The client is created per search as the project is a parameter of the request
+		return logs.SearcherFunc(func(ctx context.Context, request logs.SearchRequest) (*logs.LogResult, error) {
+			searcher, err := New(ctx, connection.GCPConnection{ConnectionName: conn, Project: request.Params["project"]}, mappingConfig)
+			if err != nil {
+				return nil, err
+			}
+			defer searcher.Close()
+
+			return searcher.Search(ctx, Request{
+				LogsRequestBase: request.LogsRequestBase,
+				Filter:          request.Query,
+			})
+		}), nil
+	})
+}
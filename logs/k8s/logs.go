@@ -9,6 +9,7 @@ import (
 
 	"github.com/samber/lo"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
@@ -40,6 +41,14 @@ type Request struct {
 	Containers types.MatchExpressions `json:"containers,omitempty"`
 }
 
+// StreamRequest represents the parameters to follow Kubernetes logs.
+//
+// +kubebuilder:object:generate=true
+type StreamRequest struct {
+	Request            `json:",inline" yaml:",inline" template:"true"`
+	logs.StreamOptions `json:",inline" yaml:",inline"`
+}
+
 type K8sLogFetcher struct {
 	conn connection.KubernetesConnection
 }
@@ -56,77 +65,96 @@ func (t *K8sLogFetcher) Search(ctx context.Context, request Request) ([]logs.Log
 		return nil, fmt.Errorf("failed to populate kubernetes connection: %w", err)
 	}
 
-	switch request.Kind {
-	case "Pod":
-		pod, err := client.CoreV1().Pods(request.Namespace).Get(ctx, request.Name, metav1.GetOptions{})
-		if err != nil {
-			return nil, fmt.Errorf("failed to get pod %s/%s: %w", request.Namespace, request.Name, err)
-		}
-
-		return fetchPodLogs(ctx, client, *pod, request)
-
-	case "Deployment":
-		return fetchDeploymentLogs(ctx, client, request.Namespace, request.Name, request)
-
-	case "StatefulSet":
-		return fetchStatefulSetLogs(ctx, client, request.Namespace, request.Name, request)
+	pods, err := getPods(ctx, client, request)
+	if err != nil {
+		return nil, err
+	}
 
-	case "DaemonSet":
-		return fetchDaemonSetLogs(ctx, client, request.Namespace, request.Name, request)
+	var logGroups []logs.LogResult
+	for _, pod := range pods {
+		for _, container := range podContainers(pod, request) {
+			if logs, err := fetchContainerLogs(ctx, client, pod, container, request); err != nil {
+				return nil, err
+			} else if logs != nil {
+				logGroups = append(logGroups, *logs)
+			}
+		}
 	}
 
-	return nil, fmt.Errorf("unsupported kind: %s", request.Kind)
+	return logGroups, nil
 }
 
-func fetchStatefulSetLogs(ctx context.Context, client kubernetes.Interface, namespace, name string, request Request) ([]logs.LogResult, error) {
-	sts, err := client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+// Stream follows the logs of the containers of the pods of the resource.
+// The stream ends once all the containers terminate.
+func (t *K8sLogFetcher) Stream(ctx context.Context, request StreamRequest) (<-chan logs.StreamItem, error) {
+	client, _, err := t.conn.Populate(ctx, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get StatefulSet %s/%s: %w", namespace, name, err)
+		return nil, fmt.Errorf("failed to populate kubernetes connection: %w", err)
 	}
 
-	selector := metav1.FormatLabelSelector(sts.Spec.Selector)
-	return fetchPodsLogs(ctx, client, selector, request)
-}
-
-func fetchDaemonSetLogs(ctx context.Context, client kubernetes.Interface, namespace, name string, request Request) ([]logs.LogResult, error) {
-	ds, err := client.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	pods, err := getPods(ctx, client, request.Request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get DaemonSet %s/%s: %w", namespace, name, err)
+		return nil, err
 	}
 
-	selector := metav1.FormatLabelSelector(ds.Spec.Selector)
-	return fetchPodsLogs(ctx, client, selector, request)
+	writer := logs.NewStreamWriter(ctx, request.StreamOptions)
+	for _, pod := range pods {
+		for _, container := range podContainers(pod, request.Request) {
+			writer.Go(time.Time{}, func(cursor *logs.StreamCursor) error {
+				return followContainerLogs(ctx, client, pod, container, request.Request, cursor)
+			})
+		}
+	}
+	go writer.Close()
+
+	return writer.Items(), nil
 }
 
-func fetchDeploymentLogs(ctx context.Context, client kubernetes.Interface, namespace, name string, request Request) ([]logs.LogResult, error) {
-	deploy, err := client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to get Deployment %s/%s: %w", namespace, name, err)
+func getPods(ctx context.Context, client kubernetes.Interface, request Request) ([]corev1.Pod, error) {
+	switch request.Kind {
+	case "Pod":
+		pod, err := client.CoreV1().Pods(request.Namespace).Get(ctx, request.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pod %s/%s: %w", request.Namespace, request.Name, err)
+		}
+		return []corev1.Pod{*pod}, nil
+
+	case "Deployment":
+		deploy, err := client.AppsV1().Deployments(request.Namespace).Get(ctx, request.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Deployment %s/%s: %w", request.Namespace, request.Name, err)
+		}
+		return listPods(ctx, client, metav1.FormatLabelSelector(deploy.Spec.Selector), request)
+
+	case "StatefulSet":
+		sts, err := client.AppsV1().StatefulSets(request.Namespace).Get(ctx, request.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get StatefulSet %s/%s: %w", request.Namespace, request.Name, err)
+		}
+		return listPods(ctx, client, metav1.FormatLabelSelector(sts.Spec.Selector), request)
+
+	case "DaemonSet":
+		ds, err := client.AppsV1().DaemonSets(request.Namespace).Get(ctx, request.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get DaemonSet %s/%s: %w", request.Namespace, request.Name, err)
+		}
+		return listPods(ctx, client, metav1.FormatLabelSelector(ds.Spec.Selector), request)
 	}
 
-	selector := metav1.FormatLabelSelector(deploy.Spec.Selector)
-	return fetchPodsLogs(ctx, client, selector, request)
+	return nil, fmt.Errorf("unsupported kind: %s", request.Kind)
 }
 
-func fetchPodsLogs(ctx context.Context, client kubernetes.Interface, selector string, request Request) ([]logs.LogResult, error) {
+func listPods(ctx context.Context, client kubernetes.Interface, selector string, request Request) ([]corev1.Pod, error) {
 	podList, err := client.CoreV1().Pods(request.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list pods in namespace %q with selector %q: %w", request.Namespace, selector, err)
 	}
-
-	var logGroups []logs.LogResult
-	for _, pod := range podList.Items {
-		if logs, err := fetchPodLogs(ctx, client, pod, request); err != nil {
-			return nil, err
-		} else if logs != nil {
-			logGroups = append(logGroups, logs...)
-		}
-	}
-
-	return logGroups, nil
+	return podList.Items, nil
 }
 
-func fetchPodLogs(ctx context.Context, client kubernetes.Interface, pod corev1.Pod, request Request) ([]logs.LogResult, error) {
+// podContainers returns the containers of the pod to fetch the logs of.
+// The default container is returned as "" when the request doesn't filter containers.
+func podContainers(pod corev1.Pod, request Request) []string {
 	if len(request.Pods) > 0 {
 		// Convert the pod to config item so it's resource selectable
 		// Instead of resource selectors maybe we can just use a cel-expression?
@@ -138,30 +166,21 @@ func fetchPodLogs(ctx context.Context, client kubernetes.Interface, pod corev1.P
 			},
 		}
 		if !request.Pods.Matches(configItem) {
-			return nil, nil
+			return nil
 		}
 	}
 
-	var logGroups []logs.LogResult
 	if len(request.Containers) == 0 {
-		if logs, err := fetchContainerLogs(ctx, client, pod, "", request); err != nil {
-			return nil, err
-		} else if logs != nil {
-			logGroups = append(logGroups, *logs)
-		}
-	} else {
-		for _, container := range pod.Spec.Containers {
-			if request.Containers.Match(container.Name) {
-				if logs, err := fetchContainerLogs(ctx, client, pod, container.Name, request); err != nil {
-					return nil, err
-				} else if logs != nil {
-					logGroups = append(logGroups, *logs)
-				}
-			}
-		}
+		return []string{""}
 	}
 
-	return logGroups, nil
+	var containers []string
+	for _, container := range pod.Spec.Containers {
+		if request.Containers.Match(container.Name) {
+			containers = append(containers, container.Name)
+		}
+	}
+	return containers
 }
 
 func fetchContainerLogs(ctx context.Context, client kubernetes.Interface, pod corev1.Pod, containerName string, request Request) (*logs.LogResult, error) {
@@ -198,30 +217,119 @@ func fetchContainerLogs(ctx context.Context, client kubernetes.Interface, pod co
 	}
 	scanner := bufio.NewScanner(podLogs)
 	for scanner.Scan() {
-		parts := strings.SplitN(scanner.Text(), " ", 2)
-		if len(parts) < 2 {
-			continue
+		if line := parseLogLine(pod, scanner.Text()); line != nil {
+			output.Logs = append(output.Logs, line)
 		}
+	}
 
-		t, err := time.Parse(time.RFC3339, parts[0])
-		if err != nil {
-			continue
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading logs: %w", err)
+	}
+
+	return &output, nil
+}
+
+// followContainerLogs sends the logs of the container until it terminates.
+// An error is returned when the stream ends while the container is still running, so the stream writer reconnects.
+func followContainerLogs(ctx context.Context, client kubernetes.Interface, pod corev1.Pod, containerName string, request Request, cursor *logs.StreamCursor) error {
+	opt := &corev1.PodLogOptions{
+		Container:  containerName,
+		Timestamps: true,
+		Follow:     true,
+	}
+
+	if since := cursor.Since(); !since.IsZero() {
+		// The lines sent before reconnecting are skipped by the cursor
+		opt.SinceTime = &metav1.Time{Time: since}
+	} else {
+		if s, err := request.GetStart(); err == nil {
+			opt.SinceTime = &metav1.Time{Time: s}
 		}
+		if request.Limit != "" {
+			limit, err := strconv.ParseInt(request.Limit, 10, 32)
+			if err != nil {
+				return err
+			}
+			opt.TailLines = lo.ToPtr(limit)
+		}
+	}
 
-		line := &logs.LogLine{
-			Count:         1,
-			Message:       parts[1],
-			Labels:        pod.Labels,
-			Host:          pod.Name,
-			FirstObserved: t,
+	podLogs, err := client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opt).Stream(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to follow logs of %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+	defer podLogs.Close()
+
+	scanner := bufio.NewScanner(podLogs)
+	for scanner.Scan() {
+		if line := parseLogLine(pod, scanner.Text()); line != nil {
+			if !cursor.Send(line) {
+				return nil
+			}
 		}
-		line.SetHash()
-		output.Logs = append(output.Logs, line)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading logs: %w", err)
+	if ctx.Err() != nil {
+		return nil
+	} else if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading logs of %s/%s: %w", pod.Namespace, pod.Name, err)
 	}
 
-	return &output, nil
+	// The API server also ends the stream of a running container, e.g. on a timeout or a restart of the kubelet,
+	// so the stream is only done once the container has terminated.
+	current, err := client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+	if !isContainerTerminated(*current, containerName) {
+		return fmt.Errorf("logs of %s/%s ended before the container terminated", pod.Namespace, pod.Name)
+	}
+	return nil
+}
+
+// isContainerTerminated returns true when the container, or the default container when no name is given,
+// has terminated or the pod has completed.
+func isContainerTerminated(pod corev1.Pod, containerName string) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return true
+	}
+
+	if containerName == "" {
+		containerName = pod.Annotations["kubectl.kubernetes.io/default-container"]
+	}
+	if containerName == "" && len(pod.Spec.Containers) > 0 {
+		containerName = pod.Spec.Containers[0].Name
+	}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == containerName {
+			return status.State.Terminated != nil
+		}
+	}
+	return false
+}
+
+// parseLogLine parses a line of the pod's logs, which are prefixed with their timestamp.
+func parseLogLine(pod corev1.Pod, text string) *logs.LogLine {
+	parts := strings.SplitN(text, " ", 2)
+	if len(parts) < 2 {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return nil
+	}
+
+	line := &logs.LogLine{
+		Count:         1,
+		Message:       parts[1],
+		Labels:        pod.Labels,
+		Host:          pod.Name,
+		FirstObserved: t,
+	}
+	line.SetHash()
+	return line
 }
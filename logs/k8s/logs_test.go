@@ -0,0 +1,59 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/logs"
+)
+
+func TestFollowContainerLogs(t *testing.T) {
+	pod := func(state corev1.ContainerState) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "api"}}},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "api", State: state}},
+			},
+		}
+	}
+
+	follow := func(pod *corev1.Pod) []error {
+		client := fake.NewSimpleClientset(pod)
+		writer := logs.NewStreamWriter(context.New(), logs.StreamOptions{MaxReconnects: -1})
+		writer.Go(time.Time{}, func(cursor *logs.StreamCursor) error {
+			return followContainerLogs(context.New(), client, *pod, "", Request{}, cursor)
+		})
+		go writer.Close()
+
+		var errs []error
+		for item := range writer.Items() {
+			if item.Error != nil {
+				errs = append(errs, item.Error)
+			}
+		}
+		return errs
+	}
+
+	t.Run("fails when the stream ends while the container runs", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		errs := follow(pod(corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}))
+		g.Expect(errs).To(gomega.HaveLen(1))
+		g.Expect(errs[0].Error()).To(gomega.ContainSubstring("ended before the container terminated"))
+	})
+
+	t.Run("ends once the container terminated", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		errs := follow(pod(corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}))
+		g.Expect(errs).To(gomega.BeEmpty())
+	})
+}
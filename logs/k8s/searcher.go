@@ -0,0 +1,33 @@
+package k8s
+
+import (
+	"github.com/flanksource/duty/connection"
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/logs"
+	"github.com/flanksource/duty/models"
+)
+
+func init() {
+	logs.RegisterSearcher(models.ConnectionTypeKubernetes, func(ctx context.Context, conn string, _ *logs.FieldMappingConfig) (logs.Searcher, error) {
+		fetcher := New(connection.KubernetesConnection{KubeconfigConnection: connection.KubeconfigConnection{ConnectionName: conn}})
+		return logs.SearcherFunc(func(ctx context.Context, request logs.SearchRequest) (*logs.LogResult, error) {
+			results, err := fetcher.Search(ctx, Request{
+				LogsRequestBase: request.LogsRequestBase,
+				Kind:            request.Params["kind"],
+				ApiVersion:      request.Params["apiVersion"],
+				Namespace:       request.Params["namespace"],
+				Name:            request.Params["name"],
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			// One result per container is merged into a single result
+			merged := &logs.LogResult{}
+			for _, result := range results {
+				merged.Logs = append(merged.Logs, result.Lines()...)
+			}
+			return merged, nil
+		}), nil
+	})
+}
@@ -98,16 +98,8 @@ func (t *lokiSearcher) Stream(ctx context.Context, request StreamRequest) (<-cha
 	if parsedBaseURL.Scheme == "https" {
 		wsScheme = "wss"
 	}
-	wsURL := &url.URL{
-		Scheme:   wsScheme,
-		Host:     parsedBaseURL.Host,
-		Path:     "/loki/api/v1/tail",
-		RawQuery: request.Params().Encode(),
-	}
 
-	dialer := websocket.DefaultDialer
 	headers := netHTTP.Header{}
-
 	if t.conn.Username != nil && t.conn.Password != nil {
 		username := t.conn.Username.ValueStatic
 		password := t.conn.Password.ValueStatic
@@ -116,81 +108,124 @@ func (t *lokiSearcher) Stream(ctx context.Context, request StreamRequest) (<-cha
 		headers.Set("Authorization", basicAuth)
 	}
 
-	conn, _, err := dialer.DialContext(ctx, wsURL.String(), headers)
+	dial := func(since time.Time) (*websocket.Conn, error) {
+		params := request.Params()
+		if !since.IsZero() {
+			params.Set("start", strconv.FormatInt(since.UnixNano(), 10))
+		}
+
+		wsURL := &url.URL{
+			Scheme:   wsScheme,
+			Host:     parsedBaseURL.Host,
+			Path:     "/loki/api/v1/tail",
+			RawQuery: params.Encode(),
+		}
+
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL.String(), headers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to websocket: %w", err)
+		}
+		return conn, nil
+	}
+
+	// The first connection is made here so that connection errors are returned to the caller
+	conn, err := dial(time.Time{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to websocket: %w", err)
+		return nil, err
 	}
 
-	itemChan := make(chan StreamItem)
+	mappingConfig := DefaultFieldMappingConfig
+	if t.mappingConfig != nil {
+		mappingConfig = t.mappingConfig.WithDefaults(DefaultFieldMappingConfig)
+	}
+
+	writer := logs.NewStreamWriter(ctx, request.StreamOptions)
+	writer.Go(time.Time{}, func(cursor *logs.StreamCursor) error {
+		if conn == nil {
+			if conn, err = dial(cursor.Since()); err != nil {
+				return err
+			}
+		}
+		defer func() {
+			conn.Close()
+			conn = nil
+		}()
+
+		return readStream(ctx, conn, cursor, writer, mappingConfig)
+	})
+	go writer.Close()
+
+	return writer.Items(), nil
+}
 
+// readStream sends the lines of a tail connection until it is closed.
+func readStream(ctx context.Context, conn *websocket.Conn, cursor *logs.StreamCursor, writer *logs.StreamWriter, mappingConfig logs.FieldMappingConfig) error {
+	// Closing the connection unblocks the read once the context is done
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
-		defer close(itemChan)
-		defer conn.Close()
-
-		conn.SetPongHandler(func(string) error {
-			return conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-		})
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				var response StreamResponse
-				if err := conn.ReadJSON(&response); err != nil {
-					if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-						return
-					}
-					select {
-					case itemChan <- StreamItem{Error: fmt.Errorf("WebSocket read error in Loki stream: %w", err)}:
-					case <-ctx.Done():
-					}
-					return
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	})
+
+	for {
+		var response StreamResponse
+		if err := conn.ReadJSON(&response); err != nil {
+			if ctx.Err() != nil || websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return fmt.Errorf("WebSocket read error in Loki stream: %w", err)
+		}
+
+		for _, stream := range response.Streams {
+			for _, v := range stream.Values {
+				if len(v) != 2 {
+					continue
+				}
+
+				firstObserved, err := strconv.ParseInt(v[0], 10, 64)
+				if err != nil {
+					continue
 				}
 
-				mappingConfig := DefaultFieldMappingConfig
-				if t.mappingConfig != nil {
-					mappingConfig = t.mappingConfig.WithDefaults(DefaultFieldMappingConfig)
+				line := &logs.LogLine{
+					Count:         1,
+					FirstObserved: time.Unix(0, firstObserved),
+					Message:       v[1],
+					Labels:        stream.Stream,
 				}
 
-				for _, stream := range response.Streams {
-					for _, v := range stream.Values {
-						if len(v) != 2 {
-							continue
-						}
-
-						firstObserved, err := strconv.ParseInt(v[0], 10, 64)
-						if err != nil {
-							continue
-						}
-
-						line := &logs.LogLine{
-							Count:         1,
-							FirstObserved: time.Unix(0, firstObserved),
-							Message:       v[1],
-							Labels:        stream.Stream,
-						}
-
-						for k, val := range stream.Stream {
-							if err := logs.MapFieldToLogLine(k, val, line, mappingConfig); err != nil {
-								continue
-							}
-						}
-
-						line.SetHash()
-
-						select {
-						case itemChan <- StreamItem{LogLine: line}:
-						case <-ctx.Done():
-							return
-						}
+				for k, val := range stream.Stream {
+					if err := logs.MapFieldToLogLine(k, val, line, mappingConfig); err != nil {
+						continue
 					}
 				}
+
+				line.SetHash()
+				if !cursor.Send(line) {
+					return nil
+				}
 			}
 		}
-	}()
 
-	return itemChan, nil
+		for _, dropped := range response.DroppedEntries {
+			if !writer.Drop(logs.DroppedEntry{
+				Count:     1,
+				Timestamp: dropped.Timestamp,
+				Labels:    dropped.Labels,
+				Reason:    logs.DroppedBackend,
+			}) {
+				return nil
+			}
+		}
+	}
 }
 
 var DefaultFieldMappingConfig = logs.FieldMappingConfig{
@@ -0,0 +1,24 @@
+package loki
+
+import (
+	"github.com/flanksource/duty/connection"
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/logs"
+	"github.com/flanksource/duty/models"
+)
+
+func init() {
+	logs.RegisterSearcher(models.ConnectionTypeLoki, func(ctx context.Context, conn string, mappingConfig *logs.FieldMappingConfig) (logs.Searcher, error) {
+		searcher := New(connection.Loki{ConnectionName: conn}, mappingConfig)
+		return logs.SearcherFunc(func(ctx context.Context, request logs.SearchRequest) (*logs.LogResult, error) {
+			return searcher.Search(ctx, Request{
+				LogsRequestBase: request.LogsRequestBase,
+				Query:           request.Query,
+				Since:           request.Params["since"],
+				Step:            request.Params["step"],
+				Interval:        request.Params["interval"],
+				Direction:       request.Params["direction"],
+			})
+		}), nil
+	})
+}
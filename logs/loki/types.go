@@ -148,6 +148,8 @@ type StreamRequest struct {
 	// Start is the start time for the query (default one hour ago)
 	// Supports Datemath
 	Start string `json:"start,omitempty"`
+
+	logs.StreamOptions `json:",inline"`
 }
 
 // Params returns the URL query parameters for the Loki streaming request
@@ -193,7 +195,4 @@ type DroppedEntry struct {
 	Timestamp time.Time         `json:"timestamp"`
 }
 
-type StreamItem struct {
-	LogLine *logs.LogLine
-	Error   error
-}
+type StreamItem = logs.StreamItem
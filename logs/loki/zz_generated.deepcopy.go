@@ -25,6 +25,7 @@ func (in *Request) DeepCopy() *Request {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StreamRequest) DeepCopyInto(out *StreamRequest) {
 	*out = *in
+	out.StreamOptions = in.StreamOptions
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StreamRequest.
@@ -0,0 +1,190 @@
+package logs
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/commons/duration"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/dataquery"
+)
+
+const maxLogMetricBuckets = 10000
+
+// defaultLogMetricsLimit is the limit of the search of SearchLogMetrics when the request has none,
+// so that backends don't cap the lines with their own, smaller, default limits.
+const defaultLogMetricsLimit = 10000
+
+// LogMetricsRequest turns the lines of a log search into a time series of line counts.
+type LogMetricsRequest struct {
+	// Start & End of the window. Buckets without lines are returned with a count of 0 when both are set.
+	LogsRequestBase `json:",inline" yaml:",inline" template:"true"`
+
+	// Step is the width of the buckets, e.g. 1m or 1h (default 1m)
+	Step string `json:"step,omitempty" yaml:"step,omitempty" template:"true"`
+
+	// GroupBy maps the columns of the rows to the fields of the lines they are counted by,
+	// e.g. {"level": "severity", "app": "label.app"}.
+	// Fields are id, message, severity, source, host or label.<name>, a bare name is a label.
+	GroupBy map[string]string `json:"groupBy,omitempty" yaml:"groupBy,omitempty" template:"true"`
+}
+
+// SearchLogMetrics searches the backends over the window of the request and returns the counts of the lines.
+// The counts are of the lines returned by the backends, so an error is returned
+// when a backend hits the limit of the search instead of returning counts that are capped.
+func SearchLogMetrics(ctx context.Context, backends []SearchBackend, request LogMetricsRequest) ([]dataquery.QueryResultRow, error) {
+	window := request.LogsRequestBase
+	if window.Limit == "" {
+		window.Limit = strconv.Itoa(defaultLogMetricsLimit)
+	}
+
+	result, err := FanOutSearch(ctx, window, backends)
+	if err != nil {
+		return nil, err
+	}
+	if truncated, _ := result.Metadata["truncated"].(bool); truncated {
+		return nil, fmt.Errorf("log search reached the limit of %s lines, narrow the window or the query to count all the lines", window.Limit)
+	}
+	return LogMetrics(result.Lines(), request)
+}
+
+// LogMetrics counts the lines per bucket of time and per group.
+// Like the range queries of dataquery.PrometheusQuery, every row has the columns of the group,
+// the "timestamp" at the start of the bucket and the count as "value".
+// Rows are ordered by group, then by time.
+func LogMetrics(lines []*LogLine, request LogMetricsRequest) ([]dataquery.QueryResultRow, error) {
+	step := time.Minute
+	if request.Step != "" {
+		d, err := duration.ParseDuration(request.Step)
+		if err != nil {
+			return nil, fmt.Errorf("invalid step %q: %w", request.Step, err)
+		}
+		if step = time.Duration(d); step <= 0 {
+			return nil, fmt.Errorf("step must be greater than zero")
+		}
+	}
+
+	var start, end time.Time
+	if request.Start != "" {
+		s, err := request.GetStart()
+		if err != nil {
+			return nil, fmt.Errorf("invalid start %q: %w", request.Start, err)
+		}
+		start = s
+	}
+	if request.End != "" {
+		e, err := request.GetEnd()
+		if err != nil {
+			return nil, fmt.Errorf("invalid end %q: %w", request.End, err)
+		}
+		end = e
+	}
+
+	bucketOf := func(t time.Time) time.Time {
+		if start.IsZero() {
+			return t.Truncate(step)
+		}
+		return start.Add(t.Sub(start) / step * step)
+	}
+
+	columns := make([]string, 0, len(request.GroupBy))
+	for column := range request.GroupBy {
+		columns = append(columns, column)
+	}
+	slices.Sort(columns)
+
+	type series struct {
+		group   []string
+		buckets map[time.Time]float64
+	}
+	seriesByKey := map[string]*series{}
+	for _, line := range lines {
+		if !start.IsZero() && line.FirstObserved.Before(start) {
+			continue
+		}
+		if !end.IsZero() && !line.FirstObserved.Before(end) {
+			continue
+		}
+
+		group := make([]string, len(columns))
+		for i, column := range columns {
+			group[i] = line.fieldValue(request.GroupBy[column])
+		}
+		key := strings.Join(group, "\x00")
+
+		s, ok := seriesByKey[key]
+		if !ok {
+			s = &series{group: group, buckets: map[time.Time]float64{}}
+			seriesByKey[key] = s
+		}
+		s.buckets[bucketOf(line.FirstObserved)] += float64(max(line.Count, 1))
+	}
+
+	// Every bucket of the window is returned so that charts show the gaps
+	var window []time.Time
+	if !start.IsZero() && !end.IsZero() {
+		if int(end.Sub(start)/step) > maxLogMetricBuckets {
+			return nil, fmt.Errorf("window of %s has more than %d buckets of %s", end.Sub(start), maxLogMetricBuckets, step)
+		}
+		for t := start; t.Before(end); t = t.Add(step) {
+			window = append(window, t)
+		}
+	}
+
+	keys := make([]string, 0, len(seriesByKey))
+	for key := range seriesByKey {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	rows := []dataquery.QueryResultRow{}
+	for _, key := range keys {
+		s := seriesByKey[key]
+
+		buckets := window
+		if buckets == nil {
+			for t := range s.buckets {
+				buckets = append(buckets, t)
+			}
+			sort.Slice(buckets, func(i, j int) bool { return buckets[i].Before(buckets[j]) })
+		}
+
+		for _, t := range buckets {
+			row := dataquery.QueryResultRow{
+				"timestamp": t,
+				"value":     s.buckets[t],
+			}
+			for i, column := range columns {
+				row[column] = s.group[i]
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, nil
+}
+
+// fieldValue returns the value of a field of the line, see LogMetricsRequest.GroupBy
+func (t LogLine) fieldValue(field string) string {
+	switch field {
+	case "id":
+		return t.ID
+	case "message":
+		return t.EffectiveMessage()
+	case "hash":
+		return t.Hash
+	case "severity":
+		return t.Severity
+	case "source":
+		return t.Source
+	case "host":
+		return t.Host
+	default:
+		return t.Labels[strings.TrimPrefix(field, "label.")]
+	}
+}
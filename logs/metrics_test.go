@@ -0,0 +1,99 @@
+package logs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/dataquery"
+)
+
+func TestLogMetrics(t *testing.T) {
+	base := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	at := func(minutes, seconds int) time.Time {
+		return base.Add(time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second)
+	}
+
+	lines := []*LogLine{
+		{FirstObserved: at(0, 10), Severity: "error", Labels: map[string]string{"app": "api"}},
+		{FirstObserved: at(0, 50), Severity: "error", Labels: map[string]string{"app": "api"}, Count: 3},
+		{FirstObserved: at(0, 30), Severity: "info", Labels: map[string]string{"app": "api"}},
+		{FirstObserved: at(2, 0), Severity: "error", Labels: map[string]string{"app": "worker"}},
+		{FirstObserved: at(-1, 0), Severity: "error", Labels: map[string]string{"app": "api"}},
+	}
+
+	t.Run("counts per bucket and group", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		rows, err := LogMetrics(lines, LogMetricsRequest{
+			LogsRequestBase: LogsRequestBase{Start: base.Format(time.RFC3339), End: at(3, 0).Format(time.RFC3339)},
+			Step:            "1m",
+			GroupBy:         map[string]string{"level": "severity", "app": "label.app"},
+		})
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(rows).To(gomega.Equal([]dataquery.QueryResultRow{
+			{"app": "api", "level": "error", "timestamp": at(0, 0), "value": 4.0},
+			{"app": "api", "level": "error", "timestamp": at(1, 0), "value": 0.0},
+			{"app": "api", "level": "error", "timestamp": at(2, 0), "value": 0.0},
+			{"app": "api", "level": "info", "timestamp": at(0, 0), "value": 1.0},
+			{"app": "api", "level": "info", "timestamp": at(1, 0), "value": 0.0},
+			{"app": "api", "level": "info", "timestamp": at(2, 0), "value": 0.0},
+			{"app": "worker", "level": "error", "timestamp": at(0, 0), "value": 0.0},
+			{"app": "worker", "level": "error", "timestamp": at(1, 0), "value": 0.0},
+			{"app": "worker", "level": "error", "timestamp": at(2, 0), "value": 1.0},
+		}))
+	})
+
+	t.Run("only buckets with lines without a window", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		rows, err := LogMetrics(lines, LogMetricsRequest{Step: "1h"})
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(rows).To(gomega.Equal([]dataquery.QueryResultRow{
+			{"timestamp": base.Add(-time.Hour), "value": 1.0},
+			{"timestamp": base, "value": 6.0},
+		}))
+	})
+
+	t.Run("from a search", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		RegisterSearcher("test-metrics", staticSearcher(lines...))
+		rows, err := SearchLogMetrics(context.New(), []SearchBackend{{Type: "test-metrics"}}, LogMetricsRequest{
+			LogsRequestBase: LogsRequestBase{Start: base.Format(time.RFC3339), End: at(1, 0).Format(time.RFC3339)},
+			GroupBy:         map[string]string{"level": "severity"},
+		})
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(rows).To(gomega.Equal([]dataquery.QueryResultRow{
+			{"level": "error", "timestamp": at(0, 0), "value": 4.0},
+			{"level": "info", "timestamp": at(0, 0), "value": 1.0},
+		}))
+	})
+
+	t.Run("fails when the search reaches its limit", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		_, err := SearchLogMetrics(context.New(), []SearchBackend{{Type: "test-metrics"}}, LogMetricsRequest{
+			LogsRequestBase: LogsRequestBase{Start: base.Format(time.RFC3339), End: at(3, 0).Format(time.RFC3339), Limit: "3"},
+		})
+		g.Expect(err).To(gomega.HaveOccurred())
+		g.Expect(err.Error()).To(gomega.ContainSubstring("limit of 3 lines"))
+
+		_, err = SearchLogMetrics(context.New(), []SearchBackend{{Type: "test-metrics", Request: SearchRequest{LogsRequestBase: LogsRequestBase{Limit: "5"}}}}, LogMetricsRequest{
+			LogsRequestBase: LogsRequestBase{Start: base.Format(time.RFC3339), End: at(3, 0).Format(time.RFC3339)},
+		})
+		g.Expect(err).To(gomega.HaveOccurred())
+	})
+
+	t.Run("too many buckets", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		_, err := LogMetrics(lines, LogMetricsRequest{
+			LogsRequestBase: LogsRequestBase{Start: "now-30d", End: "now"},
+			Step:            "1s",
+		})
+		g.Expect(err).To(gomega.HaveOccurred())
+	})
+}
@@ -0,0 +1,105 @@
+package logs
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// java: "Caused by: ...", "Suppressed: ...", "... 12 more", "... 3 common frames omitted"
+	javaContinuationPattern = regexp.MustCompile(`^(Caused by|Suppressed): |^\.\.\. \d+ (more|common frames omitted)$`)
+
+	// python: the header of a chained traceback
+	pythonChainPattern = regexp.MustCompile(`^(During handling of the above exception|The above exception was the direct cause)`)
+
+	// python: the exception that ends a traceback, e.g. "ValueError: invalid literal"
+	pythonExceptionPattern = regexp.MustCompile(`^[A-Za-z_][\w.]*(: .*)?$`)
+
+	// go: "[signal SIGSEGV: segmentation violation ...]" after a panic
+	goSignalPattern = regexp.MustCompile(`^\[signal .*\]$`)
+
+	// go: "goroutine 1 [running]:"
+	goroutinePattern = regexp.MustCompile(`^goroutine \d+ \[[^\]]+\]:$`)
+
+	// go: the function of a stack frame, e.g. "main.main()", "net/http.(*conn).serve(0xc000)" or "created by main.main"
+	goFramePattern = regexp.MustCompile(`^(created by \S+( in goroutine \d+)?|[\w./*()\-\[\]]+\(.*\))$`)
+)
+
+type multilineState int
+
+const (
+	multilineNone multilineState = iota
+	multilinePythonTraceback
+	multilineGoroutine
+)
+
+// JoinMultiline merges the continuation lines of multi-line entries, e.g. Java, Go and Python stack traces,
+// into the preceding line. Continuation lines are joined to the message with a newline.
+//
+// Lines are joined to the preceding line of the same host and source, so the lines of
+// interleaved streams can be joined. It should be called on the lines before they are parsed.
+func JoinMultiline(lines []*LogLine) []*LogLine {
+	type multilineEntry struct {
+		line  *LogLine
+		state multilineState
+	}
+
+	var joined []*LogLine
+	entries := map[string]*multilineEntry{}
+	for _, line := range lines {
+		key := line.Host + "\x00" + line.Source
+		entry, ok := entries[key]
+
+		var continuation bool
+		if ok {
+			continuation, entry.state = isContinuation(line.Message, entry.state)
+		}
+		if !continuation {
+			entries[key] = &multilineEntry{line: line}
+			joined = append(joined, line)
+			continue
+		}
+
+		entry.line.Message += "\n" + line.Message
+		if !line.FirstObserved.IsZero() && (entry.line.LastObserved == nil || line.FirstObserved.After(*entry.line.LastObserved)) {
+			observed := line.FirstObserved
+			entry.line.LastObserved = &observed
+		}
+		if entry.line.Hash != "" {
+			entry.line.SetHash()
+		}
+	}
+
+	return joined
+}
+
+// isContinuation returns whether the message continues the preceding entry,
+// and the state of the stack trace it is part of.
+func isContinuation(message string, state multilineState) (bool, multilineState) {
+	trimmed := strings.TrimRight(message, "\r")
+
+	switch {
+	case strings.HasPrefix(trimmed, "Traceback (most recent call last):"):
+		return true, multilinePythonTraceback
+	case goroutinePattern.MatchString(trimmed):
+		return true, multilineGoroutine
+	case trimmed == "" || trimmed[0] == ' ' || trimmed[0] == '\t':
+		return true, state
+	case javaContinuationPattern.MatchString(trimmed), pythonChainPattern.MatchString(trimmed), goSignalPattern.MatchString(trimmed):
+		return true, state
+	}
+
+	switch state {
+	case multilinePythonTraceback:
+		// The exception ends the traceback
+		if pythonExceptionPattern.MatchString(trimmed) {
+			return true, multilineNone
+		}
+	case multilineGoroutine:
+		if goFramePattern.MatchString(trimmed) {
+			return true, multilineGoroutine
+		}
+	}
+
+	return false, multilineNone
+}
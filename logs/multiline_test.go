@@ -0,0 +1,57 @@
+package logs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+type multilineFixture struct {
+	Cases []struct {
+		Name     string   `yaml:"name"`
+		Lines    []string `yaml:"lines"`
+		Expected []string `yaml:"expected"`
+	} `yaml:"cases"`
+}
+
+func TestJoinMultiline(t *testing.T) {
+	data, err := os.ReadFile("testdata/multiline.yaml")
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	var fixture multilineFixture
+	if err := yaml.Unmarshal(data, &fixture); err != nil {
+		t.Fatalf("parsing fixture: %v", err)
+	}
+
+	for _, tc := range fixture.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+
+			var lines []*LogLine
+			for _, message := range tc.Lines {
+				lines = append(lines, &LogLine{Message: message, Count: 1})
+			}
+
+			var messages []string
+			for _, line := range JoinMultiline(lines) {
+				messages = append(messages, line.Message)
+			}
+			g.Expect(messages).To(gomega.Equal(tc.Expected))
+		})
+	}
+
+	t.Run("only joins lines of the same host", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		joined := JoinMultiline([]*LogLine{
+			{Host: "pod-a", Message: "java.lang.NullPointerException"},
+			{Host: "pod-b", Message: "\tat com.example.Main.main(Main.java:10)"},
+			{Host: "pod-a", Message: "\tat com.example.Main.run(Main.java:20)"},
+		})
+		g.Expect(joined).To(gomega.HaveLen(2))
+		g.Expect(joined[0].Message).To(gomega.Equal("java.lang.NullPointerException\n\tat com.example.Main.run(Main.java:20)"))
+	})
+}
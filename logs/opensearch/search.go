@@ -109,30 +109,47 @@ func (t *searcher) Search(ctx context.Context, q Request) (*logs.LogResult, erro
 	}
 	logResult.Logs = make([]*logs.LogLine, 0, len(r.Hits.Hits))
 
-	mappingConfig := DefaultFieldMappingConfig
+	mappingConfig := t.fieldMapping()
+
+	for _, hit := range r.Hits.Hits {
+		logResult.Logs = append(logResult.Logs, hitToLogLine(ctx, hit, mappingConfig))
+	}
+
+	logs.GroupLogs(&logResult, mappingConfig)
+	return &logResult, nil
+}
+
+// fieldMapping returns the field mapping of the searcher, with the defaults for OpenSearch.
+func (t *searcher) fieldMapping() logs.FieldMappingConfig {
 	if t.mappingConfig != nil {
-		mappingConfig = t.mappingConfig.WithDefaults(DefaultFieldMappingConfig)
+		return t.mappingConfig.WithDefaults(DefaultFieldMappingConfig)
+	}
+	return DefaultFieldMappingConfig
+}
+
+// timestampField is the field that time windows are filtered on.
+func (t *searcher) timestampField() string {
+	if mapping := t.fieldMapping(); len(mapping.Timestamp) > 0 {
+		return mapping.Timestamp[0]
 	}
+	return "@timestamp"
+}
 
-	for _, hit := range r.Hits.Hits {
-		line := &logs.LogLine{
-			ID:    hit.ID,
-			Count: 1,
-		}
+func hitToLogLine(ctx context.Context, hit SearchHit, mappingConfig logs.FieldMappingConfig) *logs.LogLine {
+	line := &logs.LogLine{
+		ID:    hit.ID,
+		Count: 1,
+	}
 
-		for k, v := range hit.Source {
-			if err := logs.MapFieldToLogLine(k, v, line, mappingConfig); err != nil {
-				// Log or handle mapping error? For now, just log it.
-				ctx.Warnf("Error mapping field %s for log %s: %v", k, line.ID, err)
-			}
+	for k, v := range hit.Source {
+		if err := logs.MapFieldToLogLine(k, v, line, mappingConfig); err != nil {
+			// Log or handle mapping error? For now, just log it.
+			ctx.Warnf("Error mapping field %s for log %s: %v", k, line.ID, err)
 		}
-
-		line.SetHash()
-		logResult.Logs = append(logResult.Logs, line)
 	}
 
-	logs.GroupLogs(&logResult, mappingConfig)
-	return &logResult, nil
+	line.SetHash()
+	return line
 }
 
 var DefaultFieldMappingConfig = logs.FieldMappingConfig{
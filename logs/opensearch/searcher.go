@@ -0,0 +1,83 @@
+package opensearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/logs"
+	"github.com/flanksource/duty/models"
+)
+
+func init() {
+	logs.RegisterSearcher(models.ConnectionTypeOpenSearch, func(ctx context.Context, conn string, mappingConfig *logs.FieldMappingConfig) (logs.Searcher, error) {
+		searcher, err := New(ctx, Backend{ConnectionName: conn}, mappingConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		return logs.SearcherFunc(func(ctx context.Context, request logs.SearchRequest) (*logs.LogResult, error) {
+			var start, end time.Time
+			if request.Start != "" {
+				if start, err = request.GetStart(); err != nil {
+					return nil, ctx.Oops().Wrapf(err, "invalid start %q", request.Start)
+				}
+			}
+			if request.End != "" {
+				if end, err = request.GetEnd(); err != nil {
+					return nil, ctx.Oops().Wrapf(err, "invalid end %q", request.End)
+				}
+			}
+
+			// The query is a full search body, so the time window has to be part of it
+			query, err := withTimeWindow(request.Query, searcher.timestampField(), start, end)
+			if err != nil {
+				return nil, ctx.Oops().Wrap(err)
+			}
+
+			return searcher.Search(ctx, Request{
+				Index: request.Params["index"],
+				Query: query,
+				Limit: request.Limit,
+			})
+		}), nil
+	})
+}
+
+// withTimeWindow adds a range filter on the timestamp field to the query of a search body.
+// A zero start or end leaves that side of the window open.
+func withTimeWindow(body, timestampField string, start, end time.Time) (string, error) {
+	if start.IsZero() && end.IsZero() {
+		return body, nil
+	}
+
+	search := map[string]any{}
+	if strings.TrimSpace(body) != "" {
+		if err := json.Unmarshal([]byte(body), &search); err != nil {
+			return "", fmt.Errorf("invalid query: %w", err)
+		}
+	}
+
+	window := map[string]any{}
+	if !start.IsZero() {
+		window["gte"] = start.UTC().Format(time.RFC3339Nano)
+	}
+	if !end.IsZero() {
+		window["lte"] = end.UTC().Format(time.RFC3339Nano)
+	}
+
+	filter := map[string]any{"range": map[string]any{timestampField: window}}
+	if query, ok := search["query"]; ok {
+		search["query"] = map[string]any{"bool": map[string]any{"must": []any{query}, "filter": []any{filter}}}
+	} else {
+		search["query"] = map[string]any{"bool": map[string]any{"filter": []any{filter}}}
+	}
+
+	windowed, err := json.Marshal(search)
+	if err != nil {
+		return "", err
+	}
+	return string(windowed), nil
+}
@@ -0,0 +1,57 @@
+package opensearch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestWithTimeWindow(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		body       string
+		start, end time.Time
+		expected   string
+	}{
+		{
+			name:     "no window",
+			body:     `{"query": {"match_all": {}}}`,
+			expected: `{"query": {"match_all": {}}}`,
+		},
+		{
+			name:     "empty body",
+			start:    start,
+			expected: `{"query": {"bool": {"filter": [{"range": {"ts": {"gte": "2025-01-01T00:00:00Z"}}}]}}}`,
+		},
+		{
+			name:  "query and other fields of the body",
+			body:  `{"query": {"match": {"level": "error"}}, "sort": [{"ts": "desc"}]}`,
+			start: start,
+			end:   end,
+			expected: `{
+				"query": {"bool": {
+					"must": [{"match": {"level": "error"}}],
+					"filter": [{"range": {"ts": {"gte": "2025-01-01T00:00:00Z", "lte": "2025-01-01T01:00:00Z"}}}]
+				}},
+				"sort": [{"ts": "desc"}]
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+
+			body, err := withTimeWindow(tt.body, "ts", tt.start, tt.end)
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			g.Expect(body).To(gomega.MatchJSON(tt.expected))
+		})
+	}
+
+	_, err := withTimeWindow("not json", "ts", start, end)
+	gomega.NewWithT(t).Expect(err).To(gomega.HaveOccurred())
+}
@@ -0,0 +1,134 @@
+package opensearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/timberio/go-datemath"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/logs"
+)
+
+const defaultStreamBatchSize = 500
+
+// Stream follows the index, polling for documents newer than the last one sent.
+func (t *searcher) Stream(ctx context.Context, request StreamRequest) (<-chan logs.StreamItem, error) {
+	if request.Index == "" {
+		return nil, ctx.Oops().Errorf("index is empty")
+	}
+	if request.BatchSize <= 0 {
+		request.BatchSize = defaultStreamBatchSize
+	}
+
+	start := time.Now()
+	if request.Start != "" {
+		var err error
+		if start, err = datemath.ParseAndEvaluate(request.Start, datemath.WithNow(time.Now())); err != nil {
+			return nil, ctx.Oops().Wrapf(err, "invalid start %q", request.Start)
+		}
+	}
+
+	mappingConfig := t.fieldMapping()
+	timestampField := t.timestampField()
+
+	// Invalid queries are returned to the caller instead of being retried
+	if _, err := request.searchBody(timestampField, start, nil); err != nil {
+		return nil, ctx.Oops().Wrap(err)
+	}
+
+	// The position in the index is kept across reconnects
+	var searchAfter []any
+
+	writer := logs.NewStreamWriter(ctx, request.StreamOptions)
+	writer.Go(start, func(cursor *logs.StreamCursor) error {
+		for {
+			hits, err := t.poll(ctx, request, timestampField, start, searchAfter)
+			if err != nil {
+				return err
+			}
+
+			for _, hit := range hits {
+				searchAfter = hit.Sort
+				if !cursor.Send(hitToLogLine(ctx, hit, mappingConfig)) {
+					return nil
+				}
+			}
+
+			if len(hits) < request.BatchSize {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(request.GetPollInterval()):
+				}
+			}
+		}
+	})
+	go writer.Close()
+
+	return writer.Items(), nil
+}
+
+// searchBody returns the search for the documents after searchAfter, oldest first.
+// Documents with the same timestamp are ordered by their id so that none are skipped between pages.
+func (r StreamRequest) searchBody(timestampField string, start time.Time, searchAfter []any) ([]byte, error) {
+	filter := []any{
+		map[string]any{"range": map[string]any{timestampField: map[string]any{"gte": start.UTC().Format(time.RFC3339Nano)}}},
+	}
+	if r.Query != "" {
+		var query map[string]any
+		if err := json.Unmarshal([]byte(r.Query), &query); err != nil {
+			return nil, fmt.Errorf("invalid query: %w", err)
+		}
+		filter = append(filter, query)
+	}
+
+	body := map[string]any{
+		"size":  r.BatchSize,
+		"query": map[string]any{"bool": map[string]any{"filter": filter}},
+		"sort": []any{
+			map[string]any{timestampField: "asc"},
+			map[string]any{"_id": "asc"},
+		},
+	}
+	if len(searchAfter) > 0 {
+		body["search_after"] = searchAfter
+	}
+
+	return json.Marshal(body)
+}
+
+func (t *searcher) poll(ctx context.Context, request StreamRequest, timestampField string, start time.Time, searchAfter []any) ([]SearchHit, error) {
+	body, err := request.searchBody(timestampField, start, searchAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.client.Search(
+		t.client.Search.WithContext(ctx),
+		t.client.Search.WithIndex(request.Index),
+		t.client.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return nil, ctx.Oops().Wrapf(err, "error searching")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, ctx.Oops().Wrapf(err, "failed to read error response body from opensearch")
+		}
+
+		return nil, ctx.Oops().Errorf("opensearch: search failed with status %s: %s", res.Status(), string(body))
+	}
+
+	var r Response
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, ctx.Oops().Wrapf(err, "error parsing the response body")
+	}
+	return r.Hits.Hits, nil
+}
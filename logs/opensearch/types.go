@@ -4,6 +4,7 @@ import (
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/commons/utils"
 
+	"github.com/flanksource/duty/logs"
 	"github.com/flanksource/duty/types"
 )
 
@@ -22,6 +23,27 @@ type Request struct {
 	Limit string `json:"limit,omitempty" template:"true"`
 }
 
+// StreamRequest represents the parameters to follow an index.
+// New documents are polled for with search_after, sorted by their timestamp.
+//
+// +kubebuilder:object:generate=true
+type StreamRequest struct {
+	Index string `json:"index" template:"true"`
+
+	// Query is the query clause of the search, e.g. {"match": {"level": "error"}}.
+	// Unlike Request.Query, it is not a full search body as the stream sets the sort and size.
+	Query string `json:"query,omitempty" template:"true"`
+
+	// Start is the time to follow the index from (default now)
+	// Supports Datemath
+	Start string `json:"start,omitempty"`
+
+	// BatchSize is the maximum number of documents fetched per request (default 500)
+	BatchSize int `json:"batchSize,omitempty"`
+
+	logs.StreamOptions `json:",inline"`
+}
+
 type TotalHitsInfo struct {
 	Value    int64  `json:"value"`
 	Relation string `json:"relation"`
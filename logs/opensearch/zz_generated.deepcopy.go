@@ -47,3 +47,19 @@ func (in *Request) DeepCopy() *Request {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StreamRequest) DeepCopyInto(out *StreamRequest) {
+	*out = *in
+	out.StreamOptions = in.StreamOptions
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StreamRequest.
+func (in *StreamRequest) DeepCopy() *StreamRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(StreamRequest)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -3,6 +3,7 @@ package logs
 import (
 	"encoding/json"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/flanksource/commons/utils"
@@ -31,6 +32,27 @@ var syslogSeverities = []string{
 	"warning", "notice", "info", "debug",
 }
 
+// clf: Apache/nginx common & combined log format
+// host ident user [time] "request" status bytes "referer" "user-agent"
+var clfPattern = regexp.MustCompile(
+	`^(\S+)\s+(\S+)\s+(\S+)\s+` + // host ident user
+		`\[([^\]]+)\]\s+` + // [time]
+		`"((?:[^"\\]|\\.)*)"\s+` + // "request"
+		`(\d{3})\s+(\S+)` + // status bytes
+		`(?:\s+"((?:[^"\\]|\\.)*)"\s+"((?:[^"\\]|\\.)*)")?`) // "referer" "user-agent"
+
+// cef: [syslog header] CEF:Version|Vendor|Product|Version|SignatureID|Name|Severity|Extension
+var cefPattern = regexp.MustCompile(`(?:^|\s)CEF:\d+\|`)
+
+var cefExtensionKey = regexp.MustCompile(`(?:^|\s)([\w.\[\]-]+)=`)
+
+var cefSeverities = map[string]string{
+	"low":       "info",
+	"medium":    "warning",
+	"high":      "error",
+	"very-high": "critical",
+}
+
 func ParseMessage(line *LogLine, format string) {
 	switch format {
 	case "klogfmt":
@@ -41,6 +63,12 @@ func ParseMessage(line *LogLine, format string) {
 		ParseJSON(line)
 	case "syslog":
 		ParseSyslog(line)
+	case "clf", "nginx", "apache":
+		ParseCLF(line)
+	case "cef":
+		ParseCEF(line)
+	case "gelf":
+		ParseGELF(line)
 	case "autodetect", "":
 		ParseAutodetect(line)
 	}
@@ -51,11 +79,20 @@ func DetectFormat(msg string) string {
 		return ""
 	}
 	if msg[0] == '{' {
+		if strings.Contains(msg, `"short_message"`) && isGELF(msg) {
+			return "gelf"
+		}
 		return "json"
 	}
+	if cefPattern.MatchString(msg) {
+		return "cef"
+	}
 	if klogPattern.MatchString(msg) {
 		return "klogfmt"
 	}
+	if clfPattern.MatchString(msg) {
+		return "clf"
+	}
 	if msg[0] == '<' && syslogPattern.MatchString(msg) {
 		return "syslog"
 	}
@@ -245,3 +282,185 @@ func ParseSyslog(line *LogLine) {
 	}
 	line.Message = matches[6]
 }
+
+// ParseCLF parses Apache/nginx access logs in the common or combined log format.
+// The severity is derived from the status code.
+func ParseCLF(line *LogLine) {
+	matches := clfPattern.FindStringSubmatch(line.Message)
+	if matches == nil {
+		return
+	}
+
+	if line.Labels == nil {
+		line.Labels = make(map[string]string)
+	}
+
+	setLabel := func(key, value string) {
+		if value != "" && value != "-" {
+			line.Labels[key] = value
+		}
+	}
+
+	setLabel("remote_addr", matches[1])
+	setLabel("remote_user", matches[3])
+	setLabel("time", matches[4])
+	setLabel("status", matches[6])
+	setLabel("bytes", matches[7])
+	setLabel("referer", matches[8])
+	setLabel("user_agent", matches[9])
+
+	request := matches[5]
+	if parts := strings.Fields(request); len(parts) == 3 {
+		setLabel("method", parts[0])
+		setLabel("path", parts[1])
+		setLabel("protocol", parts[2])
+	}
+
+	switch matches[6][0] {
+	case '5':
+		line.Severity = "error"
+	case '4':
+		line.Severity = "warning"
+	default:
+		line.Severity = "info"
+	}
+	line.Message = request
+}
+
+// ParseCEF parses ArcSight Common Event Format events, optionally prefixed by a syslog header.
+// The name of the event is the message, and the header fields and extensions are labels.
+func ParseCEF(line *LogLine) {
+	loc := cefPattern.FindStringIndex(line.Message)
+	if loc == nil {
+		return
+	}
+
+	start := strings.Index(line.Message[loc[0]:], "CEF:") + loc[0]
+	header := splitCEFHeader(line.Message[start+len("CEF:"):])
+	if len(header) < 7 {
+		return
+	}
+
+	if line.Labels == nil {
+		line.Labels = make(map[string]string)
+	}
+
+	// The hostname is the last field of the syslog header
+	if prefix := strings.Fields(line.Message[:start]); len(prefix) > 0 {
+		line.Host = prefix[len(prefix)-1]
+	}
+
+	line.Labels["cef_version"] = header[0]
+	line.Labels["device_vendor"] = header[1]
+	line.Labels["device_product"] = header[2]
+	line.Labels["device_version"] = header[3]
+	line.Labels["signature_id"] = header[4]
+	line.Labels["cef_severity"] = header[6]
+	line.Source = strings.TrimSpace(header[1] + " " + header[2])
+
+	if sev, err := strconv.Atoi(header[6]); err == nil {
+		switch {
+		case sev <= 3:
+			line.Severity = "info"
+		case sev <= 6:
+			line.Severity = "warning"
+		case sev <= 8:
+			line.Severity = "error"
+		default:
+			line.Severity = "critical"
+		}
+	} else if sev, ok := cefSeverities[strings.ToLower(header[6])]; ok {
+		line.Severity = sev
+	}
+
+	if len(header) > 7 {
+		for k, v := range parseCEFExtension(header[7]) {
+			line.Labels[k] = v
+		}
+	}
+	line.Message = header[5]
+}
+
+// splitCEFHeader splits the 7 header fields on unescaped pipes.
+// The 8th element is the extension.
+func splitCEFHeader(s string) []string {
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && (s[i+1] == '|' || s[i+1] == '\\'):
+			field.WriteByte(s[i+1])
+			i++
+		case s[i] == '|' && len(fields) < 7:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(s[i])
+		}
+	}
+	return append(fields, field.String())
+}
+
+// parseCEFExtension parses the key=value pairs of the extension, whose values may contain spaces.
+func parseCEFExtension(s string) map[string]string {
+	unescape := strings.NewReplacer(`\=`, "=", `\\`, `\`, `\n`, "\n", `\r`, "\r")
+
+	pairs := make(map[string]string)
+	matches := cefExtensionKey.FindAllStringSubmatchIndex(s, -1)
+	for i, m := range matches {
+		end := len(s)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		pairs[s[m[2]:m[3]]] = unescape.Replace(strings.TrimSpace(s[m[1]:end]))
+	}
+	return pairs
+}
+
+// isGELF returns true when the message is a JSON object with a top-level short_message,
+// rather than any JSON that contains a short_message, e.g. in a nested object.
+func isGELF(msg string) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(msg), &fields); err != nil {
+		return false
+	}
+	_, ok := fields["short_message"]
+	return ok
+}
+
+// ParseGELF parses Graylog Extended Log Format messages.
+// Additional fields, prefixed by an underscore, are labels without the underscore.
+func ParseGELF(line *LogLine) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(line.Message), &fields); err != nil {
+		return
+	}
+	if _, ok := fields["short_message"]; !ok {
+		// Not a GELF message, e.g. JSON logs of an app that is configured as GELF
+		ParseJSON(line)
+		return
+	}
+
+	if line.Labels == nil {
+		line.Labels = make(map[string]string)
+	}
+
+	for key, val := range fields {
+		str, _ := utils.Stringify(val)
+		switch key {
+		case "short_message":
+			line.Message = str
+		case "host":
+			line.Host = str
+		case "level":
+			if level, err := strconv.Atoi(str); err == nil && level >= 0 && level < len(syslogSeverities) {
+				line.Severity = syslogSeverities[level]
+			}
+		case "facility", "_logger":
+			line.Source = str
+		case "version", "timestamp":
+		default:
+			line.Labels[strings.TrimPrefix(key, "_")] = str
+		}
+	}
+}
@@ -95,3 +95,15 @@ func TestEffectiveMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCLF(t *testing.T) {
+	runParseFixture(t, "testdata/parse_clf.yaml", ParseCLF)
+}
+
+func TestParseCEF(t *testing.T) {
+	runParseFixture(t, "testdata/parse_cef.yaml", ParseCEF)
+}
+
+func TestParseGELF(t *testing.T) {
+	runParseFixture(t, "testdata/parse_gelf.yaml", ParseGELF)
+}
@@ -0,0 +1,409 @@
+package logs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// PatternWildcard replaces the variable tokens of a pattern's template
+const PatternWildcard = "<*>"
+
+const (
+	// PatternNew is a pattern that is not in the baseline window
+	PatternNew = "new"
+
+	// PatternSpiking is a pattern whose frequency increased by at least the spike ratio
+	PatternSpiking = "spiking"
+)
+
+// PatternMinerConfig configures the Drain template miner.
+type PatternMinerConfig struct {
+	// Depth of the parse tree. The first Depth-2 tokens of a message select its leaf (default 4)
+	Depth int `json:"depth,omitempty" yaml:"depth,omitempty"`
+
+	// SimilarityThreshold is the fraction of tokens a message must share with a pattern to match it (default 0.4)
+	SimilarityThreshold float64 `json:"similarityThreshold,omitempty" yaml:"similarityThreshold,omitempty"`
+
+	// MaxChildren of a tree node, beyond which tokens are routed to the wildcard node (default 100)
+	MaxChildren int `json:"maxChildren,omitempty" yaml:"maxChildren,omitempty"`
+
+	// MaxExamples is the number of example lines kept per pattern (default 3)
+	MaxExamples int `json:"maxExamples,omitempty" yaml:"maxExamples,omitempty"`
+
+	// MessageFields are the labels to use as the message of lines without one
+	MessageFields []string `json:"messageFields,omitempty" yaml:"messageFields,omitempty"`
+}
+
+func (c PatternMinerConfig) withDefaults() PatternMinerConfig {
+	if c.Depth < 3 {
+		c.Depth = 4
+	}
+	if c.SimilarityThreshold <= 0 {
+		c.SimilarityThreshold = 0.4
+	}
+	if c.MaxChildren <= 0 {
+		c.MaxChildren = 100
+	}
+	if c.MaxExamples <= 0 {
+		c.MaxExamples = 3
+	}
+	return c
+}
+
+// LogPattern is a template of similar log messages, with the variable tokens replaced by PatternWildcard.
+type LogPattern struct {
+	// ID is derived from the template, so the same pattern mined from different windows has the same ID
+	ID            string         `json:"id"`
+	Template      string         `json:"template"`
+	Count         int            `json:"count"`
+	FirstObserved time.Time      `json:"firstObserved,omitempty"`
+	LastObserved  time.Time      `json:"lastObserved,omitempty"`
+	Severities    map[string]int `json:"severities,omitempty"`
+	Examples      []*LogLine     `json:"examples,omitempty"`
+
+	tokens []string
+}
+
+func (p *LogPattern) add(line *LogLine, maxExamples int) {
+	p.Count++
+	if p.FirstObserved.IsZero() || line.FirstObserved.Before(p.FirstObserved) {
+		p.FirstObserved = line.FirstObserved
+	}
+	if line.FirstObserved.After(p.LastObserved) {
+		p.LastObserved = line.FirstObserved
+	}
+	if line.Severity != "" {
+		if p.Severities == nil {
+			p.Severities = map[string]int{}
+		}
+		p.Severities[line.Severity]++
+	}
+	if len(p.Examples) < maxExamples {
+		p.Examples = append(p.Examples, line)
+	}
+}
+
+func (p *LogPattern) setTemplate(tokens []string) {
+	p.tokens = tokens
+	p.Template = strings.Join(tokens, " ")
+	hash := sha256.Sum256([]byte(p.Template))
+	p.ID = hex.EncodeToString(hash[:8])
+}
+
+type patternNode struct {
+	children map[string]*patternNode
+	patterns []*LogPattern
+}
+
+// PatternMiner clusters log messages into patterns with the Drain algorithm,
+// see https://jiemingzhu.github.io/pub/pjhe_icws2017.pdf
+//
+// Messages are split into tokens on whitespace and tokens with digits are treated as variables.
+// Messages with the same number of tokens and the same leading tokens are matched against the same patterns.
+// A PatternMiner is not safe for concurrent use.
+type PatternMiner struct {
+	config   PatternMinerConfig
+	root     *patternNode
+	patterns []*LogPattern
+}
+
+func NewPatternMiner(config PatternMinerConfig) *PatternMiner {
+	return &PatternMiner{
+		config: config.withDefaults(),
+		root:   &patternNode{children: map[string]*patternNode{}},
+	}
+}
+
+// Add adds the line to the pattern it matches, or to a new pattern, and returns the pattern.
+func (m *PatternMiner) Add(line *LogLine) *LogPattern {
+	tokens := patternTokens(line.EffectiveMessage(m.config.MessageFields...))
+	leaf := m.leaf(tokens)
+
+	pattern := m.match(leaf.patterns, tokens)
+	if pattern == nil {
+		pattern = &LogPattern{}
+		pattern.setTemplate(tokens)
+		leaf.patterns = append(leaf.patterns, pattern)
+		m.patterns = append(m.patterns, pattern)
+	} else if merged := mergeTemplate(pattern.tokens, tokens); merged != nil {
+		pattern.setTemplate(merged)
+	}
+
+	pattern.add(line, m.config.MaxExamples)
+	return pattern
+}
+
+// Patterns returns the patterns mined so far, most frequent first.
+func (m *PatternMiner) Patterns() []*LogPattern {
+	patterns := slices.Clone(m.patterns)
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return patterns[i].Count > patterns[j].Count
+	})
+	return patterns
+}
+
+// MinePatterns clusters the lines into patterns, most frequent first.
+func MinePatterns(lines []*LogLine, config PatternMinerConfig) []*LogPattern {
+	miner := NewPatternMiner(config)
+	for _, line := range lines {
+		miner.Add(line)
+	}
+	return miner.Patterns()
+}
+
+// find returns the most specific pattern whose template the line fits, without adding the line to it.
+func (m *PatternMiner) find(line *LogLine) *LogPattern {
+	tokens := patternTokens(line.EffectiveMessage(m.config.MessageFields...))
+
+	// The same path as leaf(), without creating nodes
+	node, ok := m.root.children[strconv.Itoa(len(tokens))]
+	for i := 0; ok && i < m.config.Depth-2 && i < len(tokens); i++ {
+		parent := node
+		if node, ok = parent.children[tokens[i]]; !ok && len(parent.children) >= m.config.MaxChildren {
+			node, ok = parent.children[PatternWildcard]
+		}
+	}
+	if !ok {
+		return nil
+	}
+
+	var best *LogPattern
+	bestWildcards := -1
+	for _, pattern := range node.patterns {
+		// The line fits the templates that it would not generalize
+		if mergeTemplate(pattern.tokens, tokens) != nil {
+			continue
+		}
+		if _, wildcards := patternSimilarity(pattern.tokens, tokens); best == nil || wildcards < bestWildcards {
+			best, bestWildcards = pattern, wildcards
+		}
+	}
+	return best
+}
+
+// leaf returns the node of the messages with the same length and leading tokens, creating it if needed.
+func (m *PatternMiner) leaf(tokens []string) *patternNode {
+	node := m.root.child(strconv.Itoa(len(tokens)))
+	for i := 0; i < m.config.Depth-2 && i < len(tokens); i++ {
+		token := tokens[i]
+		if _, ok := node.children[token]; !ok && len(node.children) >= m.config.MaxChildren {
+			token = PatternWildcard
+		}
+		node = node.child(token)
+	}
+	return node
+}
+
+func (n *patternNode) child(token string) *patternNode {
+	if child, ok := n.children[token]; ok {
+		return child
+	}
+	child := &patternNode{children: map[string]*patternNode{}}
+	n.children[token] = child
+	return child
+}
+
+// match returns the most similar pattern, preferring the pattern with the most wildcards on ties.
+func (m *PatternMiner) match(patterns []*LogPattern, tokens []string) *LogPattern {
+	var best *LogPattern
+	bestSimilarity, bestWildcards := -1.0, -1
+	for _, pattern := range patterns {
+		similarity, wildcards := patternSimilarity(pattern.tokens, tokens)
+		if similarity > bestSimilarity || (similarity == bestSimilarity && wildcards > bestWildcards) {
+			best, bestSimilarity, bestWildcards = pattern, similarity, wildcards
+		}
+	}
+
+	if best == nil || bestSimilarity < m.config.SimilarityThreshold {
+		return nil
+	}
+	return best
+}
+
+func patternSimilarity(template, tokens []string) (float64, int) {
+	if len(template) == 0 {
+		return 1, 0
+	}
+
+	var same, wildcards int
+	for i, token := range template {
+		if token == PatternWildcard {
+			wildcards++
+		} else if token == tokens[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(template)), wildcards
+}
+
+// mergeTemplate replaces the tokens of the template that differ from the message with wildcards.
+// It returns nil when the template is unchanged.
+func mergeTemplate(template, tokens []string) []string {
+	var merged []string
+	for i, token := range template {
+		if token != PatternWildcard && token != tokens[i] {
+			if merged == nil {
+				merged = slices.Clone(template)
+			}
+			merged[i] = PatternWildcard
+		}
+	}
+	return merged
+}
+
+func patternTokens(message string) []string {
+	tokens := strings.Fields(message)
+	for i, token := range tokens {
+		if strings.ContainsFunc(token, unicode.IsDigit) {
+			tokens[i] = PatternWildcard
+		}
+	}
+	return tokens
+}
+
+// PatternWindow is the lines of a time window to diff patterns over.
+type PatternWindow struct {
+	// Start and End of the window, derived from the lines when not set
+	Start time.Time  `json:"start,omitempty"`
+	End   time.Time  `json:"end,omitempty"`
+	Lines []*LogLine `json:"lines,omitempty"`
+}
+
+func (w PatternWindow) duration() time.Duration {
+	start, end := w.Start, w.End
+	for _, line := range w.Lines {
+		if w.Start.IsZero() && (start.IsZero() || line.FirstObserved.Before(start)) {
+			start = line.FirstObserved
+		}
+		if w.End.IsZero() && line.FirstObserved.After(end) {
+			end = line.FirstObserved
+		}
+	}
+	return end.Sub(start)
+}
+
+// PatternDiffConfig configures DiffPatterns.
+type PatternDiffConfig struct {
+	PatternMinerConfig `json:",inline" yaml:",inline"`
+
+	// SpikeRatio is the increase in the rate of a pattern to flag it as spiking (default 3)
+	SpikeRatio float64 `json:"spikeRatio,omitempty" yaml:"spikeRatio,omitempty"`
+
+	// MinCount is the number of lines a pattern needs in the current window to be flagged (default 1)
+	MinCount int `json:"minCount,omitempty" yaml:"minCount,omitempty"`
+
+	// Severities only diffs lines with these severities, e.g. error
+	Severities []string `json:"severities,omitempty" yaml:"severities,omitempty"`
+}
+
+// PatternChange is a pattern whose frequency changed between the baseline and current window.
+type PatternChange struct {
+	// Status is PatternNew or PatternSpiking
+	Status  string      `json:"status"`
+	Pattern *LogPattern `json:"pattern"`
+
+	// Baseline and Current are the number of lines of the pattern in each window
+	Baseline int `json:"baseline"`
+	Current  int `json:"current"`
+
+	// Ratio of the rate of the pattern in the current window to its rate in the baseline window.
+	// It is 0 for new patterns.
+	Ratio float64 `json:"ratio,omitempty"`
+}
+
+// DiffPatterns returns the patterns of the current window that are new or spiking compared to the baseline window,
+// e.g. the new error patterns since a deploy.
+//
+// The templates mined from the baseline are frozen: a current line only counts toward a baseline pattern
+// when it fits its template, and the other current lines are mined separately into new patterns. So a new
+// message is reported even when it is similar to a baseline pattern, at the cost of reporting variants of a
+// baseline message that the baseline had too few lines to generalize, e.g. a baseline with a single line.
+//
+// Rates are per unit of time when both windows have a duration, so windows of different lengths can be compared.
+// The new patterns are returned first, then the spiking ones, most frequent first.
+func DiffPatterns(baseline, current PatternWindow, config PatternDiffConfig) []PatternChange {
+	if config.SpikeRatio <= 0 {
+		config.SpikeRatio = 3
+	}
+	if config.MinCount <= 0 {
+		config.MinCount = 1
+	}
+
+	include := func(line *LogLine) bool {
+		return len(config.Severities) == 0 || slices.ContainsFunc(config.Severities, func(s string) bool {
+			return strings.EqualFold(s, line.Severity)
+		})
+	}
+
+	baselineMiner := NewPatternMiner(config.PatternMinerConfig)
+	baselineCounts := map[*LogPattern]int{}
+	for _, line := range baseline.Lines {
+		if include(line) {
+			baselineCounts[baselineMiner.Add(line)]++
+		}
+	}
+
+	// The lines of each pattern are summarized separately, so the pattern's examples and times are of the current window
+	miner := NewPatternMiner(config.PatternMinerConfig)
+	currentCounts := map[*LogPattern]int{}
+	currentPatterns := map[*LogPattern]*LogPattern{}
+	var order []*LogPattern
+	for _, line := range current.Lines {
+		if !include(line) {
+			continue
+		}
+		pattern := baselineMiner.find(line)
+		if pattern == nil {
+			pattern = miner.Add(line)
+		}
+		if _, ok := currentPatterns[pattern]; !ok {
+			currentPatterns[pattern] = &LogPattern{}
+			order = append(order, pattern)
+		}
+		currentPatterns[pattern].add(line, miner.config.MaxExamples)
+		currentCounts[pattern]++
+	}
+
+	scale := 1.0
+	if b, c := baseline.duration(), current.duration(); b > 0 && c > 0 {
+		scale = float64(b) / float64(c)
+	}
+
+	var changes []PatternChange
+	for _, pattern := range order {
+		count := currentCounts[pattern]
+		if count < config.MinCount {
+			continue
+		}
+
+		// The template of a new pattern may have been generalized by later lines
+		summary := currentPatterns[pattern]
+		summary.ID, summary.Template, summary.tokens = pattern.ID, pattern.Template, pattern.tokens
+
+		change := PatternChange{Pattern: summary, Baseline: baselineCounts[pattern], Current: count}
+		if change.Baseline == 0 {
+			change.Status = PatternNew
+		} else {
+			change.Ratio = float64(count) * scale / float64(change.Baseline)
+			if change.Ratio < config.SpikeRatio {
+				continue
+			}
+			change.Status = PatternSpiking
+		}
+		changes = append(changes, change)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Status != changes[j].Status {
+			return changes[i].Status == PatternNew
+		}
+		return changes[i].Current > changes[j].Current
+	})
+	return changes
+}
@@ -0,0 +1,116 @@
+package logs
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+type patternCase struct {
+	Name     string             `yaml:"name"`
+	Config   PatternMinerConfig `yaml:"config"`
+	Lines    []string           `yaml:"lines"`
+	Expected []struct {
+		Template string `yaml:"template"`
+		Count    int    `yaml:"count"`
+		Examples int    `yaml:"examples"`
+	} `yaml:"expected"`
+}
+
+type patternFixture struct {
+	Cases []patternCase `yaml:"cases"`
+}
+
+func TestMinePatterns(t *testing.T) {
+	data, err := os.ReadFile("testdata/patterns.yaml")
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	var fixture patternFixture
+	if err := yaml.Unmarshal(data, &fixture); err != nil {
+		t.Fatalf("parsing fixture: %v", err)
+	}
+
+	for _, tc := range fixture.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+
+			var lines []*LogLine
+			for _, message := range tc.Lines {
+				lines = append(lines, &LogLine{Message: message})
+			}
+
+			patterns := MinePatterns(lines, tc.Config)
+			g.Expect(patterns).To(gomega.HaveLen(len(tc.Expected)))
+			for i, expected := range tc.Expected {
+				g.Expect(patterns[i].Template).To(gomega.Equal(expected.Template), "patterns[%d].template", i)
+				g.Expect(patterns[i].Count).To(gomega.Equal(expected.Count), "patterns[%d].count", i)
+				if expected.Examples > 0 {
+					g.Expect(patterns[i].Examples).To(gomega.HaveLen(expected.Examples), "patterns[%d].examples", i)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffPatterns(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	deploy := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	window := func(start time.Time, messages map[string]int) PatternWindow {
+		w := PatternWindow{Start: start, End: start.Add(time.Hour)}
+		i := 0
+		for message, count := range messages {
+			for j := range count {
+				w.Lines = append(w.Lines, &LogLine{
+					FirstObserved: start.Add(time.Duration(i) * time.Second),
+					Message:       fmt.Sprintf(message, j),
+					Severity:      "error",
+				})
+				i++
+			}
+		}
+		return w
+	}
+
+	baseline := window(deploy.Add(-time.Hour), map[string]int{
+		"request %d timed out":                           2,
+		"cache miss for key user-%d":                     10,
+		"failed to connect to database after %d retries": 3,
+	})
+	current := window(deploy, map[string]int{
+		"request %d timed out":                  8,
+		"cache miss for key user-%d":            11,
+		"nil pointer dereference in handler %d": 2,
+		// Similar to a baseline pattern, but new
+		"failed to connect to cache after %d retries": 1,
+	})
+	current.Lines = append(current.Lines, &LogLine{FirstObserved: deploy, Message: "debug line 1", Severity: "debug"})
+
+	changes := DiffPatterns(baseline, current, PatternDiffConfig{Severities: []string{"ERROR"}})
+	g.Expect(changes).To(gomega.HaveLen(3))
+
+	g.Expect(changes[0].Status).To(gomega.Equal(PatternNew))
+	g.Expect(changes[0].Pattern.Template).To(gomega.Equal("nil pointer dereference in handler <*>"))
+	g.Expect(changes[0].Current).To(gomega.Equal(2))
+	g.Expect(changes[0].Pattern.Examples).To(gomega.HaveLen(2))
+	g.Expect(changes[0].Pattern.FirstObserved.Before(deploy)).To(gomega.BeFalse())
+
+	g.Expect(changes[1].Status).To(gomega.Equal(PatternNew))
+	g.Expect(changes[1].Pattern.Template).To(gomega.Equal("failed to connect to cache after <*> retries"))
+	g.Expect(changes[1].Current).To(gomega.Equal(1))
+
+	g.Expect(changes[2].Status).To(gomega.Equal(PatternSpiking))
+	g.Expect(changes[2].Pattern.Template).To(gomega.Equal("request <*> timed out"))
+	g.Expect(changes[2].Baseline).To(gomega.Equal(2))
+	g.Expect(changes[2].Current).To(gomega.Equal(8))
+	g.Expect(changes[2].Ratio).To(gomega.Equal(4.0))
+
+	// The same pattern has the same ID in both windows
+	baselinePatterns := MinePatterns(baseline.Lines, PatternMinerConfig{})
+	g.Expect(baselinePatterns).To(gomega.ContainElement(gomega.HaveField("ID", changes[2].Pattern.ID)))
+}
@@ -0,0 +1,302 @@
+package logs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/flanksource/duty/context"
+)
+
+// SearchRequest is a log search accepted by every registered Searcher.
+type SearchRequest struct {
+	LogsRequestBase `json:",inline" template:"true"`
+
+	// Query in the language of the backend,
+	// e.g. LogQL for loki, KQL for azure or a filter for google cloud logging.
+	Query string `json:"query,omitempty" template:"true"`
+
+	// Params are backend specific parameters,
+	// e.g. index for opensearch, logGroup for cloudwatch or kind, namespace & name for kubernetes.
+	Params map[string]string `json:"params,omitempty" template:"true"`
+}
+
+// Searcher searches the logs of a single backend.
+type Searcher interface {
+	Search(ctx context.Context, request SearchRequest) (*LogResult, error)
+}
+
+// SearcherFunc adapts a function to a Searcher.
+type SearcherFunc func(ctx context.Context, request SearchRequest) (*LogResult, error)
+
+func (f SearcherFunc) Search(ctx context.Context, request SearchRequest) (*LogResult, error) {
+	return f(ctx, request)
+}
+
+// SearcherFactory creates a Searcher for a connection, given by name or as a connection:// URL.
+type SearcherFactory func(ctx context.Context, connection string, mappingConfig *FieldMappingConfig) (Searcher, error)
+
+var (
+	searchersLock sync.RWMutex
+	searchers     = map[string]SearcherFactory{}
+)
+
+// RegisterSearcher registers the factory of the searcher of a connection type.
+// Backends register themselves when their package is imported.
+func RegisterSearcher(connectionType string, factory SearcherFactory) {
+	searchersLock.Lock()
+	defer searchersLock.Unlock()
+	searchers[connectionType] = factory
+}
+
+// RegisteredSearchers returns the connection types that have a searcher.
+func RegisteredSearchers() []string {
+	searchersLock.RLock()
+	defer searchersLock.RUnlock()
+
+	var types []string
+	for t := range searchers {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// NewSearcher creates the searcher registered for the connection type.
+func NewSearcher(ctx context.Context, connectionType, connection string, mappingConfig *FieldMappingConfig) (Searcher, error) {
+	searchersLock.RLock()
+	factory, ok := searchers[connectionType]
+	searchersLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no log searcher registered for connection type %q", connectionType)
+	}
+	return factory(ctx, connection, mappingConfig)
+}
+
+// windowTimeFormat is the most precise format datemath parses
+const windowTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// SearchBackend is one of the backends searched by FanOutSearch.
+type SearchBackend struct {
+	// Name identifies the backend in the result. Defaults to <type>/<connection>.
+	Name string `json:"name,omitempty"`
+
+	// Type is the connection type of the registered searcher, e.g. loki, opensearch or kubernetes.
+	Type string `json:"type"`
+
+	// Connection name or connection:// URL of the backend
+	Connection string `json:"connection,omitempty"`
+
+	// Request to the backend. The start & end are replaced by the time window of the search.
+	Request SearchRequest `json:"request"`
+
+	Mapping *FieldMappingConfig `json:"mapping,omitempty"`
+}
+
+func (b SearchBackend) GetName() string {
+	if b.Name != "" {
+		return b.Name
+	}
+	return b.Type + "/" + b.Connection
+}
+
+// SearchBackendResult summarizes the search of one backend in the metadata of a fan-out search.
+type SearchBackendResult struct {
+	Type     string         `json:"type"`
+	Count    int            `json:"count"`
+	Error    string         `json:"error,omitempty"`
+	Duration time.Duration  `json:"duration"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+
+	// Truncated is set when the backend returned as many lines as its limit,
+	// so it may have more lines in the window.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// FanOutSearch searches the backends in parallel over the same time window
+// and merges their logs into a single result sorted by time.
+//
+// Lines with the same timestamp and message, e.g. the same event shipped to two backends, are returned once.
+// Lines outside the window are dropped, for backends that don't filter by time.
+// When the window has a limit, the most recent lines are returned.
+//
+// The result metadata has a summary of every backend under "backends",
+// and the errors of the backends that failed under "errors".
+// "truncated" is set when a backend hit its limit or the merged lines were cut to the limit.
+// An error is only returned when all the backends fail.
+func FanOutSearch(ctx context.Context, window LogsRequestBase, backends []SearchBackend) (*LogResult, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no log backends to search")
+	}
+
+	// The window is resolved once, so relative times like now-1h are the same for every backend
+	var start, end time.Time
+	var err error
+	if window.Start != "" {
+		if start, err = window.GetStart(); err != nil {
+			return nil, fmt.Errorf("invalid start %q: %w", window.Start, err)
+		}
+		start = start.Truncate(time.Millisecond)
+		window.Start = start.Format(windowTimeFormat)
+	}
+	if window.End != "" {
+		if end, err = window.GetEnd(); err != nil {
+			return nil, fmt.Errorf("invalid end %q: %w", window.End, err)
+		}
+		end = end.Truncate(time.Millisecond)
+		window.End = end.Format(windowTimeFormat)
+	}
+
+	var limit int
+	if window.Limit != "" {
+		if limit, err = strconv.Atoi(window.Limit); err != nil {
+			return nil, fmt.Errorf("invalid limit %q: %w", window.Limit, err)
+		}
+	}
+
+	results := make([]*LogResult, len(backends))
+	summaries := make([]SearchBackendResult, len(backends))
+
+	var wg sync.WaitGroup
+	for i, backend := range backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			started := time.Now()
+			result, err := searchBackend(ctx, backend, window)
+			summaries[i] = SearchBackendResult{Type: backend.Type, Duration: time.Since(started)}
+			if err != nil {
+				summaries[i].Error = err.Error()
+				return
+			}
+			results[i] = result
+			summaries[i].Metadata = result.Metadata
+			if backendLimit, _ := strconv.Atoi(backendRequest(backend, window).Limit); backendLimit > 0 {
+				summaries[i].Truncated = len(result.Lines()) >= backendLimit
+			}
+		}()
+	}
+	wg.Wait()
+
+	merged := &LogResult{Metadata: map[string]any{}}
+	backendSummaries := map[string]SearchBackendResult{}
+	backendErrors := map[string]string{}
+	var errs []error
+	var truncated bool
+	seen := map[string]struct{}{}
+
+	for i, backend := range backends {
+		name := backend.GetName()
+		if summaries[i].Error != "" {
+			backendErrors[name] = summaries[i].Error
+			errs = append(errs, fmt.Errorf("%s: %s", name, summaries[i].Error))
+			backendSummaries[name] = summaries[i]
+			continue
+		}
+
+		for _, line := range results[i].Lines() {
+			if !start.IsZero() && line.FirstObserved.Before(start) {
+				continue
+			}
+			if !end.IsZero() && line.FirstObserved.After(end) {
+				continue
+			}
+
+			key := strconv.FormatInt(line.FirstObserved.UnixNano(), 10) + "\x00" + line.EffectiveMessage()
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			merged.Logs = append(merged.Logs, line)
+			summaries[i].Count++
+		}
+		truncated = truncated || summaries[i].Truncated
+		backendSummaries[name] = summaries[i]
+	}
+
+	if len(errs) == len(backends) {
+		return nil, fmt.Errorf("all log backends failed: %w", errors.Join(errs...))
+	}
+
+	sort.SliceStable(merged.Logs, func(i, j int) bool {
+		return merged.Logs[i].FirstObserved.Before(merged.Logs[j].FirstObserved)
+	})
+	if limit > 0 && len(merged.Logs) > limit {
+		merged.Logs = merged.Logs[len(merged.Logs)-limit:]
+		truncated = true
+	}
+
+	merged.Metadata["backends"] = backendSummaries
+	if truncated {
+		merged.Metadata["truncated"] = true
+	}
+	if len(backendErrors) > 0 {
+		merged.Metadata["errors"] = backendErrors
+	}
+	return merged, nil
+}
+
+func searchBackend(ctx context.Context, backend SearchBackend, window LogsRequestBase) (result *LogResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	searcher, err := NewSearcher(ctx, backend.Type, backend.Connection, backend.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := searcher.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	result, err = searcher.Search(ctx, backendRequest(backend, window))
+	if err != nil {
+		return nil, err
+	} else if result == nil {
+		return &LogResult{}, nil
+	}
+	return result, nil
+}
+
+// backendRequest is the request of the backend over the window.
+// The limit of the backend takes precedence over the limit of the window.
+func backendRequest(backend SearchBackend, window LogsRequestBase) SearchRequest {
+	request := backend.Request
+	request.Start = window.Start
+	request.End = window.End
+	if request.Limit == "" {
+		request.Limit = window.Limit
+	}
+	return request
+}
+
+// Lines returns the logs of the result, including the logs of its groups.
+// The labels of a group are added back to its lines.
+func (r *LogResult) Lines() []*LogLine {
+	lines := slices.Clone(r.Logs)
+	for _, group := range r.Groups {
+		for _, line := range group.Logs {
+			if len(group.Labels) > 0 {
+				labels := make(map[string]string, len(line.Labels)+len(group.Labels))
+				for k, v := range group.Labels {
+					labels[k] = v
+				}
+				for k, v := range line.Labels {
+					labels[k] = v
+				}
+				line.Labels = labels
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,97 @@
+package logs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+
+	"github.com/flanksource/duty/context"
+)
+
+func staticSearcher(lines ...*LogLine) SearcherFactory {
+	return func(ctx context.Context, connection string, _ *FieldMappingConfig) (Searcher, error) {
+		return SearcherFunc(func(ctx context.Context, request SearchRequest) (*LogResult, error) {
+			return &LogResult{Logs: lines, Metadata: map[string]any{"connection": connection, "start": request.Start}}, nil
+		}), nil
+	}
+}
+
+func TestFanOutSearch(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx := context.New()
+
+	base := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+
+	RegisterSearcher("test-a", staticSearcher(
+		&LogLine{FirstObserved: at(3), Message: "a3"},
+		&LogLine{FirstObserved: at(1), Message: "shared"},
+		&LogLine{FirstObserved: at(-30), Message: "before window"},
+	))
+	RegisterSearcher("test-b", staticSearcher(
+		&LogLine{FirstObserved: at(1), Message: "shared"},
+		&LogLine{FirstObserved: at(2), Message: "b2"},
+	))
+	RegisterSearcher("test-grouped", func(ctx context.Context, connection string, _ *FieldMappingConfig) (Searcher, error) {
+		return SearcherFunc(func(ctx context.Context, request SearchRequest) (*LogResult, error) {
+			return &LogResult{Groups: []*LogGroup{{
+				Labels: map[string]string{"app": "web"},
+				Logs:   []*LogLine{{FirstObserved: at(4), Message: "c4", Labels: map[string]string{"pod": "web-1"}}},
+			}}}, nil
+		}), nil
+	})
+	RegisterSearcher("test-failing", func(ctx context.Context, connection string, _ *FieldMappingConfig) (Searcher, error) {
+		return nil, fmt.Errorf("connection refused")
+	})
+
+	window := LogsRequestBase{Start: base.Format(time.RFC3339), End: at(10).Format(time.RFC3339)}
+
+	t.Run("merges, sorts and dedupes", func(t *testing.T) {
+		result, err := FanOutSearch(ctx, window, []SearchBackend{
+			{Type: "test-a", Connection: "a"},
+			{Type: "test-b", Connection: "b"},
+			{Type: "test-grouped", Name: "grouped"},
+			{Type: "test-failing", Name: "failing"},
+		})
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+
+		var messages []string
+		for _, line := range result.Logs {
+			messages = append(messages, line.Message)
+		}
+		g.Expect(messages).To(gomega.Equal([]string{"shared", "b2", "a3", "c4"}))
+		g.Expect(result.Logs[3].Labels).To(gomega.Equal(map[string]string{"app": "web", "pod": "web-1"}))
+
+		backends := result.Metadata["backends"].(map[string]SearchBackendResult)
+		g.Expect(backends["test-a/a"].Count).To(gomega.Equal(2))
+		g.Expect(backends["test-a/a"].Metadata["start"]).To(gomega.Equal("2026-01-01T10:00:00.000Z"))
+		g.Expect(backends["test-b/b"].Count).To(gomega.Equal(1))
+		g.Expect(backends["grouped"].Count).To(gomega.Equal(1))
+		g.Expect(result.Metadata["errors"]).To(gomega.Equal(map[string]string{"failing": "connection refused"}))
+		g.Expect(result.Metadata).ToNot(gomega.HaveKey("truncated"))
+	})
+
+	t.Run("limit returns the most recent lines", func(t *testing.T) {
+		limited := window
+		limited.Limit = "2"
+		result, err := FanOutSearch(ctx, limited, []SearchBackend{{Type: "test-a"}, {Type: "test-b"}})
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(result.Logs).To(gomega.HaveLen(2))
+		g.Expect(result.Logs[0].Message).To(gomega.Equal("b2"))
+		g.Expect(result.Logs[1].Message).To(gomega.Equal("a3"))
+		g.Expect(result.Metadata["truncated"]).To(gomega.BeTrue())
+
+		backends := result.Metadata["backends"].(map[string]SearchBackendResult)
+		g.Expect(backends["test-a/"].Truncated).To(gomega.BeTrue())
+		g.Expect(backends["test-b/"].Truncated).To(gomega.BeTrue())
+	})
+
+	t.Run("fails when every backend fails", func(t *testing.T) {
+		_, err := FanOutSearch(ctx, window, []SearchBackend{{Type: "test-failing"}, {Type: "unregistered"}})
+		g.Expect(err).To(gomega.HaveOccurred())
+		g.Expect(err.Error()).To(gomega.ContainSubstring("connection refused"))
+		g.Expect(err.Error()).To(gomega.ContainSubstring(`no log searcher registered for connection type "unregistered"`))
+	})
+}
@@ -0,0 +1,305 @@
+package logs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/flanksource/duty/context"
+)
+
+const (
+	defaultStreamBufferSize    = 100
+	defaultStreamMaxReconnects = 5
+	defaultStreamPollInterval  = 2 * time.Second
+	maxStreamReconnectBackoff  = 30 * time.Second
+)
+
+const (
+	// DroppedBackpressure are lines dropped because the consumer did not keep up with the stream
+	DroppedBackpressure = "backpressure"
+
+	// DroppedBackend are lines the backend did not deliver, e.g. loki's dropped_entries
+	DroppedBackend = "backend"
+)
+
+// StreamOptions configure how a log stream is delivered.
+type StreamOptions struct {
+	// BufferSize is the number of items buffered for the consumer (default 100)
+	BufferSize int `json:"bufferSize,omitempty"`
+
+	// DropOnFull drops lines instead of blocking the backend when the buffer is full.
+	// The dropped lines are reported as a DroppedEntry once the consumer catches up.
+	DropOnFull bool `json:"dropOnFull,omitempty"`
+
+	// MaxReconnects is the number of consecutive failed reconnects after which the stream ends (default 5).
+	// A negative value disables reconnects.
+	MaxReconnects int `json:"maxReconnects,omitempty"`
+
+	// PollInterval is how often backends without a native tail poll for new lines (default 2s)
+	PollInterval string `json:"pollInterval,omitempty"`
+}
+
+func (o StreamOptions) GetPollInterval() time.Duration {
+	if o.PollInterval == "" {
+		return defaultStreamPollInterval
+	}
+	if d, err := time.ParseDuration(o.PollInterval); err == nil && d > 0 {
+		return d
+	}
+	return defaultStreamPollInterval
+}
+
+// DroppedEntry reports lines of a stream that were not delivered.
+type DroppedEntry struct {
+	Count int `json:"count"`
+
+	// Timestamp of the first dropped line, if known
+	Timestamp time.Time `json:"timestamp,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Reason is DroppedBackpressure or DroppedBackend
+	Reason string `json:"reason"`
+}
+
+// StreamItem is a line, dropped lines or an error of a log stream.
+// The stream continues after an error while it reconnects and ends when the channel is closed.
+type StreamItem struct {
+	LogLine *LogLine
+	Dropped *DroppedEntry
+	Error   error
+}
+
+// StreamWriter delivers the items of a stream to its consumer,
+// applying the same backpressure and reconnect behaviour to every backend.
+type StreamWriter struct {
+	ctx   context.Context
+	opts  StreamOptions
+	items chan StreamItem
+
+	lock    sync.Mutex
+	dropped map[string]*DroppedEntry
+	wg      sync.WaitGroup
+}
+
+func NewStreamWriter(ctx context.Context, opts StreamOptions) *StreamWriter {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultStreamBufferSize
+	}
+	if opts.MaxReconnects == 0 {
+		opts.MaxReconnects = defaultStreamMaxReconnects
+	}
+
+	return &StreamWriter{
+		ctx:   ctx,
+		opts:  opts,
+		items: make(chan StreamItem, opts.BufferSize),
+	}
+}
+
+// Items returns the channel the consumer reads the stream from.
+func (w *StreamWriter) Items() <-chan StreamItem {
+	return w.items
+}
+
+// Send delivers a line. It returns false once the stream's context is done.
+func (w *StreamWriter) Send(line *LogLine) bool {
+	return w.deliver(StreamItem{LogLine: line})
+}
+
+// Drop reports lines that the backend did not deliver.
+func (w *StreamWriter) Drop(entry DroppedEntry) bool {
+	return w.deliver(StreamItem{Dropped: &entry})
+}
+
+// Error reports an error to the consumer. Errors are never dropped.
+func (w *StreamWriter) Error(err error) bool {
+	return w.send(StreamItem{Error: err})
+}
+
+func (w *StreamWriter) send(item StreamItem) bool {
+	select {
+	case w.items <- item:
+		return true
+	case <-w.ctx.Done():
+		return false
+	}
+}
+
+func (w *StreamWriter) deliver(item StreamItem) bool {
+	if w.ctx.Err() != nil {
+		return false
+	}
+	if !w.opts.DropOnFull {
+		return w.send(item)
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.flushDropped() {
+		select {
+		case w.items <- item:
+			return true
+		default:
+		}
+	}
+
+	if item.Dropped != nil {
+		w.addDropped(item.Dropped.Reason, item.Dropped.Count, item.Dropped.Timestamp)
+	} else {
+		w.addDropped(DroppedBackpressure, 1, item.LogLine.FirstObserved)
+	}
+	return true
+}
+
+func (w *StreamWriter) addDropped(reason string, count int, timestamp time.Time) {
+	if w.dropped == nil {
+		w.dropped = map[string]*DroppedEntry{}
+	}
+	entry, ok := w.dropped[reason]
+	if !ok {
+		entry = &DroppedEntry{Reason: reason, Timestamp: timestamp}
+		w.dropped[reason] = entry
+	}
+	entry.Count += count
+}
+
+// flushDropped reports the pending dropped lines without blocking.
+// It returns true when none are left.
+func (w *StreamWriter) flushDropped() bool {
+	for _, reason := range []string{DroppedBackend, DroppedBackpressure} {
+		entry, ok := w.dropped[reason]
+		if !ok {
+			continue
+		}
+		select {
+		case w.items <- StreamItem{Dropped: entry}:
+			delete(w.dropped, reason)
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Go runs a follower of the stream. The stream is closed once all its followers return.
+//
+// The follower is restarted with an exponential backoff when it fails, up to StreamOptions.MaxReconnects
+// consecutive times. It resumes after the last line it sent, see StreamCursor.
+// A follower that returns nil, e.g. because its container terminated, is not restarted.
+func (w *StreamWriter) Go(since time.Time, follow func(cursor *StreamCursor) error) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				w.Error(fmt.Errorf("log stream panicked: %v", r))
+			}
+		}()
+
+		cursor := &StreamCursor{writer: w, last: since}
+		backoff := time.Second
+		var failures int
+		for {
+			sent := cursor.sent
+			err := follow(cursor)
+			if err == nil || w.ctx.Err() != nil {
+				return
+			}
+
+			if cursor.sent > sent {
+				failures = 0
+				backoff = time.Second
+			}
+			failures++
+			if w.opts.MaxReconnects < 0 || failures > w.opts.MaxReconnects {
+				w.Error(fmt.Errorf("log stream failed after %d attempts: %w", failures, err))
+				return
+			}
+
+			if !w.Error(fmt.Errorf("log stream failed, reconnecting in %s: %w", backoff, err)) {
+				return
+			}
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxStreamReconnectBackoff)
+			cursor.Resume()
+		}
+	}()
+}
+
+// Close closes the stream after all its followers return,
+// delivering the lines that are still reported as dropped.
+func (w *StreamWriter) Close() {
+	w.wg.Wait()
+
+	w.lock.Lock()
+	for _, reason := range []string{DroppedBackend, DroppedBackpressure} {
+		if entry, ok := w.dropped[reason]; ok {
+			w.send(StreamItem{Dropped: entry})
+		}
+	}
+	w.dropped = nil
+	w.lock.Unlock()
+
+	close(w.items)
+}
+
+// StreamCursor tracks the last line a follower sent,
+// so lines a backend sends again when the follower resumes are skipped.
+type StreamCursor struct {
+	writer   *StreamWriter
+	last     time.Time
+	keys     map[string]struct{}
+	resuming bool
+	sent     int
+}
+
+// Since returns the time to resume the stream from.
+func (c *StreamCursor) Since() time.Time {
+	return c.last
+}
+
+// Resume skips the lines up to the last line sent, until a newer line is sent.
+// It is called before reconnecting, and by pollers before every poll.
+func (c *StreamCursor) Resume() {
+	c.resuming = !c.last.IsZero()
+}
+
+// Send delivers the line unless it was sent before resuming.
+// It returns false once the stream's context is done.
+func (c *StreamCursor) Send(line *LogLine) bool {
+	key := line.ID + "\x00" + line.Host + "\x00" + line.Message
+
+	if c.resuming {
+		if line.FirstObserved.Before(c.last) {
+			return true
+		}
+		if line.FirstObserved.Equal(c.last) {
+			if _, ok := c.keys[key]; ok {
+				return true
+			}
+		} else {
+			c.resuming = false
+		}
+	}
+
+	if line.FirstObserved.After(c.last) {
+		c.last = line.FirstObserved
+		c.keys = map[string]struct{}{}
+	}
+	if line.FirstObserved.Equal(c.last) {
+		if c.keys == nil {
+			c.keys = map[string]struct{}{}
+		}
+		c.keys[key] = struct{}{}
+	}
+
+	c.sent++
+	return c.writer.Send(line)
+}
@@ -0,0 +1,102 @@
+package logs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+
+	"github.com/flanksource/duty/context"
+)
+
+func collectStream(items <-chan StreamItem) (lines []string, dropped []DroppedEntry, errs []error) {
+	for item := range items {
+		switch {
+		case item.LogLine != nil:
+			lines = append(lines, item.LogLine.Message)
+		case item.Dropped != nil:
+			dropped = append(dropped, *item.Dropped)
+		case item.Error != nil:
+			errs = append(errs, item.Error)
+		}
+	}
+	return
+}
+
+func TestStreamWriter(t *testing.T) {
+	base := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	line := func(seconds int, message string) *LogLine {
+		return &LogLine{FirstObserved: base.Add(time.Duration(seconds) * time.Second), Message: message}
+	}
+
+	t.Run("resumes after the last line sent", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		writer := NewStreamWriter(context.New(), StreamOptions{})
+		var attempts int
+		var resumedFrom time.Time
+		writer.Go(time.Time{}, func(cursor *StreamCursor) error {
+			attempts++
+			if attempts == 1 {
+				cursor.Send(line(1, "a"))
+				cursor.Send(line(2, "b"))
+				return fmt.Errorf("connection reset")
+			}
+
+			// Backends resume from the last timestamp, sending the lines at that timestamp again
+			resumedFrom = cursor.Since()
+			cursor.Send(line(2, "b"))
+			cursor.Send(line(2, "c"))
+			cursor.Send(line(3, "d"))
+			return nil
+		})
+		go writer.Close()
+
+		lines, dropped, errs := collectStream(writer.Items())
+		g.Expect(lines).To(gomega.Equal([]string{"a", "b", "c", "d"}))
+		g.Expect(dropped).To(gomega.BeEmpty())
+		g.Expect(errs).To(gomega.HaveLen(1))
+		g.Expect(errs[0].Error()).To(gomega.ContainSubstring("reconnecting"))
+		g.Expect(resumedFrom).To(gomega.Equal(base.Add(2 * time.Second)))
+	})
+
+	t.Run("gives up after max reconnects", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		writer := NewStreamWriter(context.New(), StreamOptions{MaxReconnects: -1})
+		writer.Go(time.Time{}, func(cursor *StreamCursor) error {
+			return fmt.Errorf("unreachable")
+		})
+		go writer.Close()
+
+		_, _, errs := collectStream(writer.Items())
+		g.Expect(errs).To(gomega.HaveLen(1))
+		g.Expect(errs[0].Error()).To(gomega.ContainSubstring("failed after 1 attempts: unreachable"))
+	})
+
+	t.Run("reports the lines dropped for a slow consumer", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		writer := NewStreamWriter(context.New(), StreamOptions{BufferSize: 2, DropOnFull: true})
+		writer.Go(time.Time{}, func(cursor *StreamCursor) error {
+			for i := range 5 {
+				cursor.Send(line(i, fmt.Sprintf("line-%d", i)))
+			}
+			writer.Drop(DroppedEntry{Count: 3, Reason: DroppedBackend})
+			return nil
+		})
+
+		// Nothing is read until the follower is done, so only the buffered lines are delivered
+		time.Sleep(50 * time.Millisecond)
+		go writer.Close()
+
+		lines, dropped, errs := collectStream(writer.Items())
+		g.Expect(errs).To(gomega.BeEmpty())
+		g.Expect(lines).To(gomega.Equal([]string{"line-0", "line-1"}))
+		g.Expect(dropped).To(gomega.Equal([]DroppedEntry{
+			{Count: 3, Reason: DroppedBackend},
+			{Count: 3, Timestamp: base.Add(2 * time.Second), Reason: DroppedBackpressure},
+		}))
+	})
+}
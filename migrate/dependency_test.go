@@ -47,6 +47,7 @@ func TestDependencyMap(t *testing.T) {
 	expected := map[string][]string{
 		"functions/drop.sql":         {"views/006_config_views.sql", "views/021_notification.sql", "views/038_config_access.sql"},
 		"functions/cost_overlap.sql": {"views/006_config_views.sql"},
+		"functions/config_masks.sql": {"views/006_config_views.sql"},
 		"views/006_config_views.sql": {"views/014_config_item_by_type.sql", "views/021_notification.sql"},
 	}
 
@@ -69,3 +69,73 @@ func (a Agent) PK() string {
 func (t Agent) AsMap(removeFields ...string) map[string]any {
 	return asMap(t, removeFields...)
 }
+
+const (
+	UpstreamPullStatusApplied = "applied"
+
+	// UpstreamPullStatusConflict is an upstream object that was not applied
+	// because the local copy was edited and won the conflict.
+	UpstreamPullStatusConflict = "conflict"
+
+	UpstreamPullStatusFailed = "failed"
+)
+
+// UpstreamPulledObject records the last version of an upstream object pulled by an agent.
+type UpstreamPulledObject struct {
+	Table              string    `json:"table_name" gorm:"column:table_name;primaryKey"`
+	ID                 string    `json:"id" gorm:"primaryKey"`
+	UpstreamModifiedAt time.Time `json:"upstream_modified_at"`
+
+	// LocalModifiedAt is the modification time of the local copy when it was last written by a pull.
+	// A local copy modified after this time was edited on the agent.
+	LocalModifiedAt *time.Time `json:"local_modified_at,omitempty"`
+
+	Status string  `json:"status"`
+	Error  *string `json:"error,omitempty"`
+
+	// Attempts is the number of times this version of the object failed to apply
+	Attempts int       `json:"attempts,omitempty"`
+	PulledAt time.Time `json:"pulled_at" gorm:"default:now()"`
+}
+
+func (t UpstreamPulledObject) TableName() string {
+	return "upstream_pulled_objects"
+}
+
+// UpstreamPushChunk is a push chunk that the upstream has saved,
+// identified by its idempotency key.
+type UpstreamPushChunk struct {
+	AgentID   uuid.UUID `json:"agent_id" gorm:"primaryKey"`
+	Key       string    `json:"key" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"<-:false"`
+}
+
+func (t UpstreamPushChunk) TableName() string {
+	return "upstream_push_chunks"
+}
+
+const (
+	UpstreamSpoolKindDelete   = "delete"
+	UpstreamSpoolKindArtifact = "artifact"
+)
+
+// UpstreamSpoolEntry is a delete or an artifact upload that an agent
+// could not send to the upstream, waiting to be sent in order.
+type UpstreamSpoolEntry struct {
+	ID         int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Kind       string     `json:"kind"`
+	ArtifactID *uuid.UUID `json:"artifact_id,omitempty"`
+
+	// Payload is the JSON encoded push data to delete, or the artifact content
+	Payload []byte `json:"-"`
+	Size    int64  `json:"size"`
+
+	Attempts    int        `json:"attempts"`
+	Error       *string    `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"<-:false"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+}
+
+func (t UpstreamSpoolEntry) TableName() string {
+	return "upstream_spool"
+}
@@ -33,6 +33,13 @@ type Artifact struct {
 	// It may become nil when job_history retention prunes old rows.
 	JobHistoryID *uuid.UUID `json:"job_history_id,omitempty"`
 
+	// EncryptionKey is the per-artifact data key wrapped by the secret keeper.
+	// It is nil when the content is stored in plain text.
+	EncryptionKey []byte `json:"-" gorm:"type:bytea"`
+
+	// EncryptionAlgorithm is the cipher the content is encrypted with.
+	EncryptionAlgorithm string `json:"encryption_algorithm,omitempty"`
+
 	ConnectionID    uuid.UUID  `json:"connection_id,omitempty"`
 	Path            string     `json:"path"`
 	IsPushed        bool       `json:"is_pushed"`
@@ -160,6 +167,10 @@ func (a Artifact) IsInline() bool {
 	return a.Content != nil
 }
 
+func (a Artifact) IsEncrypted() bool {
+	return a.EncryptionAlgorithm != ""
+}
+
 // SetContent compresses data using the given compression type and sets the
 // Content and CompressionType fields. maxSize is checked against the
 // post-compression size; returns an error if exceeded.
@@ -186,6 +197,14 @@ func (a Artifact) GetContent() ([]byte, error) {
 	return decompress(a.Content, a.CompressionType)
 }
 
+// GetContentReader returns a reader that decompresses the inline content as it is read.
+func (a Artifact) GetContentReader() (io.ReadCloser, error) {
+	if a.Content == nil {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	return decompressReader(a.Content, a.CompressionType)
+}
+
 func sha256Sum(data []byte) []byte {
 	h := sha256.Sum256(data)
 	return h[:]
@@ -226,6 +245,17 @@ func decompress(data []byte, compressionType string) ([]byte, error) {
 	}
 }
 
+func decompressReader(data []byte, compressionType string) (io.ReadCloser, error) {
+	switch compressionType {
+	case "gzip":
+		return gzip.NewReader(bytes.NewReader(data))
+	case "", "none":
+		return io.NopCloser(bytes.NewReader(data)), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression type: %s", compressionType)
+	}
+}
+
 func (t Artifact) GetUnpushed(db *gorm.DB) ([]DBTable, error) {
 	var items []Artifact
 	err := db.Where("is_pushed IS FALSE").Find(&items).Error
@@ -157,6 +157,9 @@ type ConfigItem struct {
 	DeletedAt    *time.Time           `json:"deleted_at,omitempty"`
 	DeleteReason string               `json:"delete_reason,omitempty"`
 
+	// MaskedPaths are the fields of the config that were redacted for the reader
+	MaskedPaths []string `json:"masked_paths,omitempty" gorm:"-"`
+
 	configJson map[string]any `json:"-" yaml:"-" gorm:"-"`
 }
 type ConfigItemLastScrapedTime struct {
@@ -402,6 +405,14 @@ func (ci *ConfigItem) ConfigJSONStringMap() (map[string]any, error) {
 	return ci.configJson, err
 }
 
+// WithMaskedConfig returns a copy of the config item with its config replaced by a redacted config
+func (ci ConfigItem) WithMaskedConfig(config string, maskedPaths []string) ConfigItem {
+	ci.Config = &config
+	ci.MaskedPaths = maskedPaths
+	ci.configJson = nil
+	return ci
+}
+
 func (ci *ConfigItem) NestedString(paths ...string) string {
 	m, err := ci.ConfigJSONStringMap()
 	if err != nil {
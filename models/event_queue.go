@@ -23,7 +23,12 @@ type Event struct {
 	Error       *string             `json:"error,omitempty"`
 	Attempts    int                 `json:"attempts"`
 	LastAttempt *time.Time          `json:"last_attempt"`
+	NextAttempt *time.Time          `json:"next_attempt,omitempty"`
 	Priority    int                 `json:"priority"`
+
+	// Failure is the error set by Fail. It is not persisted, but lets async consumers
+	// hand typed errors, e.g. a retry hint, back to the queue.
+	Failure error `json:"-" gorm:"-"`
 }
 
 // We are using the term `Event` as it represents an event in the
@@ -37,10 +42,17 @@ func (t *Event) SetError(err string) {
 	t.Error = &err
 }
 
+// Fail records err as the reason the event failed.
+func (t *Event) Fail(err error) {
+	t.Failure = err
+	t.SetError(err.Error())
+}
+
 type Events []Event
 
 // Recreate creates the given failed events in batches after updating the
-// attempts count.
+// attempts count. The events keep their creation time so that they keep their
+// place among events delivered in order.
 func (events Events) Recreate(ctx context.Context, tx *gorm.DB) error {
 	if len(events) == 0 {
 		return nil
@@ -51,10 +63,12 @@ func (events Events) Recreate(ctx context.Context, tx *gorm.DB) error {
 		batch = append(batch, Event{
 			EventID:     event.EventID,
 			Name:        event.Name,
+			CreatedAt:   event.CreatedAt,
 			Properties:  event.Properties,
 			Error:       event.Error,
 			Attempts:    event.Attempts + 1,
 			LastAttempt: event.LastAttempt,
+			NextAttempt: event.NextAttempt,
 			Priority:    event.Priority - 1,
 		})
 	}
@@ -66,6 +80,29 @@ func (e Event) PK() string {
 	return e.ID.String()
 }
 
+// EventDeadLetter is an event that was moved out of the event queue
+// after it failed more than the consumer's max attempts.
+type EventDeadLetter struct {
+	ID             uuid.UUID           `json:"id"`
+	EventID        *uuid.UUID          `json:"event_id,omitempty"`
+	Name           string              `json:"name"`
+	Properties     types.JSONStringMap `json:"properties,omitempty"`
+	Error          *string             `json:"error,omitempty"`
+	Attempts       int                 `json:"attempts"`
+	Priority       int                 `json:"priority"`
+	CreatedAt      time.Time           `json:"created_at"`
+	LastAttempt    *time.Time          `json:"last_attempt,omitempty"`
+	DeadLetteredAt time.Time           `json:"dead_lettered_at" gorm:"default:now()"`
+}
+
+func (EventDeadLetter) TableName() string {
+	return "event_queue_dead_letters"
+}
+
+func (e EventDeadLetter) PK() string {
+	return e.ID.String()
+}
+
 type EventQueueSummary struct {
 	EventID       uuid.UUID  `json:"event_id"`
 	Name          string     `json:"name"`
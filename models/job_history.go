@@ -62,6 +62,7 @@ type JobHistory struct {
 	Status         string
 	TimeStart      time.Time
 	TimeEnd        *time.Time
+	ParentID       *uuid.UUID    `json:"parent_id,omitempty"`
 	Errors         []string      `gorm:"-"`
 	Logger         logger.Logger `gorm:"-"`
 }
@@ -197,3 +198,26 @@ func (h *JobHistory) evaluateStatus() {
 		h.Status = StatusSuccess
 	}
 }
+
+// JobLock is a lease on a singleton job shared by all replicas.
+type JobLock struct {
+	JobID string `json:"job_id" gorm:"primaryKey"`
+
+	// Holder identifies the process that holds the lease.
+	Holder string `json:"holder"`
+
+	// PreviousHolder is the holder whose expired lease was taken over, if any.
+	PreviousHolder *string `json:"previous_holder,omitempty"`
+
+	AcquiredAt time.Time `json:"acquired_at"`
+	RenewedAt  time.Time `json:"renewed_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func (JobLock) TableName() string {
+	return "job_locks"
+}
+
+func (l JobLock) PK() string {
+	return l.JobID
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/flanksource/clicky"
 	"github.com/flanksource/clicky/api"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/samber/lo"
 
 	"github.com/flanksource/duty/types"
@@ -181,3 +182,85 @@ func (t *Permission) Effect() string {
 
 	return "allow"
 }
+
+const (
+	PermissionGrantStatusPending  = "pending"
+	PermissionGrantStatusApproved = "approved"
+	PermissionGrantStatusRejected = "rejected"
+	PermissionGrantStatusRevoked  = "revoked"
+	PermissionGrantStatusExpired  = "expired"
+)
+
+// PermissionGrant is a request for time-bounded access.
+// Once approved, it is backed by a permission that lasts until the grant expires.
+type PermissionGrant struct {
+	ID             uuid.UUID             `json:"id" gorm:"default:generate_ulid()"`
+	Subject        string                `json:"subject"`
+	SubjectType    PermissionSubjectType `json:"subject_type,omitempty" gorm:"default:person"`
+	Action         string                `json:"action"`
+	Object         string                `json:"object,omitempty" gorm:"default:NULL"`
+	ObjectSelector types.JSON            `json:"object_selector,omitempty" gorm:"default:NULL"`
+	Reason         string                `json:"reason,omitempty"`
+
+	// Duration is how long the grant lasts once approved, e.g. "2h".
+	Duration string `json:"duration"`
+
+	Status       string     `json:"status" gorm:"default:pending"`
+	RequestedBy  *uuid.UUID `json:"requested_by,omitempty"`
+	ApprovedBy   *uuid.UUID `json:"approved_by,omitempty"`
+	ApprovedAt   *time.Time `json:"approved_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	RevokedBy    *uuid.UUID `json:"revoked_by,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	PermissionID *uuid.UUID `json:"permission_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at,omitempty" time_format:"postgres_timestamp" gorm:"<-:false"`
+}
+
+func (p PermissionGrant) PK() string {
+	return p.ID.String()
+}
+
+func (p PermissionGrant) TableName() string {
+	return "permission_grants"
+}
+
+// PermissionGrantEvent records a change to a permission grant for auditing.
+type PermissionGrantEvent struct {
+	ID        uuid.UUID  `json:"id" gorm:"default:generate_ulid()"`
+	GrantID   uuid.UUID  `json:"grant_id"`
+	Event     string     `json:"event"`
+	Actor     *uuid.UUID `json:"actor,omitempty"`
+	Details   string     `json:"details,omitempty"`
+	CreatedAt time.Time  `json:"created_at,omitempty" time_format:"postgres_timestamp" gorm:"<-:false"`
+}
+
+func (p PermissionGrantEvent) PK() string {
+	return p.ID.String()
+}
+
+func (p PermissionGrantEvent) TableName() string {
+	return "permission_grant_events"
+}
+
+// ConfigMask redacts fields of the config of config items
+// for the subjects with any of its roles, or for everyone when it has none.
+type ConfigMask struct {
+	ID          uuid.UUID      `json:"id" gorm:"default:generate_ulid()"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	ConfigTypes pq.StringArray `json:"config_types,omitempty" gorm:"type:[]text"`
+	Roles       pq.StringArray `json:"roles,omitempty" gorm:"type:[]text"`
+	Paths       pq.StringArray `json:"paths" gorm:"type:[]text"`
+	CreatedBy   *uuid.UUID     `json:"created_by,omitempty"`
+	CreatedAt   time.Time      `json:"created_at,omitempty" time_format:"postgres_timestamp" gorm:"<-:create"`
+	UpdatedAt   *time.Time     `json:"updated_at,omitempty" time_format:"postgres_timestamp"`
+	DeletedAt   *time.Time     `json:"deleted_at,omitempty" time_format:"postgres_timestamp"`
+}
+
+func (c ConfigMask) PK() string {
+	return c.ID.String()
+}
+
+func (c ConfigMask) TableName() string {
+	return "config_masks"
+}
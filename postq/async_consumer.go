@@ -8,9 +8,11 @@ import (
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/duty/context"
 	"github.com/flanksource/duty/models"
+	"github.com/samber/lo"
 )
 
-// AsyncEventHandlerFunc processes multiple events and returns the failed ones
+// AsyncEventHandlerFunc processes multiple events and returns the failed ones.
+// Failed events set with models.Event.Fail can carry a RetryAfter or Permanent error.
 type AsyncEventHandlerFunc func(context.Context, models.Events) models.Events
 
 type AsyncEventConsumer struct {
@@ -71,11 +73,26 @@ func (t *AsyncEventConsumer) Handle(ctx context.Context) (int, error) {
 	}
 	c := ctx.Wrap(gocontext.Background())
 	failedEvents := t.Consumer(c, events)
-	if err := failedEvents.Recreate(ctx, tx); err != nil {
+	retryEvents, exhaustedEvents := t.EventFetcherOption.planRetries(failedEvents)
+	if err := retryEvents.Recreate(ctx, tx); err != nil {
 		ctx.Debugf("error saving event attempt updates to event_queue: %v\n", err)
 	}
+	if err := deadLetter(ctx, tx, "async", exhaustedEvents); err != nil {
+		ctx.Debugf("error moving events to the dead-letter queue: %v\n", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return len(events), err
+	}
+
+	if len(exhaustedEvents) > 0 {
+		names := lo.Uniq(lo.Map(exhaustedEvents, func(e models.Event, _ int) string { return e.Name }))
+		if err := RecordDeadLetterDepth(ctx, names...); err != nil {
+			ctx.Debugf("error recording dead-letter depth: %v\n", err)
+		}
+	}
 
-	return len(events), tx.Commit().Error
+	return len(events), nil
 }
 
 func (t AsyncEventConsumer) EventConsumer() (*PGConsumer, error) {
@@ -0,0 +1,175 @@
+package postq
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"github.com/samber/oops"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// deadLetter moves events that used up their attempts from the event queue to the dead-letter table.
+func deadLetter(ctx context.Context, tx *gorm.DB, consumer string, events models.Events) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	deadLetters := make([]models.EventDeadLetter, 0, len(events))
+	for _, event := range events {
+		deadLetters = append(deadLetters, models.EventDeadLetter{
+			ID:             event.ID,
+			EventID:        lo.Ternary(event.EventID == uuid.Nil, nil, lo.ToPtr(event.EventID)),
+			Name:           event.Name,
+			Properties:     event.Properties,
+			Error:          event.Error,
+			Attempts:       event.Attempts,
+			Priority:       event.Priority,
+			CreatedAt:      event.CreatedAt,
+			LastAttempt:    event.LastAttempt,
+			DeadLetteredAt: now,
+		})
+	}
+
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(deadLetters, 100).Error; err != nil {
+		return oops.Tags("db").Wrapf(err, "error dead-lettering events")
+	}
+
+	ids := lo.Map(events, func(e models.Event, _ int) uuid.UUID { return e.ID })
+	if err := tx.Where("id IN ?", ids).Delete(&models.Event{}).Error; err != nil {
+		return oops.Tags("db").Wrapf(err, "error removing dead-lettered events from event_queue")
+	}
+
+	recordDeadLettered(ctx, consumer, events)
+	return nil
+}
+
+// DeadLetterExhausted moves the queued events that already exceeded maxAttempts,
+// e.g. the ones left behind before dead-lettering was introduced, to the dead-letter table.
+func DeadLetterExhausted(ctx context.Context, watchEvents []string, maxAttempts int) (int, error) {
+	var events models.Events
+	if err := ctx.DB().Where("name IN ? AND attempts > ?", watchEvents, maxAttempts).Find(&events).Error; err != nil {
+		return 0, oops.Tags("db").Wrap(err)
+	}
+
+	err := ctx.DB().Transaction(func(tx *gorm.DB) error {
+		return deadLetter(ctx, tx, "backfill", events)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(events), RecordDeadLetterDepth(ctx, watchEvents...)
+}
+
+// DeadLetterQuery filters dead-lettered events.
+type DeadLetterQuery struct {
+	// Names matches events with any of the given names.
+	Names []string
+
+	// Error matches events whose error contains the given text, case-insensitively.
+	Error string
+
+	// Limit is the maximum number of events returned. Defaults to 100.
+	Limit int
+}
+
+func (q DeadLetterQuery) apply(db *gorm.DB) *gorm.DB {
+	if len(q.Names) > 0 {
+		db = db.Where("name IN ?", q.Names)
+	}
+	if q.Error != "" {
+		db = db.Where("error ILIKE ?", "%"+q.Error+"%")
+	}
+	return db
+}
+
+// ListDeadLetters returns the dead-lettered events matching the query, most recent first.
+func ListDeadLetters(ctx context.Context, q DeadLetterQuery) ([]models.EventDeadLetter, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	var deadLetters []models.EventDeadLetter
+	if err := q.apply(ctx.DB()).Order("dead_lettered_at DESC").Limit(limit).Find(&deadLetters).Error; err != nil {
+		return nil, oops.Tags("db").Wrap(err)
+	}
+	return deadLetters, nil
+}
+
+// ReplayDeadLetters moves the given dead-lettered events back to the event queue with their attempts reset.
+// Events already queued again under the same name and event_id are reset instead of duplicated.
+// It returns the number of events queued.
+func ReplayDeadLetters(ctx context.Context, ids ...uuid.UUID) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	const replayQuery = `
+		WITH replayed AS (
+			DELETE FROM event_queue_dead_letters WHERE id IN ? RETURNING *
+		)
+		INSERT INTO event_queue (event_id, name, properties, priority)
+		SELECT DISTINCT ON (name, COALESCE(event_id, id)) event_id, name, properties, priority
+		FROM replayed
+		ORDER BY name, COALESCE(event_id, id), dead_lettered_at DESC
+		ON CONFLICT ON CONSTRAINT event_queue_name_event_id DO UPDATE
+			SET created_at = NOW(), last_attempt = NULL, attempts = 0, error = NULL
+		RETURNING name
+	`
+
+	var names []string
+	if err := ctx.DB().Raw(replayQuery, ids).Scan(&names).Error; err != nil {
+		return 0, oops.Tags("db").Wrapf(err, "error replaying dead-lettered events")
+	}
+
+	return len(names), RecordDeadLetterDepth(ctx, lo.Uniq(names)...)
+}
+
+// ReplayDeadLettersMatching replays the dead-lettered events matching the query.
+func ReplayDeadLettersMatching(ctx context.Context, q DeadLetterQuery) (int, error) {
+	deadLetters, err := ListDeadLetters(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := lo.Map(deadLetters, func(e models.EventDeadLetter, _ int) uuid.UUID { return e.ID })
+	return ReplayDeadLetters(ctx, ids...)
+}
+
+// RecordDeadLetterDepth updates the dead-letter depth metric of the given event names,
+// or of every dead-lettered event name if none are given.
+func RecordDeadLetterDepth(ctx context.Context, names ...string) error {
+	type depth struct {
+		Name  string
+		Count int64
+	}
+
+	query := ctx.DB().Model(&models.EventDeadLetter{}).Select("name, COUNT(*) AS count").Group("name")
+	if len(names) > 0 {
+		query = query.Where("name IN ?", names)
+	}
+
+	var depths []depth
+	if err := query.Scan(&depths).Error; err != nil {
+		return fmt.Errorf("error counting dead-lettered events: %w", err)
+	}
+
+	counts := make(map[string]int64, len(names))
+	for _, name := range names {
+		counts[name] = 0
+	}
+	for _, d := range depths {
+		counts[d.Name] = d.Count
+	}
+	for name, count := range counts {
+		ctx.Gauge(deadLetterDepthMetricName, "event", name).Set(float64(count))
+	}
+	return nil
+}
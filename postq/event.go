@@ -10,8 +10,11 @@ import (
 	"gorm.io/gorm"
 )
 
+const defaultMaxAttempts = 3
+
 type EventFetcherOption struct {
-	// MaxAttempts is the number of times an event is attempted to process
+	// MaxAttempts is the number of times an event is attempted to process.
+	// Events that fail more often are moved to the event_queue_dead_letters table.
 	// default: 3
 	MaxAttempts int
 
@@ -22,6 +25,76 @@ type EventFetcherOption struct {
 	// Exponent is the exponent of the base delay
 	// default: 5 (along with baseDelay = 60, the retries are 1, 6, 31, 156 (in minutes))
 	Exponent int
+
+	// RetryPolicies overrides the BaseDelay and Exponent backoff per event name.
+	RetryPolicies map[string]RetryPolicy
+
+	// PartitionKey is the event property, e.g. "id", that events are ordered by.
+	// When set, at most one event per key is in flight across all consumers and
+	// events with the same key are delivered in the order they were created.
+	// An event that is waiting for a retry holds back the later events of its key.
+	// Events without the property are not ordered.
+	PartitionKey string
+}
+
+func (t *EventFetcherOption) partitionKey() string {
+	if t == nil {
+		return ""
+	}
+	return t.PartitionKey
+}
+
+// readyEventsCondition matches the events whose delay and retry backoff have elapsed.
+const readyEventsCondition = `
+	(delay IS NULL OR created_at + (delay * INTERVAL '1 second' / 1000000000)  <= NOW()) AND
+	attempts <= @MaxAttempts AND
+	name = ANY(@Events) AND
+	(
+		last_attempt IS NULL OR
+		(next_attempt IS NOT NULL AND next_attempt <= NOW()) OR
+		(next_attempt IS NULL AND last_attempt <= NOW() - INTERVAL '1 SECOND' * @BaseDelay * POWER(attempts, @Exponent))
+	)`
+
+const selectEventsQuery = `
+	WITH to_delete AS (
+		SELECT id FROM event_queue
+		WHERE ` + readyEventsCondition + `
+		ORDER BY priority DESC, created_at ASC
+		FOR UPDATE SKIP LOCKED
+		LIMIT @BatchSize
+	)
+	DELETE FROM event_queue
+	WHERE id IN (SELECT id FROM to_delete)
+	RETURNING *
+`
+
+// selectPartitionedEventsQuery only considers the oldest event of each partition key.
+// An event being processed stays locked until its transaction ends,
+// so no other consumer can take the next event of the same key meanwhile.
+const selectPartitionedEventsQuery = `
+	WITH heads AS (
+		SELECT DISTINCT ON (COALESCE(properties->>@PartitionKey, id::text)) id
+		FROM event_queue
+		WHERE name = ANY(@Events) AND attempts <= @MaxAttempts
+		ORDER BY COALESCE(properties->>@PartitionKey, id::text), created_at ASC, id ASC
+	),
+	to_delete AS (
+		SELECT id FROM event_queue
+		WHERE id IN (SELECT id FROM heads) AND ` + readyEventsCondition + `
+		ORDER BY priority DESC, created_at ASC
+		FOR UPDATE SKIP LOCKED
+		LIMIT @BatchSize
+	)
+	DELETE FROM event_queue
+	WHERE id IN (SELECT id FROM to_delete)
+	RETURNING *
+`
+
+func (t *EventFetcherOption) maxAttempts() int {
+	if t != nil && t.MaxAttempts > 0 {
+		return t.MaxAttempts
+	}
+	return defaultMaxAttempts
 }
 
 // fetchEvents fetches given watch events from the `event_queue` table.
@@ -30,44 +103,25 @@ func fetchEvents(ctx context.Context, tx *gorm.DB, watchEvents []string, batchSi
 		batchSize = 1
 	}
 
-	const selectEventsQuery = `
-		WITH to_delete AS (
-			SELECT id FROM event_queue
-			WHERE
-				(delay IS NULL OR created_at + (delay * INTERVAL '1 second' / 1000000000)  <= NOW()) AND
-				attempts <= @MaxAttempts AND
-				name = ANY(@Events) AND
-				(last_attempt IS NULL OR last_attempt <= NOW() - INTERVAL '1 SECOND' * @BaseDelay * POWER(attempts, @Exponent))
-			ORDER BY priority DESC, created_at ASC
-			FOR UPDATE SKIP LOCKED
-			LIMIT @BatchSize
-		)
-		DELETE FROM event_queue
-		WHERE id IN (SELECT id FROM to_delete)
-		RETURNING *
-	`
-
 	type EventArgs struct {
-		Events      pq.StringArray
-		BatchSize   int
-		MaxAttempts int
-		BaseDelay   int
-		Exponent    int
+		Events       pq.StringArray
+		BatchSize    int
+		MaxAttempts  int
+		BaseDelay    int
+		Exponent     int
+		PartitionKey string
 	}
 
 	args := EventArgs{
-		Events:      watchEvents,
-		BatchSize:   batchSize,
-		MaxAttempts: 3,
-		BaseDelay:   60,
-		Exponent:    5,
+		Events:       watchEvents,
+		BatchSize:    batchSize,
+		MaxAttempts:  opts.maxAttempts(),
+		BaseDelay:    60,
+		Exponent:     5,
+		PartitionKey: opts.partitionKey(),
 	}
 
 	if opts != nil {
-		if opts.MaxAttempts > 0 {
-			args.MaxAttempts = opts.MaxAttempts
-		}
-
 		if opts.BaseDelay > 0 {
 			args.BaseDelay = opts.BaseDelay
 		}
@@ -76,9 +130,13 @@ func fetchEvents(ctx context.Context, tx *gorm.DB, watchEvents []string, batchSi
 			args.Exponent = opts.Exponent
 		}
 	}
-	var events []models.Event
+	query := selectEventsQuery
+	if args.PartitionKey != "" {
+		query = selectPartitionedEventsQuery
+	}
 
-	if err := tx.Raw(selectEventsQuery, args).Scan(&events).Error; err != nil {
+	var events []models.Event
+	if err := tx.Raw(query, args).Scan(&events).Error; err != nil {
 		return nil, oops.Tags("db").Wrap(err)
 	}
 
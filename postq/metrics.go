@@ -5,9 +5,14 @@ import (
 	"strings"
 
 	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/models"
 )
 
-const preHandlerErrorsMetricName = "postq_prehandler_errors_total"
+const (
+	preHandlerErrorsMetricName = "postq_prehandler_errors_total"
+	deadLetteredMetricName     = "postq_dead_lettered_total"
+	deadLetterDepthMetricName  = "postq_dead_letter_depth"
+)
 
 func recordPreHandlerError(ctx context.Context, consumer string, watchEvents []string, err error) {
 	ctx.Counter(
@@ -18,6 +23,17 @@ func recordPreHandlerError(ctx context.Context, consumer string, watchEvents []s
 	).Add(1)
 }
 
+func recordDeadLettered(ctx context.Context, consumer string, events models.Events) {
+	counts := make(map[string]int)
+	for _, event := range events {
+		counts[event.Name]++
+	}
+
+	for name, count := range counts {
+		ctx.Counter(deadLetteredMetricName, "consumer", consumer, "event", name).Add(count)
+	}
+}
+
 func eventMetricLabel(events []string) string {
 	if len(events) == 0 {
 		return "unknown"
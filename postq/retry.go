@@ -0,0 +1,168 @@
+package postq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/flanksource/duty/models"
+	"github.com/robfig/cron/v3"
+)
+
+// RetryPolicy decides when a failed event is attempted again.
+type RetryPolicy interface {
+	// NextAttempt returns when an event that has failed the given number of times
+	// should be attempted again.
+	NextAttempt(attempts int, failedAt time.Time) time.Time
+}
+
+// FixedRetry retries after the same delay every time.
+type FixedRetry struct {
+	Delay time.Duration
+}
+
+func (t FixedRetry) NextAttempt(_ int, failedAt time.Time) time.Time {
+	return failedAt.Add(t.Delay)
+}
+
+// ExponentialRetry retries after BaseDelay * attempts^Exponent, capped at MaxDelay.
+type ExponentialRetry struct {
+	BaseDelay time.Duration
+	Exponent  float64
+
+	// MaxDelay caps the delay. A zero MaxDelay leaves it uncapped.
+	MaxDelay time.Duration
+}
+
+func (t ExponentialRetry) NextAttempt(attempts int, failedAt time.Time) time.Time {
+	delay := time.Duration(float64(t.BaseDelay) * math.Pow(float64(attempts), t.Exponent))
+	if t.MaxDelay > 0 && (delay > t.MaxDelay || delay < 0) {
+		delay = t.MaxDelay
+	}
+	return failedAt.Add(delay)
+}
+
+// JitteredRetry randomly shortens the delay of another policy by up to Jitter,
+// a fraction between 0 and 1, so that events that failed together do not retry together.
+type JitteredRetry struct {
+	Policy RetryPolicy
+	Jitter float64
+}
+
+func (t JitteredRetry) NextAttempt(attempts int, failedAt time.Time) time.Time {
+	next := t.Policy.NextAttempt(attempts, failedAt)
+	jitter := min(max(t.Jitter, 0), 1)
+	delay := next.Sub(failedAt)
+	return next.Add(-time.Duration(float64(delay) * jitter * rand.Float64()))
+}
+
+// CronRetry retries at the next time of a cron schedule.
+type CronRetry struct {
+	Schedule cron.Schedule
+}
+
+// NewCronRetry parses a standard cron expression, e.g. "*/15 * * * *", into a CronRetry.
+func NewCronRetry(spec string) (CronRetry, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return CronRetry{}, fmt.Errorf("invalid retry schedule %q: %w", spec, err)
+	}
+	return CronRetry{Schedule: schedule}, nil
+}
+
+func (t CronRetry) NextAttempt(_ int, failedAt time.Time) time.Time {
+	return t.Schedule.Next(failedAt)
+}
+
+// RetryAfterError asks the queue to attempt the event again after a delay,
+// regardless of its retry policy.
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", e.Err, e.After)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter wraps err so that the event is attempted again after the given delay.
+func RetryAfter(err error, after time.Duration) error {
+	return &RetryAfterError{Err: err, After: after}
+}
+
+// PermanentError marks a failure that retrying cannot fix.
+// The event is moved to the dead-letter queue straight away.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return fmt.Sprintf("permanent failure: %v", e.Err)
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err so that the event is not retried.
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// planRetry decides what to do with an event that failed with err.
+// The event's attempts must already include the failed attempt.
+// It returns whether the event should be dead-lettered and, otherwise, when to attempt it next.
+// A nil next attempt leaves the delay to the fetcher's default backoff.
+func (t *EventFetcherOption) planRetry(event models.Event, err error, failedAt time.Time) (bool, *time.Time) {
+	var permanent *PermanentError
+	if errors.As(err, &permanent) || event.Attempts > t.maxAttempts() {
+		return true, nil
+	}
+
+	var retryAfter *RetryAfterError
+	if errors.As(err, &retryAfter) {
+		next := failedAt.Add(retryAfter.After)
+		return false, &next
+	}
+
+	if t != nil {
+		if policy, ok := t.RetryPolicies[event.Name]; ok && policy != nil {
+			next := policy.NextAttempt(event.Attempts, failedAt)
+			return false, &next
+		}
+	}
+
+	return false, nil
+}
+
+// planRetries splits the failed events of an async consumer into the ones to retry
+// and the ones to dead-letter, and schedules the next attempt of the former.
+// Dead-lettered events have their attempt count incremented; events to retry are
+// incremented by Recreate.
+func (t *EventFetcherOption) planRetries(events models.Events) (models.Events, models.Events) {
+	var retry, deadLetters models.Events
+	now := time.Now()
+	for _, event := range events {
+		failed := event
+		failed.Attempts++
+
+		giveUp, nextAttempt := t.planRetry(failed, event.Failure, now)
+		if giveUp {
+			deadLetters = append(deadLetters, failed)
+			continue
+		}
+
+		event.NextAttempt = nextAttempt
+		if nextAttempt != nil {
+			event.LastAttempt = &now
+		}
+		retry = append(retry, event)
+	}
+	return retry, deadLetters
+}
@@ -0,0 +1,88 @@
+package postq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flanksource/duty/models"
+)
+
+func TestRetryPolicies(t *testing.T) {
+	failedAt := time.Date(2024, 1, 1, 10, 7, 0, 0, time.UTC)
+
+	if got := (FixedRetry{Delay: time.Minute}).NextAttempt(3, failedAt); !got.Equal(failedAt.Add(time.Minute)) {
+		t.Errorf("fixed: expected %s, got %s", failedAt.Add(time.Minute), got)
+	}
+
+	exponential := ExponentialRetry{BaseDelay: time.Minute, Exponent: 2, MaxDelay: 10 * time.Minute}
+	if got := exponential.NextAttempt(2, failedAt); !got.Equal(failedAt.Add(4 * time.Minute)) {
+		t.Errorf("exponential: expected %s, got %s", failedAt.Add(4*time.Minute), got)
+	}
+	if got := exponential.NextAttempt(5, failedAt); !got.Equal(failedAt.Add(10 * time.Minute)) {
+		t.Errorf("exponential: expected the delay to be capped, got %s", got)
+	}
+
+	jittered := JitteredRetry{Policy: FixedRetry{Delay: time.Minute}, Jitter: 0.5}
+	for range 20 {
+		got := jittered.NextAttempt(1, failedAt)
+		if got.Before(failedAt.Add(30*time.Second)) || got.After(failedAt.Add(time.Minute)) {
+			t.Fatalf("jittered: %s is outside of the jitter range", got)
+		}
+	}
+
+	cronRetry, err := NewCronRetry("*/15 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cronRetry.NextAttempt(1, failedAt); !got.Equal(time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC)) {
+		t.Errorf("cron: expected the next quarter hour, got %s", got)
+	}
+
+	if _, err := NewCronRetry("not a schedule"); err == nil {
+		t.Error("cron: expected an invalid schedule to fail")
+	}
+}
+
+func TestPlanRetry(t *testing.T) {
+	failedAt := time.Now()
+	opts := &EventFetcherOption{
+		MaxAttempts:   3,
+		RetryPolicies: map[string]RetryPolicy{"with.policy": FixedRetry{Delay: time.Hour}},
+	}
+
+	tests := []struct {
+		name           string
+		event          models.Event
+		err            error
+		giveUp         bool
+		expectedAfter  time.Duration
+		defaultBackoff bool
+	}{
+		{name: "default backoff", event: models.Event{Name: "no.policy", Attempts: 1}, err: errors.New("boom"), defaultBackoff: true},
+		{name: "policy", event: models.Event{Name: "with.policy", Attempts: 1}, err: errors.New("boom"), expectedAfter: time.Hour},
+		{name: "retry after overrides policy", event: models.Event{Name: "with.policy", Attempts: 1}, err: RetryAfter(errors.New("rate limited"), time.Minute), expectedAfter: time.Minute},
+		{name: "permanent", event: models.Event{Name: "with.policy", Attempts: 1}, err: Permanent(errors.New("bad payload")), giveUp: true},
+		{name: "exhausted", event: models.Event{Name: "with.policy", Attempts: 4}, err: RetryAfter(errors.New("rate limited"), time.Minute), giveUp: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			giveUp, next := opts.planRetry(tt.event, tt.err, failedAt)
+			if giveUp != tt.giveUp {
+				t.Fatalf("expected giveUp=%v, got %v", tt.giveUp, giveUp)
+			}
+
+			switch {
+			case tt.giveUp || tt.defaultBackoff:
+				if next != nil {
+					t.Errorf("expected no next attempt, got %s", next)
+				}
+			case next == nil:
+				t.Errorf("expected a next attempt")
+			case !next.Equal(failedAt.Add(tt.expectedAfter)):
+				t.Errorf("expected next attempt after %s, got %s", tt.expectedAfter, next.Sub(failedAt))
+			}
+		})
+	}
+}
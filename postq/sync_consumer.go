@@ -4,13 +4,16 @@ import (
 	"container/ring"
 	gocontext "context"
 	"fmt"
+	"time"
 
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/duty/context"
 	"github.com/flanksource/duty/models"
+	"gorm.io/gorm"
 )
 
 // SyncEventHandlerFunc processes a single event and ONLY makes db changes.
+// It can return a RetryAfter or Permanent error to control how the event is retried.
 type SyncEventHandlerFunc func(context.Context, models.Event) error
 
 type SyncEventConsumer struct {
@@ -56,9 +59,21 @@ func (t *SyncEventConsumer) Handle(ctx context.Context) (int, error) {
 
 		event.Attempts++
 		event.SetError(err.Error())
-		const query = `UPDATE event_queue SET error=$1, attempts=$2, last_attempt=NOW() WHERE id=$3`
-		if _, err := ctx.Pool().Exec(ctx, query, event.Error, event.Attempts, event.ID); err != nil {
-			ctx.Debugf("error saving event attempt updates to event_queue: %v\n", err)
+		giveUp, nextAttempt := t.EventFetchOption.planRetry(*event, err, time.Now())
+		if giveUp {
+			err := ctx.DB().Transaction(func(tx *gorm.DB) error {
+				return deadLetter(ctx, tx, "sync", models.Events{*event})
+			})
+			if err != nil {
+				ctx.Debugf("error moving event to the dead-letter queue: %v\n", err)
+			} else if err := RecordDeadLetterDepth(ctx, event.Name); err != nil {
+				ctx.Debugf("error recording dead-letter depth: %v\n", err)
+			}
+		} else {
+			const query = `UPDATE event_queue SET error=$1, attempts=$2, last_attempt=NOW(), next_attempt=$4 WHERE id=$3`
+			if _, err := ctx.Pool().Exec(ctx, query, event.Error, event.Attempts, event.ID, nextAttempt); err != nil {
+				ctx.Debugf("error saving event attempt updates to event_queue: %v\n", err)
+			}
 		}
 	}
 
@@ -486,9 +486,17 @@ func FindConfigForComponent(ctx context.Context, componentID, configType string)
 	if configType != "" {
 		query = query.Where("type = @config_type OR config_class = @config_type", sql.Named("config_type", configType))
 	}
-	err = query.Find(&results).Error
+	if err = query.Find(&results).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		if results[i], err = maskConfigItem(ctx, results[i]); err != nil {
+			return nil, err
+		}
+	}
 	timer.Results(results)
-	return results, err
+	return results, nil
 }
 
 func FindConfigChildrenIDsByLocation(ctx context.Context, configID uuid.UUID, prefix string) (results []uuid.UUID, err error) {
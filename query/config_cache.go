@@ -134,10 +134,14 @@ func ConfigItemFromCache(ctx context.Context, id string) (models.ConfigItem, err
 			return ci, err
 		}
 
-		return ci, configItemCache.Set(ctx, configItemCacheKey(id), ci)
+		// The cache is shared by all readers, so it holds the unmasked config
+		if err := configItemCache.Set(ctx, configItemCacheKey(id), ci); err != nil {
+			return ci, err
+		}
+		return maskConfigItem(ctx, ci)
 	}
 
-	return c, nil
+	return maskConfigItem(ctx, c)
 }
 
 func ConfigItemSummaryFromCache(ctx context.Context, id string) (models.ConfigItemSummary, error) {
@@ -0,0 +1,44 @@
+package query
+
+import (
+	"github.com/lib/pq"
+	"github.com/samber/lo"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/rls"
+)
+
+// GetConfigMasks returns the config masks that apply to subjects with any of the given roles,
+// to be added to their RLS payload.
+func GetConfigMasks(ctx context.Context, roles ...string) ([]rls.ConfigMask, error) {
+	var masks []models.ConfigMask
+	if err := ctx.DB().
+		Where("deleted_at IS NULL").
+		Where("roles IS NULL OR cardinality(roles) = 0 OR roles && ?", pq.StringArray(roles)).
+		Order("name").
+		Find(&masks).Error; err != nil {
+		return nil, ctx.Oops().Wrapf(err, "failed to get config masks")
+	}
+
+	return lo.Map(masks, func(m models.ConfigMask, _ int) rls.ConfigMask {
+		return rls.ConfigMask{Types: m.ConfigTypes, Paths: m.Paths}
+	}), nil
+}
+
+// maskConfigItem redacts the config of a config item with the config masks of the RLS payload.
+func maskConfigItem(ctx context.Context, ci models.ConfigItem) (models.ConfigItem, error) {
+	payload := ctx.RLSPayload()
+	if payload == nil || len(payload.ConfigMasks) == 0 || ci.Config == nil {
+		return ci, nil
+	}
+
+	config, maskedPaths, err := payload.MaskConfig(lo.FromPtr(ci.Type), *ci.Config)
+	if err != nil {
+		return ci, ctx.Oops().Wrapf(err, "failed to mask config %s", ci.ID)
+	} else if len(maskedPaths) == 0 {
+		return ci, nil
+	}
+
+	return ci.WithMaskedConfig(config, maskedPaths), nil
+}
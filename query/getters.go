@@ -94,11 +94,17 @@ func GetCachedComponent(ctx context.Context, id string) (*models.Component, erro
 
 func GetCachedConfig(ctx context.Context, id string) (*models.ConfigItem, error) {
 	config, err := findCachedEntity[models.ConfigItem](ctx, id)
-	if err != nil {
+	if err != nil || config == nil {
 		return nil, err
 	}
 
-	return config, nil
+	masked, err := maskConfigItem(ctx, *config)
+	if err != nil {
+		return nil, err
+	} else if len(masked.MaskedPaths) == 0 {
+		return config, nil
+	}
+	return &masked, nil
 }
 
 func GetCachedIncident(ctx context.Context, id string) (*models.Incident, error) {
@@ -2,6 +2,7 @@ package rbac
 
 import (
 	"strings"
+	"time"
 
 	"github.com/flanksource/duty/api"
 	"github.com/flanksource/duty/context"
@@ -35,6 +36,10 @@ type SubjectAccessReviewResult struct {
 	Subject string `json:"subject"`
 	Allowed bool   `json:"allowed"`
 	Error   string `json:"error,omitempty"`
+
+	// ExpiresAt and TTL are set when access is allowed by a time-bounded permission.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	TTL       string     `json:"ttl,omitempty"`
 }
 
 type SubjectAccessSearchRequest struct {
@@ -247,13 +252,24 @@ func RunSubjectAccessReview(ctx context.Context, req SubjectAccessReviewRequest)
 		}
 
 		var allowed bool
+		var object any = resourceAttr
 		if req.Resource.Global != "" {
+			object = req.Resource.Global
 			allowed = Check(ctx, subject, req.Resource.Global, req.Action)
 		} else {
 			allowed = HasPermission(ctx, subject, resourceAttr, req.Action)
 		}
 
-		results = append(results, SubjectAccessReviewResult{Subject: subject, Allowed: allowed})
+		result := SubjectAccessReviewResult{Subject: subject, Allowed: allowed}
+		if allowed {
+			if expiresAt, err := accessExpiry(ctx, subject, object, req.Action); err != nil {
+				result.Error = err.Error()
+			} else if expiresAt != nil {
+				result.ExpiresAt = expiresAt
+				result.TTL = time.Until(*expiresAt).Round(time.Second).String()
+			}
+		}
+		results = append(results, result)
 	}
 
 	return results, nil
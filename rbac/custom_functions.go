@@ -49,10 +49,23 @@ type resourcePair struct {
 	present      bool
 	attrResource types.ResourceSelectable
 	selectors    []types.ResourceSelector
+
+	// resourceType is the object_selector field of the selectors
+	resourceType string
 }
 
 // matchResourceSelector matches an ABACAttribute against resource selectors
 func matchResourceSelector(attr *models.ABACAttribute, selector Selectors) (bool, error) {
+	for _, pair := range resourceSelectorPairs(attr, selector) {
+		if !matchResourceSelectorPair(pair) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func resourceSelectorPairs(attr *models.ABACAttribute, selector Selectors) []resourcePair {
 	// The view selector isn't fully resourceSelector compliant yet.
 	// For now we start with just the namespace/name and id selector
 	var viewSelectors []types.ResourceSelector
@@ -64,21 +77,13 @@ func matchResourceSelector(attr *models.ABACAttribute, selector Selectors) (bool
 		})
 	}
 
-	resourcePairs := []resourcePair{
-		{hasResourceID(attr.Playbook.ID), &attr.Playbook, selector.Playbooks},
-		{hasResourceID(attr.Component.ID), attr.Component, selector.Components},
-		{hasResourceID(attr.Connection.ID), &attr.Connection, selector.Connections},
-		{hasResourceID(attr.Config.ID), attr.Config, selector.Configs},
-		{hasResourceID(attr.View.ID), &attr.View, viewSelectors},
-	}
-
-	for _, pair := range resourcePairs {
-		if !matchResourceSelectorPair(pair) {
-			return false, nil
-		}
+	return []resourcePair{
+		{hasResourceID(attr.Playbook.ID), &attr.Playbook, selector.Playbooks, "playbooks"},
+		{hasResourceID(attr.Component.ID), attr.Component, selector.Components, "components"},
+		{hasResourceID(attr.Connection.ID), &attr.Connection, selector.Connections, "connections"},
+		{hasResourceID(attr.Config.ID), attr.Config, selector.Configs, "configs"},
+		{hasResourceID(attr.View.ID), &attr.View, viewSelectors, "views"},
 	}
-
-	return true, nil
 }
 
 func matchResourceSelectorPair(pair resourcePair) bool {
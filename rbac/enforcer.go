@@ -121,6 +121,13 @@ func Stop() {
 	}
 }
 
+// Reset stops and removes the enforcer, so that permissions are no longer enforced.
+// It is used by tests that Init the enforcer.
+func Reset() {
+	Stop()
+	enforcer = nil
+}
+
 func DeleteRole(role string) (bool, error) {
 	return enforcer.DeleteRole(role)
 }
@@ -0,0 +1,236 @@
+package rbac
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+
+	"github.com/flanksource/duty/api"
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/rbac/policy"
+)
+
+const (
+	DecisionAllowed = "allowed"
+
+	// DecisionDenied is an explicit deny policy that overrides any allow
+	DecisionDenied = "denied"
+
+	// DecisionNoMatch is the default deny when no allow policy matched
+	DecisionNoMatch = "no_matching_policy"
+)
+
+// Explanation describes how the enforcer reached a decision.
+type Explanation struct {
+	Subject  string `json:"subject"`
+	Action   string `json:"action"`
+	Allowed  bool   `json:"allowed"`
+	Decision string `json:"decision"`
+
+	// DenyWon is true when an allow policy matched but was overridden by a deny policy
+	DenyWon bool `json:"deny_won,omitempty"`
+
+	// Roles are the role inheritance chains of the subject, each starting with the subject.
+	Roles [][]string `json:"roles,omitempty"`
+
+	// Policies are the policies of the subject and its roles that apply to the object and action.
+	Policies []PolicyEvaluation `json:"policies,omitempty"`
+}
+
+// PolicyEvaluation is the outcome of a single policy for the request.
+type PolicyEvaluation struct {
+	policy.Permission
+
+	// Via is the role chain through which the subject inherits the policy.
+	// It is empty when the policy is assigned to the subject directly.
+	Via []string `json:"via,omitempty"`
+
+	Matched bool   `json:"matched"`
+	Error   string `json:"error,omitempty"`
+
+	// Selectors is how each resource selector in the condition evaluated.
+	Selectors []SelectorEvaluation `json:"selectors,omitempty"`
+}
+
+// SelectorEvaluation is the outcome of the object_selector of a policy for a resource type.
+type SelectorEvaluation struct {
+	ResourceType string   `json:"resource_type"`
+	Selectors    []string `json:"selectors,omitempty"`
+
+	// Present is true when the request has a resource of this type
+	Present bool `json:"present"`
+	Matched bool `json:"matched"`
+}
+
+// Explain explains why the live enforcer allows or denies the subject the action on an object.
+func Explain(ctx context.Context, subject string, attr *models.ABACAttribute, action string) (*Explanation, error) {
+	if enforcer == nil {
+		return nil, api.Errorf(api.EINVALID, "rbac is not initialized")
+	}
+	if attr == nil {
+		return nil, api.Errorf(api.EINVALID, "attributes are required")
+	}
+
+	explanation, err := explain(enforcer, subject, attr, action)
+	if err != nil {
+		return nil, ctx.Oops().Wrapf(err, "failed to explain access of %s", subject)
+	}
+	return explanation, nil
+}
+
+func explain(e casbin.IEnforcer, subject string, obj any, action string) (*Explanation, error) {
+	// A condition that fails to evaluate denies access, as it does in Check.
+	// The failing policy is reported with its error below.
+	allowed, _ := e.Enforce(subject, obj, action)
+
+	groupings, err := e.GetGroupingPolicy()
+	if err != nil {
+		return nil, err
+	}
+	chains := roleChains(subject, groupings)
+
+	policies, err := e.GetPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every policy is evaluated on its own by an enforcer that has only that policy
+	m, err := model.NewModelFromString(DefaultModel)
+	if err != nil {
+		return nil, err
+	}
+	scratch, err := casbin.NewEnforcer(m)
+	if err != nil {
+		return nil, err
+	}
+	AddCustomFunctions(scratch)
+
+	explanation := &Explanation{Subject: subject, Action: action, Allowed: allowed}
+	for _, chain := range chains {
+		if len(chain) > 1 {
+			explanation.Roles = append(explanation.Roles, chain)
+		}
+	}
+
+	var allowMatched, denyMatched bool
+	for _, chain := range chains {
+		role := chain[len(chain)-1]
+		for _, rule := range policies {
+			p := policy.NewPermission(rule)
+			if p.Subject != role || !matchAction(action, p.Action) || !matchObject(obj, p.Object) {
+				continue
+			}
+
+			evaluation := PolicyEvaluation{Permission: p}
+			if len(chain) > 1 {
+				evaluation.Via = chain
+			}
+
+			scratch.ClearPolicy()
+			if _, err := scratch.AddPolicy(subject, p.Object, p.Action, "allow", p.Condition, p.ID); err != nil {
+				return nil, err
+			}
+			if evaluation.Matched, err = scratch.Enforce(subject, obj, action); err != nil {
+				evaluation.Error = err.Error()
+			}
+
+			if attr, ok := obj.(*models.ABACAttribute); ok && attr != nil {
+				evaluation.Selectors = explainSelectors(attr, p.Condition)
+			}
+
+			if evaluation.Matched {
+				if p.Deny {
+					denyMatched = true
+				} else {
+					allowMatched = true
+				}
+			}
+			explanation.Policies = append(explanation.Policies, evaluation)
+		}
+	}
+
+	switch {
+	case denyMatched:
+		explanation.Decision = DecisionDenied
+		explanation.DenyWon = allowMatched
+	case allowMatched:
+		explanation.Decision = DecisionAllowed
+	default:
+		explanation.Decision = DecisionNoMatch
+	}
+
+	return explanation, nil
+}
+
+// roleChains returns the subject itself and every role it inherits, each as the chain of roles leading to it.
+func roleChains(subject string, groupings [][]string) [][]string {
+	parents := map[string][]string{}
+	for _, g := range groupings {
+		if len(g) >= 2 {
+			parents[g[0]] = append(parents[g[0]], g[1])
+		}
+	}
+
+	chains := [][]string{{subject}}
+	seen := map[string]bool{subject: true}
+	for i := 0; i < len(chains); i++ {
+		chain := chains[i]
+		for _, role := range parents[chain[len(chain)-1]] {
+			if seen[role] {
+				continue
+			}
+			seen[role] = true
+
+			next := append(append([]string{}, chain...), role)
+			chains = append(chains, next)
+		}
+	}
+	return chains
+}
+
+// matchObject is the object part of the matcher in model.ini
+func matchObject(obj any, policyObject string) bool {
+	if policyObject == "*" {
+		return true
+	}
+	s, ok := obj.(string)
+	return ok && s == policyObject
+}
+
+var resourceSelectorCondition = regexp.MustCompile(`matchResourceSelector\(r\.obj, ("(?:[^"\\]|\\.)*")\)`)
+
+func explainSelectors(attr *models.ABACAttribute, condition string) []SelectorEvaluation {
+	var evaluations []SelectorEvaluation
+	for _, match := range resourceSelectorCondition.FindAllStringSubmatch(condition, -1) {
+		raw, err := strconv.Unquote(match[1])
+		if err != nil {
+			continue
+		}
+
+		var selectors Selectors
+		if err := json.Unmarshal([]byte(raw), &selectors); err != nil {
+			continue
+		}
+
+		for _, pair := range resourceSelectorPairs(attr, selectors) {
+			if !pair.present && len(pair.selectors) == 0 {
+				continue
+			}
+
+			evaluation := SelectorEvaluation{
+				ResourceType: pair.resourceType,
+				Present:      pair.present,
+				Matched:      matchResourceSelectorPair(pair),
+			}
+			for _, rs := range pair.selectors {
+				evaluation.Selectors = append(evaluation.Selectors, rs.String())
+			}
+			evaluations = append(evaluations, evaluation)
+		}
+	}
+	return evaluations
+}
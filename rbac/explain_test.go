@@ -0,0 +1,94 @@
+package rbac
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/rbac/policy"
+	"github.com/flanksource/duty/types"
+)
+
+func TestExplain(t *testing.T) {
+	selector := (&models.Permission{
+		ObjectSelector: types.JSON(`{"playbooks":[{"name":"restart-*"}]}`),
+	}).Condition()
+
+	enforcer, err := NewEnforcer(`
+p, editor, *, playbook:run, allow, ` + csvQuote(selector) + `, restart
+p, johndoe, *, playbook:run, deny, r.obj.Playbook.Name == 'restart-database', no-database
+p, viewer, catalog, read, allow, , na
+g, johndoe, editor
+g, editor, viewer
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restart := func(name string) *models.ABACAttribute {
+		return &models.ABACAttribute{Playbook: models.Playbook{ID: uuid.New(), Name: name}}
+	}
+
+	t.Run("allowed through a role", func(t *testing.T) {
+		e, err := explain(enforcer, "johndoe", restart("restart-deployment"), policy.ActionPlaybookRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !e.Allowed || e.Decision != DecisionAllowed || e.DenyWon {
+			t.Fatalf("expected an allow, got %+v", e)
+		}
+		if len(e.Roles) != 2 || len(e.Roles[1]) != 3 || e.Roles[1][2] != "viewer" {
+			t.Errorf("expected johndoe -> editor -> viewer, got %v", e.Roles)
+		}
+		if len(e.Policies) != 2 {
+			t.Fatalf("expected 2 policies, got %+v", e.Policies)
+		}
+
+		allow := e.Policies[1]
+		if allow.ID != "restart" || !allow.Matched || len(allow.Via) != 2 || allow.Via[1] != "editor" {
+			t.Errorf("expected the editor policy to match, got %+v", allow)
+		}
+		if len(allow.Selectors) != 1 || allow.Selectors[0].ResourceType != "playbooks" || !allow.Selectors[0].Matched {
+			t.Errorf("expected the playbook selector to match, got %+v", allow.Selectors)
+		}
+	})
+
+	t.Run("explicit deny wins", func(t *testing.T) {
+		e, err := explain(enforcer, "johndoe", restart("restart-database"), policy.ActionPlaybookRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Allowed || e.Decision != DecisionDenied || !e.DenyWon {
+			t.Fatalf("expected the deny to win, got %+v", e)
+		}
+	})
+
+	t.Run("selector does not match", func(t *testing.T) {
+		e, err := explain(enforcer, "johndoe", restart("delete-deployment"), policy.ActionPlaybookRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Allowed || e.Decision != DecisionNoMatch {
+			t.Fatalf("expected no matching policy, got %+v", e)
+		}
+		if selectors := e.Policies[1].Selectors; len(selectors) != 1 || selectors[0].Matched || !selectors[0].Present {
+			t.Errorf("expected the playbook selector not to match, got %+v", selectors)
+		}
+	})
+
+	t.Run("rbac objects", func(t *testing.T) {
+		e, err := explain(enforcer, "johndoe", policy.ObjectCatalog, policy.ActionRead)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !e.Allowed || len(e.Policies) != 1 || e.Policies[0].Subject != "viewer" {
+			t.Fatalf("expected the viewer policy to allow, got %+v", e)
+		}
+	})
+}
+
+func csvQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
@@ -0,0 +1,357 @@
+package rbac
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/flanksource/duty/api"
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/types"
+)
+
+// SourceJIT is the source of the permissions created by approved grants.
+const SourceJIT = "JIT"
+
+const permissionGrantRequested = "requested"
+
+// GrantRequest asks for time-bounded access to an object.
+type GrantRequest struct {
+	Subject        string                       `json:"subject"`
+	SubjectType    models.PermissionSubjectType `json:"subject_type,omitempty"`
+	Action         string                       `json:"action"`
+	Object         string                       `json:"object,omitempty"`
+	ObjectSelector types.JSON                   `json:"object_selector,omitempty"`
+	Duration       time.Duration                `json:"duration"`
+	Reason         string                       `json:"reason,omitempty"`
+}
+
+func (req GrantRequest) Validate(ctx context.Context) error {
+	if strings.TrimSpace(req.Subject) == "" {
+		return api.Errorf(api.EINVALID, "subject is required")
+	}
+	if req.Action == "" {
+		return api.Errorf(api.EINVALID, "action is required")
+	}
+	if (req.Object == "") == (len(req.ObjectSelector) == 0) {
+		return api.Errorf(api.EINVALID, "exactly one of object or object_selector is required")
+	}
+	if req.Duration <= 0 {
+		return api.Errorf(api.EINVALID, "duration must be positive")
+	}
+	if maxDuration := ctx.Properties().Duration("rbac.grants.max_duration", 24*time.Hour); req.Duration > maxDuration {
+		return api.Errorf(api.EINVALID, "duration must not exceed %s", maxDuration)
+	}
+	return nil
+}
+
+// RequestGrant records a pending request for time-bounded access.
+func RequestGrant(ctx context.Context, req GrantRequest, requestedBy *uuid.UUID) (*models.PermissionGrant, error) {
+	if err := req.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	grant := models.PermissionGrant{
+		Subject:        req.Subject,
+		SubjectType:    lo.CoalesceOrEmpty(req.SubjectType, models.PermissionSubjectTypePerson),
+		Action:         req.Action,
+		Object:         req.Object,
+		ObjectSelector: req.ObjectSelector,
+		Duration:       req.Duration.String(),
+		Reason:         req.Reason,
+		Status:         models.PermissionGrantStatusPending,
+		RequestedBy:    requestedBy,
+	}
+
+	err := ctx.DB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&grant).Error; err != nil {
+			return err
+		}
+		return recordGrantEvent(tx, grant.ID, permissionGrantRequested, requestedBy, req.Reason)
+	})
+	if err != nil {
+		return nil, ctx.Oops().Wrapf(err, "failed to request grant")
+	}
+	return &grant, nil
+}
+
+// ApproveGrant approves a pending grant, creates the permission that backs it
+// and adds it to the enforcer until the grant expires.
+// A grant cannot be approved by the person who requested it.
+func ApproveGrant(ctx context.Context, grantID, approver uuid.UUID) (*models.PermissionGrant, error) {
+	var grant models.PermissionGrant
+	var permission models.Permission
+	err := ctx.DB().Transaction(func(tx *gorm.DB) error {
+		if err := lockGrant(tx, grantID, &grant); err != nil {
+			return err
+		}
+		if grant.Status != models.PermissionGrantStatusPending {
+			return api.Errorf(api.ECONFLICT, "grant is %s", grant.Status)
+		}
+		if grant.RequestedBy != nil && *grant.RequestedBy == approver {
+			return api.Errorf(api.EFORBIDDEN, "grants cannot be approved by their requester")
+		}
+
+		duration, err := time.ParseDuration(grant.Duration)
+		if err != nil {
+			return api.Errorf(api.EINVALID, "invalid grant duration %q", grant.Duration)
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(duration)
+		permission = models.Permission{
+			Name:           fmt.Sprintf("jit-%s", grant.ID),
+			Description:    lo.CoalesceOrEmpty(grant.Reason, "just-in-time grant"),
+			Source:         SourceJIT,
+			Subject:        grant.Subject,
+			SubjectType:    grant.SubjectType,
+			Action:         grant.Action,
+			Object:         grant.Object,
+			ObjectSelector: grant.ObjectSelector,
+			Until:          &expiresAt,
+			CreatedBy:      &approver,
+		}
+		if err := tx.Create(&permission).Error; err != nil {
+			return err
+		}
+
+		grant.Status = models.PermissionGrantStatusApproved
+		grant.ApprovedBy, grant.ApprovedAt, grant.ExpiresAt = &approver, &now, &expiresAt
+		grant.PermissionID = &permission.ID
+		if err := tx.Save(&grant).Error; err != nil {
+			return err
+		}
+		return recordGrantEvent(tx, grant.ID, models.PermissionGrantStatusApproved, &approver, fmt.Sprintf("expires at %s", expiresAt.Format(time.RFC3339)))
+	})
+	if err != nil {
+		return nil, grantError(ctx, err, "approve")
+	}
+
+	if enforcer != nil {
+		if _, err := enforcer.AddPolicy(permissionPolicy(permission)); err != nil {
+			return &grant, ctx.Oops().Wrapf(err, "failed to add grant %s to the enforcer", grant.ID)
+		}
+		invalidateEnforcerCache(ctx)
+	}
+	grantTimers.schedule(ctx, grant)
+	return &grant, nil
+}
+
+// RejectGrant rejects a pending grant.
+func RejectGrant(ctx context.Context, grantID, approver uuid.UUID, reason string) error {
+	err := ctx.DB().Transaction(func(tx *gorm.DB) error {
+		var grant models.PermissionGrant
+		if err := lockGrant(tx, grantID, &grant); err != nil {
+			return err
+		}
+		if grant.Status != models.PermissionGrantStatusPending {
+			return api.Errorf(api.ECONFLICT, "grant is %s", grant.Status)
+		}
+		if err := tx.Model(&grant).Update("status", models.PermissionGrantStatusRejected).Error; err != nil {
+			return err
+		}
+		return recordGrantEvent(tx, grant.ID, models.PermissionGrantStatusRejected, &approver, reason)
+	})
+	return grantError(ctx, err, "reject")
+}
+
+// RevokeGrant ends an approved grant before it expires.
+func RevokeGrant(ctx context.Context, grantID uuid.UUID, actor *uuid.UUID) error {
+	return endGrant(ctx, grantID, models.PermissionGrantStatusRevoked, actor)
+}
+
+// ExpireGrants ends the approved grants that have expired, and schedules the ones that
+// expire before the next run so that they are revoked on time.
+// It also removes any other expired permission from the enforcer, which otherwise
+// only drops them when the policy is reloaded, and the rules of expired or deleted
+// permissions that are still saved in casbin_rule, e.g. when ending their grant
+// failed to remove them.
+func ExpireGrants(ctx context.Context, lookahead time.Duration) (int, error) {
+	var grants []models.PermissionGrant
+	if err := ctx.DB().Where("status = ? AND expires_at < ?", models.PermissionGrantStatusApproved, time.Now().Add(lookahead)).
+		Find(&grants).Error; err != nil {
+		return 0, ctx.Oops().Wrapf(err, "failed to list expiring grants")
+	}
+
+	var expired int
+	var errs []error
+	for _, grant := range grants {
+		if grant.ExpiresAt.After(time.Now()) {
+			grantTimers.schedule(ctx, grant)
+			continue
+		}
+		if err := endGrant(ctx, grant.ID, models.PermissionGrantStatusExpired, nil); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		expired++
+	}
+
+	if enforcer != nil {
+		// Permissions that expired longer ago were dropped when the policy was last reloaded,
+		// unless their rules were saved to casbin_rule which is reloaded with the policy
+		var permissionIDs []string
+		if err := ctx.DB().Model(&models.Permission{}).
+			Where("(until < NOW() AND until > ?) OR ((until < NOW() OR deleted_at IS NOT NULL) AND id::text IN (SELECT v5 FROM casbin_rule WHERE ptype = 'p'))", time.Now().Add(-2*lookahead)).
+			Pluck("id", &permissionIDs).Error; err != nil {
+			errs = append(errs, err)
+		}
+		for _, id := range permissionIDs {
+			if _, err := enforcer.RemoveFilteredPolicy(5, id); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(permissionIDs) > 0 {
+			invalidateEnforcerCache(ctx)
+		}
+	}
+
+	return expired, errors.Join(errs...)
+}
+
+// accessExpiry returns when the access of a subject to an object expires, if the
+// permission that allows it is time-bounded.
+func accessExpiry(ctx context.Context, subject string, object any, action string) (*time.Time, error) {
+	if enforcer == nil {
+		return nil, nil
+	}
+
+	allowed, rule, err := enforcer.EnforceEx(subject, object, action)
+	if err != nil || !allowed || len(rule) < 6 {
+		return nil, err
+	}
+	if _, err := uuid.Parse(rule[5]); err != nil {
+		// default policies have no permission ID
+		return nil, nil
+	}
+
+	var until []time.Time
+	if err := ctx.DB().Model(&models.Permission{}).Where("id = ? AND until IS NOT NULL", rule[5]).Pluck("until", &until).Error; err != nil {
+		return nil, err
+	}
+	if len(until) == 0 {
+		return nil, nil
+	}
+	return &until[0], nil
+}
+
+// endGrant revokes or expires an approved grant: it deletes its permission,
+// removes it from the enforcer and records the event.
+func endGrant(ctx context.Context, grantID uuid.UUID, status string, actor *uuid.UUID) error {
+	var grant models.PermissionGrant
+	err := ctx.DB().Transaction(func(tx *gorm.DB) error {
+		if err := lockGrant(tx, grantID, &grant); err != nil {
+			return err
+		}
+		if grant.Status != models.PermissionGrantStatusApproved {
+			return api.Errorf(api.ECONFLICT, "grant is %s", grant.Status)
+		}
+
+		now := time.Now()
+		if err := tx.Model(&grant).Updates(map[string]any{"status": status, "revoked_by": actor, "revoked_at": now}).Error; err != nil {
+			return err
+		}
+		if grant.PermissionID != nil {
+			if err := tx.Model(&models.Permission{}).Where("id = ?", *grant.PermissionID).Update("deleted_at", now).Error; err != nil {
+				return err
+			}
+		}
+		return recordGrantEvent(tx, grant.ID, status, actor, "")
+	})
+	if err != nil {
+		return grantError(ctx, err, status)
+	}
+
+	grantTimers.cancel(grant.ID)
+	if enforcer != nil && grant.PermissionID != nil {
+		if _, err := enforcer.RemoveFilteredPolicy(5, grant.PermissionID.String()); err != nil {
+			return ctx.Oops().Wrapf(err, "failed to remove grant %s from the enforcer", grant.ID)
+		}
+		invalidateEnforcerCache(ctx)
+	}
+	return nil
+}
+
+// invalidateEnforcerCache drops the cached decisions, as they are cached per request
+// and would otherwise outlive the policies that were added or removed.
+func invalidateEnforcerCache(ctx context.Context) {
+	if err := enforcer.InvalidateCache(); err != nil {
+		ctx.Warnf("failed to invalidate the rbac cache: %v", err)
+	}
+}
+
+func lockGrant(tx *gorm.DB, grantID uuid.UUID, grant *models.PermissionGrant) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", grantID).First(grant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return api.Errorf(api.ENOTFOUND, "grant %s not found", grantID)
+		}
+		return err
+	}
+	return nil
+}
+
+func recordGrantEvent(tx *gorm.DB, grantID uuid.UUID, event string, actor *uuid.UUID, details string) error {
+	return tx.Create(&models.PermissionGrantEvent{GrantID: grantID, Event: event, Actor: actor, Details: details}).Error
+}
+
+func grantError(ctx context.Context, err error, verb string) error {
+	if err == nil || api.ErrorCode(err) != api.EINTERNAL {
+		return err
+	}
+	return ctx.Oops().Wrapf(err, "failed to %s grant", verb)
+}
+
+// permissionPolicy returns the enforcer policy of a permission.
+func permissionPolicy(p models.Permission) []string {
+	return []string{p.Principal(), p.GetObject(), p.Action, p.Effect(), p.Condition(), p.ID.String()}
+}
+
+// grantTimers revokes approved grants when they expire.
+var grantTimers = &grantTimerRegistry{timers: make(map[uuid.UUID]*time.Timer)}
+
+type grantTimerRegistry struct {
+	mu     sync.Mutex
+	timers map[uuid.UUID]*time.Timer
+}
+
+func (r *grantTimerRegistry) schedule(ctx context.Context, grant models.PermissionGrant) {
+	if grant.ExpiresAt == nil {
+		return
+	}
+
+	// The grant may be approved within a request, which is done long before the grant expires
+	cloned := ctx.Context.Clone()
+	cloned.Context = gocontext.WithoutCancel(ctx.Context)
+	ctx = context.Context{Context: cloned}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.timers[grant.ID]; ok {
+		return
+	}
+
+	r.timers[grant.ID] = time.AfterFunc(time.Until(*grant.ExpiresAt), func() {
+		r.cancel(grant.ID)
+		if err := endGrant(ctx, grant.ID, models.PermissionGrantStatusExpired, nil); err != nil && api.ErrorCode(err) != api.ECONFLICT {
+			ctx.Errorf("failed to expire grant %s: %v", grant.ID, err)
+		}
+	})
+}
+
+func (r *grantTimerRegistry) cancel(grantID uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if timer, ok := r.timers[grantID]; ok {
+		timer.Stop()
+		delete(r.timers, grantID)
+	}
+}
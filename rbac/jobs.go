@@ -0,0 +1,24 @@
+package rbac
+
+import (
+	"time"
+
+	"github.com/flanksource/duty/job"
+)
+
+// ExpirePermissionGrants revokes expired grants that were missed, e.g. during a restart,
+// and schedules the revocation of the grants that expire before its next run.
+var ExpirePermissionGrants = &job.Job{
+	Name:       "ExpirePermissionGrants",
+	Schedule:   "@every 1m",
+	Singleton:  true,
+	JobHistory: true,
+	Retention:  job.RetentionFew,
+	Fn: func(ctx job.JobRuntime) error {
+		expired, err := ExpireGrants(ctx.Context, 2*time.Minute)
+		ctx.History.SuccessCount = expired
+		return err
+	},
+}
+
+var Jobs = []*job.Job{ExpirePermissionGrants}
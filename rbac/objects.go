@@ -78,6 +78,7 @@ var dbResourceObjMap = map[string]string{
 	"config_items_aws":                          policy.ObjectCatalog,
 	"config_items":                              policy.ObjectCatalog,
 	"config_items_last_scraped_time":            policy.ObjectCatalog,
+	"config_items_masked":                       policy.ObjectCatalog,
 	"config_labels":                             policy.ObjectDatabasePublic,
 	"config_names":                              policy.ObjectDatabasePublic,
 	"config_relationships":                      policy.ObjectCatalog,
@@ -99,6 +100,7 @@ var dbResourceObjMap = map[string]string{
 	"courier_messages":                          policy.ObjectAuthConfidential,
 	"event_queue_summary":                       policy.ObjectMonitor,
 	"event_queue":                               policy.ObjectDatabaseSystem,
+	"event_queue_dead_letters":                  policy.ObjectDatabaseSystem,
 	"evidences":                                 policy.ObjectIncident,
 	"failed_events":                             policy.ObjectMonitor,
 	"hypotheses":                                policy.ObjectIncident,
@@ -128,6 +130,7 @@ var dbResourceObjMap = map[string]string{
 	"job_history_names":                         policy.ObjectMonitor,
 	"job_history_summary":                       policy.ObjectMonitor,
 	"job_history":                               policy.ObjectMonitor,
+	"job_locks":                                 policy.ObjectMonitor,
 	"logging_backends":                          policy.ObjectDatabaseSettings,
 	"migration_logs":                            policy.ObjectDatabaseSystem,
 	"networks":                                  policy.ObjectAuthConfidential,
@@ -229,6 +232,9 @@ var dbResourceObjMap = map[string]string{
 	// permission
 	"permissions":               policy.ObjectDatabaseSystem,
 	"permission_groups":         policy.ObjectDatabaseSystem,
+	"permission_grants":         policy.ObjectDatabaseSystem,
+	"permission_grant_events":   policy.ObjectDatabaseSystem,
+	"config_masks":              policy.ObjectDatabaseSystem,
 	"permission_subjects":       policy.ObjectDatabaseSystem,
 	"permissions_summary":       policy.ObjectDatabaseSystem,
 	"permissions_group_summary": policy.ObjectDatabaseSystem,
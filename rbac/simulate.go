@@ -0,0 +1,278 @@
+package rbac
+
+import (
+	"strings"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+
+	"github.com/flanksource/duty/api"
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/rbac/policy"
+)
+
+// SimulationRequest proposes permission changes to evaluate without applying them.
+type SimulationRequest struct {
+	// Add and Remove are the proposed permission changes.
+	// Permissions to remove are matched by ID when it is set, otherwise by their policy.
+	Add    []models.Permission `json:"add,omitempty"`
+	Remove []models.Permission `json:"remove,omitempty"`
+
+	// Supports ["*"], in which case we iterate over all permission subjects in the database.
+	Subjects []string `json:"subjects"`
+
+	// Actions to evaluate. Defaults to read.
+	Actions []string `json:"actions,omitempty"`
+
+	// ResourceTypes to evaluate: config, component, playbook and view. Defaults to all of them.
+	ResourceTypes []string `json:"resource_types,omitempty"`
+}
+
+// AccessChange is access to a resource that a subject gains or loses.
+type AccessChange struct {
+	Subject      string `json:"subject"`
+	Action       string `json:"action"`
+	ResourceType string `json:"resource_type"`
+	ID           string `json:"id"`
+	Name         string `json:"name,omitempty"`
+}
+
+// SimulationReport is the difference in effective access caused by the proposed changes.
+type SimulationReport struct {
+	Gained []AccessChange `json:"gained"`
+	Lost   []AccessChange `json:"lost"`
+
+	// Evaluated is the number of subject, action and resource combinations checked.
+	Evaluated int `json:"evaluated"`
+
+	// Truncated lists the resource types that had more resources than were evaluated.
+	Truncated []string `json:"truncated,omitempty"`
+}
+
+var simulationResourceTypes = []string{"config", "component", "playbook", "view"}
+
+func (req *SimulationRequest) Validate() error {
+	if len(req.Add) == 0 && len(req.Remove) == 0 {
+		return api.Errorf(api.EINVALID, "at least one permission to add or remove is required")
+	}
+	if len(req.Subjects) == 0 {
+		return api.Errorf(api.EINVALID, "at least one subject is required")
+	}
+
+	if len(req.Actions) == 0 {
+		req.Actions = []string{policy.ActionRead}
+	}
+	for _, action := range req.Actions {
+		if !lo.Contains(policy.AllActions, action) {
+			return api.Errorf(api.EINVALID, "unsupported action %q, only %s are supported", action, strings.Join(policy.AllActions, ", "))
+		}
+	}
+
+	if len(req.ResourceTypes) == 0 {
+		req.ResourceTypes = simulationResourceTypes
+	}
+	for i, resourceType := range req.ResourceTypes {
+		req.ResourceTypes[i] = strings.ToLower(strings.TrimSpace(resourceType))
+		if !lo.Contains(simulationResourceTypes, req.ResourceTypes[i]) {
+			return api.Errorf(api.EINVALID, "unsupported resource_type %q, only %s are supported", resourceType, strings.Join(simulationResourceTypes, ", "))
+		}
+	}
+	req.ResourceTypes = lo.Uniq(req.ResourceTypes)
+
+	return nil
+}
+
+// SimulatePermissionChanges evaluates the access of subjects before and after the proposed
+// permission changes, on in-memory copies of the live enforcer, and reports the difference.
+func SimulatePermissionChanges(ctx context.Context, req SimulationRequest) (*SimulationReport, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if enforcer == nil {
+		return nil, api.Errorf(api.EINVALID, "rbac is not initialized")
+	}
+
+	subjects, err := resolveAccessReviewSubjects(ctx, req.Subjects)
+	if err != nil {
+		return nil, err
+	} else if len(subjects) > MaxSubjectAccessReviewSubjects {
+		return nil, api.Errorf(api.EINVALID, "subjects exceeds maximum of %d", MaxSubjectAccessReviewSubjects)
+	}
+
+	before, err := cloneEnforcer(enforcer, subjects)
+	if err != nil {
+		return nil, ctx.Oops().Wrapf(err, "failed to clone enforcer")
+	}
+	after, err := cloneEnforcer(enforcer, subjects)
+	if err != nil {
+		return nil, ctx.Oops().Wrapf(err, "failed to clone enforcer")
+	}
+	if err := applyPermissionChanges(after, req.Add, req.Remove); err != nil {
+		return nil, err
+	}
+
+	report := &SimulationReport{Gained: []AccessChange{}, Lost: []AccessChange{}}
+	limit := ctx.Properties().Int("rbac.simulation.max_objects", 500)
+	for _, resourceType := range req.ResourceTypes {
+		resources, truncated, err := listSimulationResources(ctx, resourceType, limit)
+		if err != nil {
+			return nil, err
+		}
+		if truncated {
+			report.Truncated = append(report.Truncated, resourceType)
+		}
+
+		for _, resource := range resources {
+			for _, subject := range subjects {
+				for _, action := range req.Actions {
+					wasAllowed := simulateEnforce(ctx, before, subject, resource.attr, action)
+					isAllowed := simulateEnforce(ctx, after, subject, resource.attr, action)
+					report.Evaluated++
+
+					if wasAllowed == isAllowed {
+						continue
+					}
+					change := AccessChange{Subject: subject, Action: action, ResourceType: resourceType, ID: resource.id, Name: resource.name}
+					if isAllowed {
+						report.Gained = append(report.Gained, change)
+					} else {
+						report.Lost = append(report.Lost, change)
+					}
+				}
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// cloneEnforcer copies the policies of an enforcer into a new in-memory enforcer.
+// Subjects are given the everyone role, as Check does for the live enforcer.
+func cloneEnforcer(source casbin.IEnforcer, subjects []string) (*casbin.SyncedCachedEnforcer, error) {
+	m, err := model.NewModelFromString(DefaultModel)
+	if err != nil {
+		return nil, err
+	}
+
+	clone, err := casbin.NewSyncedCachedEnforcer(m)
+	if err != nil {
+		return nil, err
+	}
+	clone.EnableCache(false)
+	AddCustomFunctions(clone)
+
+	policies, err := source.GetPolicy()
+	if err != nil {
+		return nil, err
+	}
+	groupings, err := source.GetGroupingPolicy()
+	if err != nil {
+		return nil, err
+	}
+	for _, subject := range subjects {
+		groupings = append(groupings, []string{subject, policy.RoleEveryone})
+	}
+
+	if len(policies) > 0 {
+		if _, err := clone.AddPolicies(policies); err != nil {
+			return nil, err
+		}
+	}
+	if len(groupings) > 0 {
+		if _, err := clone.AddGroupingPolicies(groupings); err != nil {
+			return nil, err
+		}
+	}
+	return clone, nil
+}
+
+// simulateEnforce denies access when a condition fails to evaluate, as Check does.
+func simulateEnforce(ctx context.Context, e *casbin.SyncedCachedEnforcer, subject string, attr *models.ABACAttribute, action string) bool {
+	allowed, err := e.Enforce(subject, attr, action)
+	if err != nil {
+		ctx.Debugf("error simulating abac for subject=%s action=%s: %v", subject, action, err)
+		return false
+	}
+	return allowed
+}
+
+func applyPermissionChanges(e *casbin.SyncedCachedEnforcer, add, remove []models.Permission) error {
+	for _, p := range remove {
+		var err error
+		if p.ID != uuid.Nil {
+			_, err = e.RemoveFilteredPolicy(5, p.ID.String())
+		} else {
+			_, err = e.RemovePolicy(permissionPolicy(p))
+		}
+		if err != nil {
+			return api.Errorf(api.EINVALID, "failed to remove permission %s: %v", p.Name, err)
+		}
+	}
+
+	for i, p := range add {
+		if p.Principal() == "" {
+			return api.Errorf(api.EINVALID, "permission %d to add has no subject", i)
+		}
+		if _, err := e.AddPolicy(permissionPolicy(p)); err != nil {
+			return api.Errorf(api.EINVALID, "failed to add permission %s: %v", p.Name, err)
+		}
+	}
+	return nil
+}
+
+type simulationResource struct {
+	id, name string
+	attr     *models.ABACAttribute
+}
+
+// listSimulationResources returns up to limit resources of a type,
+// and whether there were more.
+func listSimulationResources(ctx context.Context, resourceType string, limit int) ([]simulationResource, bool, error) {
+	var resources []simulationResource
+	query := ctx.DB().Where("deleted_at IS NULL").Order("id").Limit(limit + 1)
+
+	var err error
+	switch resourceType {
+	case "config":
+		var configs []models.ConfigItem
+		if err = query.Find(&configs).Error; err == nil {
+			for _, c := range configs {
+				resources = append(resources, simulationResource{id: c.ID.String(), name: lo.FromPtr(c.Name), attr: &models.ABACAttribute{Config: c}})
+			}
+		}
+	case "component":
+		var components []models.Component
+		if err = query.Find(&components).Error; err == nil {
+			for _, c := range components {
+				resources = append(resources, simulationResource{id: c.ID.String(), name: c.Name, attr: &models.ABACAttribute{Component: c}})
+			}
+		}
+	case "playbook":
+		var playbooks []models.Playbook
+		if err = query.Find(&playbooks).Error; err == nil {
+			for _, p := range playbooks {
+				resources = append(resources, simulationResource{id: p.ID.String(), name: p.Name, attr: &models.ABACAttribute{Playbook: p}})
+			}
+		}
+	case "view":
+		var views []models.View
+		if err = query.Find(&views).Error; err == nil {
+			for _, v := range views {
+				resources = append(resources, simulationResource{id: v.ID.String(), name: v.Name, attr: &models.ABACAttribute{View: v}})
+			}
+		}
+	default:
+		return nil, false, api.Errorf(api.EINVALID, "unsupported resource_type %q", resourceType)
+	}
+	if err != nil {
+		return nil, false, ctx.Oops().Wrapf(err, "failed to list %s resources", resourceType)
+	}
+
+	if len(resources) > limit {
+		return resources[:limit], true, nil
+	}
+	return resources, false, nil
+}
@@ -0,0 +1,74 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/rbac/policy"
+)
+
+func TestSimulatedPermissionChanges(t *testing.T) {
+	readConfigs := uuid.New()
+	source, err := NewEnforcer(`
+p, bob, *, read, allow, r.obj.Config.Tags.namespace == 'default', ` + readConfigs.String() + `
+p, alice, catalog, read, allow, , na
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := cloneEnforcer(source, []string{"bob", "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := cloneEnforcer(source, []string{"bob", "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	playbookID := uuid.New()
+	add := []models.Permission{{
+		ID:         uuid.New(),
+		Subject:    "alice",
+		Action:     policy.ActionRead,
+		PlaybookID: &playbookID,
+	}}
+	remove := []models.Permission{{ID: readConfigs}}
+	if err := applyPermissionChanges(after, add, remove); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &models.ABACAttribute{Config: models.ConfigItem{Tags: map[string]string{"namespace": "default"}}}
+	playbook := &models.ABACAttribute{Playbook: models.Playbook{ID: playbookID, Name: "echo"}}
+
+	testData := []struct {
+		description   string
+		subject       string
+		obj           any
+		before, after bool
+	}{
+		{"removed permission is lost", "bob", config, true, false},
+		{"added permission is gained", "alice", playbook, false, true},
+		{"other subjects are unaffected", "bob", playbook, false, false},
+		{"unchanged permissions are kept", "alice", policy.ObjectCatalog, true, true},
+	}
+
+	for _, td := range testData {
+		t.Run(td.description, func(t *testing.T) {
+			// Conditions that fail to evaluate deny access
+			if allowed, _ := before.Enforce(td.subject, td.obj, policy.ActionRead); allowed != td.before {
+				t.Errorf("expected %v before the changes, got %v", td.before, allowed)
+			}
+			if allowed, _ := after.Enforce(td.subject, td.obj, policy.ActionRead); allowed != td.after {
+				t.Errorf("expected %v after the changes, got %v", td.after, allowed)
+			}
+		})
+	}
+
+	// The live policies must not be modified
+	if policies, _ := source.GetPolicy(); len(policies) != 2 {
+		t.Errorf("expected the source enforcer to keep its 2 policies, got %d", len(policies))
+	}
+}
@@ -0,0 +1,120 @@
+package rls
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/flanksource/commons/collections"
+)
+
+// MaskedValue replaces the value of masked config fields
+const MaskedValue = "***"
+
+// ConfigMask redacts fields of the config of config items.
+type ConfigMask struct {
+	// Types of config items the mask applies to, with * wildcards and ! exclusions.
+	// The mask applies to all config types when empty.
+	Types []string `json:"types,omitempty"`
+
+	// Paths to redact as JSONPaths,
+	// e.g. $.data.*, $.spec.containers[*].env[*].value
+	Paths []string `json:"paths"`
+}
+
+// Matches reports whether the mask applies to the config type,
+// like config_mask_matches_type() in functions/config_masks.sql.
+func (m ConfigMask) Matches(configType string) bool {
+	return collections.MatchItems(configType, m.Types...)
+}
+
+var (
+	maskPathWildcardIndex = regexp.MustCompile(`\[\*\]`)
+	maskPathIndex         = regexp.MustCompile(`\[(\d+)\]`)
+)
+
+// maskPathKeys splits a JSONPath into its keys, like config_mask_path_keys() in functions/config_masks.sql.
+func maskPathKeys(path string) []string {
+	path = strings.TrimPrefix(path, "$")
+	path = maskPathWildcardIndex.ReplaceAllString(path, ".*")
+	path = maskPathIndex.ReplaceAllString(path, ".$1")
+	path = strings.Trim(path, ".")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// MaskConfig redacts the fields of a config that are masked for the config type.
+// It returns the masked config and the paths of the fields that were masked.
+func (t Payload) MaskConfig(configType, config string) (string, []string, error) {
+	if t.Disable || config == "" {
+		return config, nil, nil
+	}
+
+	var paths [][]string
+	for _, mask := range t.ConfigMasks {
+		if !mask.Matches(configType) {
+			continue
+		}
+		for _, path := range mask.Paths {
+			if keys := maskPathKeys(path); len(keys) > 0 {
+				paths = append(paths, keys)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return config, nil, nil
+	}
+
+	var doc any
+	if err := json.Unmarshal([]byte(config), &doc); err != nil {
+		return "", nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	var masked []string
+	for _, keys := range paths {
+		doc = maskJSON(doc, keys, "", &masked)
+	}
+	if len(masked) == 0 {
+		return config, nil, nil
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal masked config: %w", err)
+	}
+
+	slices.Sort(masked)
+	return string(out), slices.Compact(masked), nil
+}
+
+func maskJSON(doc any, keys []string, prefix string, masked *[]string) any {
+	key, rest := keys[0], keys[1:]
+	apply := func(path string, value any) any {
+		if len(rest) == 0 {
+			*masked = append(*masked, path)
+			return MaskedValue
+		}
+		return maskJSON(value, rest, path+".", masked)
+	}
+
+	switch v := doc.(type) {
+	case map[string]any:
+		for k, value := range v {
+			if key == "*" || key == k {
+				v[k] = apply(prefix+k, value)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			if key == "*" || key == strconv.Itoa(i) {
+				v[i] = apply(prefix+strconv.Itoa(i), value)
+			}
+		}
+	}
+	return doc
+}
@@ -0,0 +1,77 @@
+package rls
+
+import (
+	"os"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+type configMaskCase struct {
+	Name   string       `yaml:"name"`
+	Type   string       `yaml:"type"`
+	Config string       `yaml:"config"`
+	Masks  []ConfigMask `yaml:"masks"`
+	Masked string       `yaml:"masked"`
+	Paths  []string     `yaml:"paths"`
+}
+
+// The same cases are run against mask_config() in functions/config_masks.sql by tests/config_masks_test.go
+func TestPayload_MaskConfig(t *testing.T) {
+	data, err := os.ReadFile("testdata/config_masks.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixture struct {
+		Cases []configMaskCase `yaml:"cases"`
+	}
+	if err := yaml.Unmarshal(data, &fixture); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range fixture.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+
+			masked, paths, err := Payload{ConfigMasks: tc.Masks}.MaskConfig(tc.Type, tc.Config)
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			g.Expect(masked).To(gomega.MatchJSON(tc.Masked))
+			if len(tc.Paths) == 0 {
+				g.Expect(paths).To(gomega.BeEmpty())
+			} else {
+				g.Expect(paths).To(gomega.Equal(tc.Paths))
+			}
+		})
+	}
+
+	t.Run("should not mask when RLS is disabled", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		const config = `{"data":{"password":"hunter2"}}`
+		payload := Payload{Disable: true, ConfigMasks: []ConfigMask{{Paths: []string{"$.data"}}}}
+		masked, paths, err := payload.MaskConfig("Kubernetes::ConfigMap", config)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(paths).To(gomega.BeEmpty())
+		g.Expect(masked).To(gomega.Equal(config))
+	})
+}
+
+func TestConfigMask_Matches(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(ConfigMask{}.Matches("Kubernetes::Secret")).To(gomega.BeTrue())
+	g.Expect(ConfigMask{Types: []string{"Kubernetes::*", "!Kubernetes::ConfigMap"}}.Matches("Kubernetes::Secret")).To(gomega.BeTrue())
+	g.Expect(ConfigMask{Types: []string{"Kubernetes::*", "!Kubernetes::ConfigMap"}}.Matches("Kubernetes::ConfigMap")).To(gomega.BeFalse())
+	g.Expect(ConfigMask{Types: []string{"!*::ConfigMap"}}.Matches("Kubernetes::Secret")).To(gomega.BeTrue())
+	g.Expect(ConfigMask{Types: []string{"!*::ConfigMap"}}.Matches("Kubernetes::ConfigMap")).To(gomega.BeFalse())
+	g.Expect(ConfigMask{Types: []string{"AWS::*"}}.Matches("Kubernetes::Secret")).To(gomega.BeFalse())
+}
+
+func TestMaskPathKeys(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(maskPathKeys("$.spec.containers[*].env[2].value")).To(gomega.Equal([]string{"spec", "containers", "*", "env", "2", "value"}))
+	g.Expect(maskPathKeys("data.*")).To(gomega.Equal([]string{"data", "*"}))
+	g.Expect(maskPathKeys("$")).To(gomega.BeEmpty())
+}
@@ -46,6 +46,21 @@ type Payload struct {
 	Canary    []Scope `json:"canary,omitempty"`
 	View      []Scope `json:"view,omitempty"`
 
+	// Check scopes checks by their labels, agent, name and id.
+	// Without it, checks are scoped by the canary they belong to.
+	Check []Scope `json:"check,omitempty"`
+
+	// Incident scopes incidents by their title and id.
+	// Without it, incidents are not row-filtered.
+	Incident []Scope `json:"incident,omitempty"`
+
+	// Notification scopes notifications, and their send history, by their name and id.
+	// Without it, notifications are not row-filtered.
+	Notification []Scope `json:"notification,omitempty"`
+
+	// ConfigMasks redact fields of the config of config items.
+	ConfigMasks []ConfigMask `json:"config_masks,omitempty"`
+
 	// Scopes contains the list of scope UUIDs the user has access to.
 	// This is used for generated view tables only (for now).
 	Scopes []string `json:"scopes,omitempty"`
@@ -81,6 +96,22 @@ func (t Payload) JWTClaims() map[string]any {
 		claims["view"] = t.View
 	}
 
+	if len(t.Check) > 0 {
+		claims["check"] = t.Check
+	}
+
+	if len(t.Incident) > 0 {
+		claims["incident"] = t.Incident
+	}
+
+	if len(t.Notification) > 0 {
+		claims["notification"] = t.Notification
+	}
+
+	if len(t.ConfigMasks) > 0 {
+		claims["config_masks"] = t.ConfigMasks
+	}
+
 	if len(t.Scopes) > 0 {
 		claims["scopes"] = t.Scopes
 	}
@@ -103,6 +134,26 @@ func (t *Payload) EvalFingerprint() {
 		}
 	}
 
+	// These claims replace the default policy of their tables when present,
+	// so their scopes must not collide with the same scope on another resource.
+	for name, scopeArray := range map[string][]Scope{"check": t.Check, "incident": t.Incident, "notification": t.Notification} {
+		if len(scopeArray) > 0 {
+			parts = append(parts, name)
+		}
+		for _, scope := range scopeArray {
+			if !scope.IsEmpty() {
+				parts = append(parts, name+"::"+scope.Fingerprint())
+			}
+		}
+	}
+
+	for _, mask := range t.ConfigMasks {
+		types, paths := slices.Clone(mask.Types), slices.Clone(mask.Paths)
+		slices.Sort(types)
+		slices.Sort(paths)
+		parts = append(parts, fmt.Sprintf("config_mask::types:%s | paths:%s", strings.Join(types, "--"), strings.Join(paths, "--")))
+	}
+
 	// Include scope UUIDs in fingerprint
 	if len(t.Scopes) > 0 {
 		scopesCopy := slices.Clone(t.Scopes)
@@ -88,3 +88,33 @@ func TestPayload_EvalFingerprint(t *testing.T) {
 		g.Expect(payload.Fingerprint()).To(gomega.Equal(firstFingerprint))
 	})
 }
+
+func TestPayload_ResourceClaims(t *testing.T) {
+	t.Run("should not collide with the same scope on another resource", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		scope := Scope{Names: []string{"api"}}
+		canary := &Payload{Canary: []Scope{scope}}
+		check := &Payload{Check: []Scope{scope}}
+		incident := &Payload{Incident: []Scope{scope}}
+
+		g.Expect(canary.Fingerprint()).NotTo(gomega.Equal(check.Fingerprint()))
+		g.Expect(check.Fingerprint()).NotTo(gomega.Equal(incident.Fingerprint()))
+	})
+
+	t.Run("should fingerprint claims whose scopes are all empty", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		// An empty check scope denies every check instead of falling back to the canary scopes
+		payload := &Payload{Check: []Scope{{}}}
+		g.Expect(payload.Fingerprint()).NotTo(gomega.Equal("empty"))
+		g.Expect(payload.JWTClaims()).To(gomega.HaveKey("check"))
+	})
+
+	t.Run("should only add claims that are set", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		payload := Payload{Notification: []Scope{{Names: []string{"alerts"}}}}
+		g.Expect(payload.JWTClaims()).To(gomega.Equal(map[string]any{"notification": payload.Notification}))
+	})
+}
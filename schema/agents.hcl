@@ -113,3 +113,127 @@ table "agents" {
     on_delete   = NO_ACTION
   }
 }
+
+table "upstream_pulled_objects" {
+  schema  = schema.public
+  comment = "objects pulled from the upstream by an agent, used to resume pulls and detect conflicting local edits"
+  column "table_name" {
+    null = false
+    type = text
+  }
+  column "id" {
+    null = false
+    type = text
+  }
+  column "upstream_modified_at" {
+    null = false
+    type = timestamptz
+  }
+  column "local_modified_at" {
+    null    = true
+    type    = timestamptz
+    comment = "when the local copy was last written by a pull"
+  }
+  column "status" {
+    null = false
+    type = text
+  }
+  column "error" {
+    null = true
+    type = text
+  }
+  column "attempts" {
+    null    = false
+    type    = integer
+    default = 0
+    comment = "failed attempts to apply this version of the object"
+  }
+  column "pulled_at" {
+    null    = false
+    type    = timestamptz
+    default = sql("now()")
+  }
+  primary_key {
+    columns = [column.table_name, column.id]
+  }
+  index "upstream_pulled_objects_cursor_idx" {
+    columns = [column.table_name, column.upstream_modified_at, column.id]
+  }
+}
+
+table "upstream_push_chunks" {
+  schema  = schema.public
+  comment = "idempotency keys of the push chunks saved by the upstream, to skip retried chunks"
+  column "agent_id" {
+    null = false
+    type = uuid
+  }
+  column "key" {
+    null = false
+    type = text
+  }
+  column "created_at" {
+    null    = false
+    type    = timestamptz
+    default = sql("now()")
+  }
+  primary_key {
+    columns = [column.agent_id, column.key]
+  }
+  index "upstream_push_chunks_created_at_idx" {
+    columns = [column.created_at]
+  }
+  foreign_key "upstream_push_chunks_agent_id_fkey" {
+    columns     = [column.agent_id]
+    ref_columns = [table.agents.column.id]
+    on_update   = NO_ACTION
+    on_delete   = CASCADE
+  }
+}
+
+table "upstream_spool" {
+  schema  = schema.public
+  comment = "deletes and artifact uploads queued by an agent while the upstream is unreachable"
+  column "id" {
+    null = false
+    type = bigserial
+  }
+  column "kind" {
+    null = false
+    type = text
+  }
+  column "artifact_id" {
+    null = true
+    type = uuid
+  }
+  column "payload" {
+    null    = false
+    type    = bytea
+    comment = "the push data to delete, or the artifact content"
+  }
+  column "size" {
+    null = false
+    type = bigint
+  }
+  column "attempts" {
+    null    = false
+    type    = integer
+    default = 0
+  }
+  column "error" {
+    null = true
+    type = text
+  }
+  column "created_at" {
+    null    = false
+    type    = timestamptz
+    default = sql("now()")
+  }
+  column "last_attempt" {
+    null = true
+    type = timestamptz
+  }
+  primary_key {
+    columns = [column.id]
+  }
+}
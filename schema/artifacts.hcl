@@ -66,6 +66,16 @@ table "artifacts" {
     type    = text
     comment = "compression algorithm applied to content: gzip, zstd, or none"
   }
+  column "encryption_key" {
+    null    = true
+    type    = bytea
+    comment = "per-artifact data key the content is encrypted with, wrapped by the secret keeper"
+  }
+  column "encryption_algorithm" {
+    null    = true
+    type    = text
+    comment = "algorithm the content is encrypted with; null when stored in plain text"
+  }
   column "is_pushed" {
     null    = false
     default = false
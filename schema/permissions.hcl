@@ -327,3 +327,264 @@ table "permission_groups" {
     on_delete   = NO_ACTION
   }
 }
+
+table "permission_grants" {
+  schema = schema.public
+
+  column "id" {
+    null    = false
+    type    = uuid
+    default = sql("generate_ulid()")
+  }
+
+  column "subject" {
+    null = false
+    type = text
+  }
+
+  column "subject_type" {
+    null    = false
+    type    = text
+    default = "person"
+  }
+
+  column "action" {
+    null = false
+    type = text
+  }
+
+  column "object" {
+    null = true
+    type = text
+  }
+
+  column "object_selector" {
+    null = true
+    type = jsonb
+  }
+
+  column "reason" {
+    null = true
+    type = text
+  }
+
+  column "duration" {
+    null    = false
+    type    = text
+    comment = "how long the grant lasts once approved, e.g. 2h"
+  }
+
+  column "status" {
+    null    = false
+    type    = text
+    default = "pending"
+  }
+
+  column "requested_by" {
+    null = true
+    type = uuid
+  }
+
+  column "approved_by" {
+    null = true
+    type = uuid
+  }
+
+  column "approved_at" {
+    null = true
+    type = timestamptz
+  }
+
+  column "expires_at" {
+    null = true
+    type = timestamptz
+  }
+
+  column "revoked_by" {
+    null = true
+    type = uuid
+  }
+
+  column "revoked_at" {
+    null = true
+    type = timestamptz
+  }
+
+  column "permission_id" {
+    null    = true
+    type    = uuid
+    comment = "the permission created when the grant was approved"
+  }
+
+  column "created_at" {
+    null    = false
+    type    = timestamptz
+    default = sql("now()")
+  }
+
+  primary_key {
+    columns = [column.id]
+  }
+
+  check "permission_grants_object_or_selector_check" {
+    expr = "(object_selector IS NOT NULL)::int + (NULLIF(object, '') IS NOT NULL)::int = 1"
+  }
+
+  foreign_key "permission_grants_requested_by_fkey" {
+    columns     = [column.requested_by]
+    ref_columns = [table.people.column.id]
+    on_update   = NO_ACTION
+    on_delete   = NO_ACTION
+  }
+  foreign_key "permission_grants_approved_by_fkey" {
+    columns     = [column.approved_by]
+    ref_columns = [table.people.column.id]
+    on_update   = NO_ACTION
+    on_delete   = NO_ACTION
+  }
+  foreign_key "permission_grants_revoked_by_fkey" {
+    columns     = [column.revoked_by]
+    ref_columns = [table.people.column.id]
+    on_update   = NO_ACTION
+    on_delete   = NO_ACTION
+  }
+  foreign_key "permission_grants_permission_id_fkey" {
+    columns     = [column.permission_id]
+    ref_columns = [table.permissions.column.id]
+    on_update   = NO_ACTION
+    on_delete   = SET_NULL
+  }
+
+  index "permission_grants_status_expires_at_idx" {
+    columns = [column.status, column.expires_at]
+  }
+}
+
+table "permission_grant_events" {
+  schema = schema.public
+
+  column "id" {
+    null    = false
+    type    = uuid
+    default = sql("generate_ulid()")
+  }
+
+  column "grant_id" {
+    null = false
+    type = uuid
+  }
+
+  column "event" {
+    null = false
+    type = text
+  }
+
+  column "actor" {
+    null = true
+    type = uuid
+  }
+
+  column "details" {
+    null = true
+    type = text
+  }
+
+  column "created_at" {
+    null    = false
+    type    = timestamptz
+    default = sql("now()")
+  }
+
+  primary_key {
+    columns = [column.id]
+  }
+
+  foreign_key "permission_grant_events_grant_id_fkey" {
+    columns     = [column.grant_id]
+    ref_columns = [table.permission_grants.column.id]
+    on_update   = NO_ACTION
+    on_delete   = CASCADE
+  }
+
+  index "permission_grant_events_grant_id_idx" {
+    columns = [column.grant_id, column.created_at]
+  }
+}
+
+table "config_masks" {
+  schema  = schema.public
+  comment = "redacts fields of the config of config items for the subjects with the given roles"
+
+  column "id" {
+    null    = false
+    type    = uuid
+    default = sql("generate_ulid()")
+  }
+
+  column "name" {
+    null = false
+    type = text
+  }
+
+  column "description" {
+    null = true
+    type = text
+  }
+
+  column "config_types" {
+    null    = true
+    type    = sql("text[]")
+    comment = "config types the mask applies to, with * wildcards. Applies to all types when empty."
+  }
+
+  column "roles" {
+    null    = true
+    type    = sql("text[]")
+    comment = "roles the mask applies to. Applies to everyone when empty."
+  }
+
+  column "paths" {
+    null    = false
+    type    = sql("text[]")
+    comment = "JSONPaths of the fields to redact, e.g. $.data.*"
+  }
+
+  column "created_by" {
+    null = true
+    type = uuid
+  }
+
+  column "created_at" {
+    null    = false
+    type    = timestamptz
+    default = sql("now()")
+  }
+
+  column "updated_at" {
+    null    = true
+    type    = timestamptz
+    default = sql("now()")
+  }
+
+  column "deleted_at" {
+    null = true
+    type = timestamptz
+  }
+
+  primary_key {
+    columns = [column.id]
+  }
+
+  foreign_key "config_masks_created_by_fkey" {
+    columns     = [column.created_by]
+    ref_columns = [table.people.column.id]
+    on_update   = NO_ACTION
+    on_delete   = NO_ACTION
+  }
+
+  index "config_masks_name_key" {
+    unique  = true
+    columns = [column.name]
+    where   = "deleted_at IS NULL"
+  }
+}
@@ -99,6 +99,11 @@ table "event_queue" {
     null = true
     type = timestamptz
   }
+  column "next_attempt" {
+    null    = true
+    type    = timestamptz
+    comment = "set by a retry policy or a retry-after error; overrides the default backoff after the last attempt"
+  }
   column "attempts" {
     null    = true
     type    = integer
@@ -124,6 +129,60 @@ table "event_queue" {
   }
 }
 
+table "event_queue_dead_letters" {
+  schema  = schema.public
+  comment = "events from event_queue that failed more than the consumer's max attempts"
+  column "id" {
+    null = false
+    type = uuid
+  }
+  column "event_id" {
+    null = true
+    type = uuid
+  }
+  column "name" {
+    null = false
+    type = text
+  }
+  column "properties" {
+    null = true
+    type = jsonb
+  }
+  column "error" {
+    null = true
+    type = text
+  }
+  column "attempts" {
+    null    = false
+    type    = integer
+    default = 0
+  }
+  column "priority" {
+    null    = false
+    type    = integer
+    default = 100
+  }
+  column "created_at" {
+    null = false
+    type = timestamptz
+  }
+  column "last_attempt" {
+    null = true
+    type = timestamptz
+  }
+  column "dead_lettered_at" {
+    null    = false
+    type    = timestamptz
+    default = sql("now()")
+  }
+  primary_key {
+    columns = [column.id]
+  }
+  index "event_queue_dead_letters_name" {
+    columns = [column.name, column.dead_lettered_at]
+  }
+}
+
 table "integrations" {
   schema = schema.public
   column "id" {
@@ -243,9 +302,16 @@ table "job_history" {
     default = false
     type    = bool
   }
+  column "parent_id" {
+    null = true
+    type = uuid
+  }
   primary_key {
     columns = [column.id]
   }
+  index "job_history_parent_id_idx" {
+    columns = [column.parent_id]
+  }
   index "job_history_is_pushed_idx" {
     columns = [column.is_pushed]
     where   = "is_pushed IS FALSE AND status in ('FAILED', 'WARNING')"
@@ -257,3 +323,36 @@ table "job_history" {
     columns = [column.status]
   }
 }
+
+table "job_locks" {
+  schema = schema.public
+  column "job_id" {
+    null = false
+    type = text
+  }
+  column "holder" {
+    null = false
+    type = text
+  }
+  column "previous_holder" {
+    null = true
+    type = text
+  }
+  column "acquired_at" {
+    null    = false
+    type    = timestamptz
+    default = sql("now()")
+  }
+  column "renewed_at" {
+    null    = false
+    type    = timestamptz
+    default = sql("now()")
+  }
+  column "expires_at" {
+    null = false
+    type = timestamptz
+  }
+  primary_key {
+    columns = [column.job_id]
+  }
+}
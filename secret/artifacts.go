@@ -0,0 +1,36 @@
+package secret
+
+import (
+	"fmt"
+
+	"github.com/flanksource/duty/artifact"
+	"github.com/flanksource/duty/context"
+	"gocloud.dev/secrets/localsecrets"
+)
+
+func init() {
+	context.ArtifactKeeperProvider = artifactKeeper
+}
+
+// artifactKeeper returns the keeper that wraps artifact data keys.
+// It uses the KMS connection in artifacts.encryption.connection, or the base64 encoded
+// 32 byte key in artifacts.encryption.local_key, and returns nil if neither is set.
+func artifactKeeper(ctx context.Context) (artifact.Keeper, error) {
+	if connURL := ctx.Properties().String("artifacts.encryption.connection", ""); connURL != "" {
+		keeper, err := cachedKeeper(ctx, "artifacts:"+connURL, connURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get artifact keeper from connection (%s): %w", connURL, err)
+		}
+		return keeper, nil
+	}
+
+	if key := ctx.Properties().String("artifacts.encryption.local_key", ""); key != "" {
+		sk, err := localsecrets.Base64KeyStd(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid artifacts.encryption.local_key: %w", err)
+		}
+		return localsecrets.NewKeeper(sk), nil
+	}
+
+	return nil, nil
+}
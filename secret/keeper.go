@@ -51,8 +51,13 @@ func createOrGetKeeper(ctx context.Context) (*secrets.Keeper, error) {
 		return nil, oops.Errorf("secret keeper connection is not set")
 	}
 
+	return cachedKeeper(ctx, "keeper", KMSConnection)
+}
+
+// cachedKeeper returns the Keeper cached under key, creating it from the connection if needed.
+func cachedKeeper(ctx context.Context, key, connectionString string) (*secrets.Keeper, error) {
 	keeperLock.RLock()
-	cached, ok := keeperCache.Get("keeper")
+	cached, ok := keeperCache.Get(key)
 	keeperLock.RUnlock()
 	if ok {
 		return cached.(*secrets.Keeper), nil
@@ -61,13 +66,13 @@ func createOrGetKeeper(ctx context.Context) (*secrets.Keeper, error) {
 	keeperLock.Lock()
 	defer keeperLock.Unlock()
 
-	keeper, err := KeeperFromConnection(ctx, KMSConnection)
+	keeper, err := KeeperFromConnection(ctx, connectionString)
 	if err != nil {
 		return nil, err
 	}
 
 	ttl := ctx.Properties().Duration("secretkeeper.cache.ttl", defaultKeeperTTL)
-	keeperCache.Set("keeper", keeper, ttl)
+	keeperCache.Set(key, keeper, ttl)
 	return keeper, nil
 }
 
@@ -2,14 +2,23 @@ package tests
 
 import (
 	"bytes"
+	gocontext "context"
+	"fmt"
 	"io"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/flanksource/duty/artifact"
+	artifactFS "github.com/flanksource/duty/artifact/fs"
 	"github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/tests/fixtures/dummy"
 	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"gocloud.dev/secrets/localsecrets"
 )
 
 var _ = Describe("Artifacts", Ordered, func() {
@@ -191,6 +200,443 @@ var _ = Describe("Artifacts", Ordered, func() {
 			Expect(err.Error()).To(ContainSubstring("exceeds max"))
 		})
 
+		It("should deduplicate identical payloads when content-addressed", func() {
+			store := artifact.NewBlobStore(
+				artifact.NewInlineStore(DefaultContext.DB()),
+				DefaultContext.DB(), "inline",
+				artifact.WithContentAddressing(),
+			)
+
+			data := []byte(strings.Repeat("content addressed payload ", 40))
+			write := func(filename string) *models.Artifact {
+				a, err := store.Write(artifact.Data{
+					Content:  io.NopCloser(bytes.NewReader(data)),
+					Filename: filename,
+				}, &models.Artifact{})
+				Expect(err).ToNot(HaveOccurred())
+				return a
+			}
+
+			first := write("/test/cas/first.txt")
+			second := write("/test/cas/second.txt")
+
+			Expect(first.ID).ToNot(Equal(second.ID))
+			Expect(first.Path).To(Equal(artifact.ContentAddressedPath(first.Checksum)))
+			Expect(second.Path).To(Equal(first.Path))
+			Expect(first.IsInline()).To(BeTrue())
+			Expect(second.IsInline()).To(BeFalse())
+			Expect(second.Filename).To(Equal("/test/cas/second.txt"))
+
+			for _, id := range []uuid.UUID{first.ID, second.ID} {
+				result, err := store.Read(id)
+				Expect(err).ToNot(HaveOccurred())
+				got, err := io.ReadAll(result.Content)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result.Content.Close()).To(Succeed())
+				Expect(got).To(Equal(data))
+			}
+
+			By("keeping the blob while any reference is live")
+			Expect(DefaultContext.DB().Exec("UPDATE artifacts SET deleted_at = NOW() - INTERVAL '1 hour' WHERE id = ?", first.ID).Error).To(Succeed())
+			freed, err := store.DeleteOrphans(time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(freed).To(Equal(0))
+
+			result, err := store.Read(second.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Content.Close()).To(Succeed())
+
+			By("freeing the blob once every reference is deleted")
+			Expect(DefaultContext.DB().Exec("UPDATE artifacts SET deleted_at = NOW() - INTERVAL '1 hour' WHERE id = ?", second.ID).Error).To(Succeed())
+			freed, err = store.DeleteOrphans(time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(freed).To(Equal(1))
+
+			var remaining int64
+			Expect(DefaultContext.DB().Model(&models.Artifact{}).Where("path = ?", first.Path).Count(&remaining).Error).To(Succeed())
+			Expect(remaining).To(BeZero())
+		})
+
+		It("should not free a blob that is reused while it is swept", func() {
+			store := artifact.NewBlobStore(artifactFS.NewLocalFS(GinkgoT().TempDir()), DefaultContext.DB(), "local",
+				artifact.WithContentAddressing(),
+			)
+
+			data := []byte("reused while swept")
+			orphan, err := store.Write(artifact.Data{Content: io.NopCloser(bytes.NewReader(data)), Filename: "/test/cas/orphan.txt"}, &models.Artifact{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(DefaultContext.DB().Exec("UPDATE artifacts SET deleted_at = NOW() - INTERVAL '1 hour' WHERE id = ?", orphan.ID).Error).To(Succeed())
+
+			// A write that reuses the blob holds its lock until the artifact is saved
+			tx := DefaultContext.DB().Begin()
+			Expect(tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", orphan.Path).Error).To(Succeed())
+
+			type sweep struct {
+				freed int
+				err   error
+			}
+			swept := make(chan sweep, 1)
+			go func() {
+				freed, err := store.DeleteOrphans(time.Minute)
+				swept <- sweep{freed, err}
+			}()
+			Consistently(swept, "500ms").ShouldNot(Receive())
+
+			reused := models.Artifact{Path: orphan.Path, Filename: "/test/cas/reused.txt", Checksum: orphan.Checksum, Size: orphan.Size, ContentType: orphan.ContentType}
+			Expect(tx.Create(&reused).Error).To(Succeed())
+			Expect(tx.Commit().Error).To(Succeed())
+
+			var result sweep
+			Eventually(swept).Should(Receive(&result))
+			Expect(result.err).ToNot(HaveOccurred())
+			Expect(result.freed).To(BeZero())
+
+			read, err := store.Read(reused.ID)
+			Expect(err).ToNot(HaveOccurred())
+			got, err := io.ReadAll(read.Content)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(read.Content.Close()).To(Succeed())
+			Expect(got).To(Equal(data))
+		})
+
+		It("should apply retention policies to check artifacts", func() {
+			dir := GinkgoT().TempDir()
+			store := artifact.NewBlobStore(artifactFS.NewLocalFS(dir), DefaultContext.DB(), "local")
+
+			checkID := dummy.LogisticsDBCheck.ID
+			var written []*models.Artifact
+			for i, age := range []string{"3 hours", "2 hours", "1 hour"} {
+				a, err := store.Write(artifact.Data{
+					Content:  io.NopCloser(strings.NewReader(strings.Repeat("x", 100))),
+					Filename: fmt.Sprintf("retention/check-%d.txt", i),
+				}, &models.Artifact{CheckID: &checkID})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(DefaultContext.DB().Exec("UPDATE artifacts SET created_at = NOW() - ?::interval WHERE id = ?", age, a.ID).Error).To(Succeed())
+				written = append(written, a)
+			}
+
+			result, err := store.ApplyRetention(artifact.RetentionPolicy{Owner: artifact.RetentionOwnerCheck, MaxSize: 200})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Deleted).To(Equal(1))
+			Expect(result.BlobsDeleted).To(Equal(1))
+			Expect(result.FreedBytes).To(Equal(int64(100)))
+			Expect(filepath.Join(dir, written[0].Path)).ToNot(BeAnExistingFile())
+			Expect(filepath.Join(dir, written[1].Path)).To(BeAnExistingFile())
+
+			result, err = store.ApplyRetention(artifact.RetentionPolicy{Owner: artifact.RetentionOwnerCheck, MaxAge: 90 * time.Minute})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Deleted).To(Equal(1))
+
+			var live []models.Artifact
+			Expect(DefaultContext.DB().Where("check_id = ? AND deleted_at IS NULL", checkID).Find(&live).Error).To(Succeed())
+			Expect(live).To(HaveLen(1))
+			Expect(live[0].ID).To(Equal(written[2].ID))
+
+			By("deleting artifacts past their expiry")
+			Expect(DefaultContext.DB().Exec("UPDATE artifacts SET expires_at = NOW() - INTERVAL '1 minute' WHERE id = ?", written[2].ID).Error).To(Succeed())
+			result, err = store.DeleteExpired()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Deleted).To(Equal(1))
+			Expect(filepath.Join(dir, written[2].Path)).ToNot(BeAnExistingFile())
+
+			By("leaving the artifacts of other connections to their stores")
+			otherDir := GinkgoT().TempDir()
+			other := artifact.NewBlobStore(artifactFS.NewLocalFS(otherDir), DefaultContext.DB(), "local", artifact.WithConnectionID(uuid.New()))
+			a, err := other.Write(artifact.Data{
+				Content:  io.NopCloser(strings.NewReader(strings.Repeat("x", 100))),
+				Filename: "retention/other-connection.txt",
+			}, &models.Artifact{CheckID: &checkID})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(DefaultContext.DB().Exec("UPDATE artifacts SET created_at = NOW() - INTERVAL '3 hours', expires_at = NOW() - INTERVAL '1 minute' WHERE id = ?", a.ID).Error).To(Succeed())
+
+			result, err = store.DeleteExpired()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Deleted).To(BeZero())
+			result, err = store.ApplyRetention(artifact.RetentionPolicy{Owner: artifact.RetentionOwnerCheck, MaxAge: time.Hour})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Deleted).To(BeZero())
+			Expect(filepath.Join(otherDir, a.Path)).To(BeAnExistingFile())
+
+			result, err = other.DeleteExpired()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Deleted).To(Equal(1))
+			Expect(result.BlobsDeleted).To(Equal(1))
+			Expect(filepath.Join(otherDir, a.Path)).ToNot(BeAnExistingFile())
+		})
+
+		It("should read ranges of inline and external artifacts", func() {
+			data := []byte(strings.Repeat("0123456789", 20))
+			for _, fs := range []artifact.FilesystemRW{
+				artifact.NewInlineStore(DefaultContext.DB()),
+				artifactFS.NewLocalFS(GinkgoT().TempDir()),
+			} {
+				store := artifact.NewBlobStore(fs, DefaultContext.DB(), "range")
+				a, err := store.Write(artifact.Data{
+					Content:  io.NopCloser(bytes.NewReader(data)),
+					Filename: "range/digits.txt",
+				}, &models.Artifact{})
+				Expect(err).ToNot(HaveOccurred())
+
+				result, err := store.ReadRange(a.ID, 15, 10)
+				Expect(err).ToNot(HaveOccurred())
+				got, err := io.ReadAll(result.Content)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result.Content.Close()).To(Succeed())
+				Expect(string(got)).To(Equal("5678901234"))
+				Expect(result.ContentLength).To(Equal(int64(10)))
+
+				result, err = store.ReadRange(a.ID, 195, 0)
+				Expect(err).ToNot(HaveOccurred())
+				got, err = io.ReadAll(result.Content)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result.Content.Close()).To(Succeed())
+				Expect(string(got)).To(Equal("56789"))
+				Expect(result.ContentLength).To(Equal(int64(5)))
+			}
+
+			_, err := artifact.NewBlobStore(artifact.NewInlineStore(DefaultContext.DB()), DefaultContext.DB(), "inline").ReadRange(uuid.New(), -1, 0)
+			Expect(err).To(MatchError(ContainSubstring("invalid range offset")))
+		})
+
+		It("should encrypt artifacts at rest and decrypt them on read", func() {
+			sk, err := localsecrets.NewRandomKey()
+			Expect(err).ToNot(HaveOccurred())
+			keeper := localsecrets.NewKeeper(sk)
+			defer keeper.Close()
+
+			dir := GinkgoT().TempDir()
+			store := artifact.NewBlobStore(artifactFS.NewLocalFS(dir), DefaultContext.DB(), "local", artifact.WithEncryption(keeper))
+
+			// Spans several encryption chunks
+			data := []byte(strings.Repeat("secret payload 0123456789 ", 10000))
+			a, err := store.Write(artifact.Data{
+				Content:  io.NopCloser(bytes.NewReader(data)),
+				Filename: "encrypted/har.json",
+			}, &models.Artifact{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(a.EncryptionAlgorithm).To(Equal(artifact.EncryptionAlgorithmAESGCM))
+			Expect(a.EncryptionKey).ToNot(BeEmpty())
+			Expect(a.Size).To(Equal(int64(len(data))))
+
+			stored, err := os.ReadFile(filepath.Join(dir, a.Path))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bytes.Contains(stored, []byte("secret payload"))).To(BeFalse())
+
+			result, err := store.Read(a.ID)
+			Expect(err).ToNot(HaveOccurred())
+			got, err := io.ReadAll(result.Content)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Content.Close()).To(Succeed())
+			Expect(got).To(Equal(data))
+
+			By("reading a range that crosses a chunk boundary")
+			offset := int64(64*1024 - 10)
+			result, err = store.ReadRange(a.ID, offset, 20)
+			Expect(err).ToNot(HaveOccurred())
+			got, err = io.ReadAll(result.Content)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Content.Close()).To(Succeed())
+			Expect(got).To(Equal(data[offset : offset+20]))
+
+			By("failing to read without the keeper")
+			_, err = artifact.NewBlobStore(artifactFS.NewLocalFS(dir), DefaultContext.DB(), "local").Read(a.ID)
+			Expect(err).To(MatchError(ContainSubstring("no keeper is configured")))
+
+			By("sharing the data key of a content-addressed blob")
+			cas := artifact.NewBlobStore(artifact.NewInlineStore(DefaultContext.DB()), DefaultContext.DB(), "inline",
+				artifact.WithContentAddressing(), artifact.WithEncryption(keeper))
+			first, err := cas.Write(artifact.Data{Content: io.NopCloser(bytes.NewReader(data)), Filename: "encrypted/first.json"}, &models.Artifact{})
+			Expect(err).ToNot(HaveOccurred())
+			second, err := cas.Write(artifact.Data{Content: io.NopCloser(bytes.NewReader(data)), Filename: "encrypted/second.json"}, &models.Artifact{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(second.Path).To(Equal(first.Path))
+			Expect(second.EncryptionKey).To(Equal(first.EncryptionKey))
+
+			result, err = cas.Read(second.ID)
+			Expect(err).ToNot(HaveOccurred())
+			got, err = io.ReadAll(result.Content)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Content.Close()).To(Succeed())
+			Expect(got).To(Equal(data))
+		})
+
+		It("should migrate artifacts between backends and resume after failures", func() {
+			sourceDir, destinationDir := GinkgoT().TempDir(), GinkgoT().TempDir()
+			sourceID, destinationID := uuid.New(), uuid.New()
+			source := artifact.NewBlobStore(artifactFS.NewLocalFS(sourceDir), DefaultContext.DB(), "local")
+
+			var written []*models.Artifact
+			for i := range 3 {
+				a, err := source.Write(artifact.Data{
+					Content:  io.NopCloser(strings.NewReader(fmt.Sprintf("migrated artifact %d", i))),
+					Filename: fmt.Sprintf("/migrate/artifact-%d.txt", i),
+				}, &models.Artifact{ConnectionID: sourceID})
+				Expect(err).ToNot(HaveOccurred())
+				written = append(written, a)
+			}
+			Expect(DefaultContext.DB().Model(&models.Artifact{}).Where("id = ?", written[2].ID).Update("checksum", "corrupted").Error).To(Succeed())
+
+			migration := artifact.Migration{
+				Source:                  artifactFS.NewLocalFS(sourceDir),
+				Destination:             artifactFS.NewLocalFS(destinationDir),
+				SourceConnectionID:      sourceID,
+				DestinationConnectionID: destinationID,
+				BatchSize:               2,
+			}
+			result, err := migration.Run(DefaultContext.DB())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Migrated).To(Equal(2))
+			Expect(result.Failed).To(Equal(1))
+			Expect(result.Remaining).To(Equal(int64(1)))
+			Expect(result.Errors[0]).To(ContainSubstring("checksum mismatch"))
+
+			var migrated models.Artifact
+			Expect(DefaultContext.DB().Where("id = ?", written[0].ID).First(&migrated).Error).To(Succeed())
+			Expect(migrated.ConnectionID).To(Equal(destinationID))
+			Expect(migrated.Path).To(Equal("migrate/artifact-0.txt"))
+			Expect(filepath.Join(destinationDir, migrated.Path)).To(BeAnExistingFile())
+
+			destination := artifact.NewBlobStore(artifactFS.NewLocalFS(destinationDir), DefaultContext.DB(), "local")
+			data, err := destination.Read(migrated.ID)
+			Expect(err).ToNot(HaveOccurred())
+			got, err := io.ReadAll(data.Content)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data.Content.Close()).To(Succeed())
+			Expect(string(got)).To(Equal("migrated artifact 0"))
+
+			By("resuming with the artifacts that still reference the source")
+			Expect(DefaultContext.DB().Model(&models.Artifact{}).Where("id = ?", written[2].ID).Update("checksum", written[2].Checksum).Error).To(Succeed())
+			result, err = migration.Run(DefaultContext.DB())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Migrated).To(Equal(1))
+			Expect(result.Failed).To(BeZero())
+			Expect(result.Remaining).To(BeZero())
+		})
+
+		It("should keep inline content until migrated artifacts are released", func() {
+			destinationDir, destinationID := GinkgoT().TempDir(), uuid.New()
+			inline := artifact.NewBlobStore(artifact.NewInlineStore(DefaultContext.DB()), DefaultContext.DB(), "inline")
+
+			a, err := inline.Write(artifact.Data{
+				Content:  io.NopCloser(strings.NewReader("inline before cut-over")),
+				Filename: "migrate-inline/report.txt",
+			}, &models.Artifact{})
+			Expect(err).ToNot(HaveOccurred())
+
+			By("ignoring external artifacts without a connection")
+			external, err := artifact.NewBlobStore(artifactFS.NewLocalFS(GinkgoT().TempDir()), DefaultContext.DB(), "local").Write(artifact.Data{
+				Content:  io.NopCloser(strings.NewReader("external")),
+				Filename: "migrate-inline/external.txt",
+			}, &models.Artifact{})
+			Expect(err).ToNot(HaveOccurred())
+
+			migration := artifact.Migration{
+				Source:                  artifact.NewInlineStore(DefaultContext.DB()),
+				Destination:             artifactFS.NewLocalFS(destinationDir),
+				DestinationConnectionID: destinationID,
+			}
+			_, err = migration.Run(DefaultContext.DB())
+			Expect(err).ToNot(HaveOccurred())
+
+			var unmigrated models.Artifact
+			Expect(DefaultContext.DB().Where("id = ?", external.ID).First(&unmigrated).Error).To(Succeed())
+			Expect(unmigrated.ConnectionID).To(Equal(uuid.Nil))
+
+			By("serving the migrated artifact from the inline store before cut-over")
+			var migrated models.Artifact
+			Expect(DefaultContext.DB().Where("id = ?", a.ID).First(&migrated).Error).To(Succeed())
+			Expect(migrated.ConnectionID).To(Equal(destinationID))
+			Expect(migrated.IsInline()).To(BeTrue())
+			Expect(filepath.Join(destinationDir, migrated.Path)).To(BeAnExistingFile())
+
+			data, err := inline.Read(a.ID)
+			Expect(err).ToNot(HaveOccurred())
+			got, err := io.ReadAll(data.Content)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data.Content.Close()).To(Succeed())
+			Expect(string(got)).To(Equal("inline before cut-over"))
+
+			By("releasing the inline content after cut-over")
+			released, err := migration.ReleaseInlineContent(DefaultContext.DB())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(released).To(BeNumerically(">=", 1))
+
+			destination := artifact.NewBlobStore(artifactFS.NewLocalFS(destinationDir), DefaultContext.DB(), "local", artifact.WithConnectionID(destinationID))
+			data, err = destination.Read(a.ID)
+			Expect(err).ToNot(HaveOccurred())
+			got, err = io.ReadAll(data.Content)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data.Content.Close()).To(Succeed())
+			Expect(string(got)).To(Equal("inline before cut-over"))
+
+			By("recording the connection of the blob store on writes")
+			written, err := destination.Write(artifact.Data{
+				Content:  io.NopCloser(strings.NewReader("after cut-over")),
+				Filename: "migrate-inline/after.txt",
+			}, &models.Artifact{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written.ConnectionID).To(Equal(destinationID))
+		})
+
+		It("should sign URLs with the HMAC fallback and upload through them", func() {
+			signer := artifact.NewHMACSigner([]byte("test-key"), "https://example.com/artifacts/signed/")
+			store := artifact.NewBlobStore(artifactFS.NewLocalFS(GinkgoT().TempDir()), DefaultContext.DB(), "local", artifact.WithURLSigner(signer))
+
+			a, err := store.Write(artifact.Data{
+				Content:  io.NopCloser(strings.NewReader("before upload")),
+				Filename: "signed/report.txt",
+			}, &models.Artifact{})
+			Expect(err).ToNot(HaveOccurred())
+
+			signed, err := store.SignedURL(a.ID, time.Minute, "put")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(signed).To(HavePrefix("https://example.com/artifacts/signed/" + a.ID.String() + "?"))
+
+			parsed, err := url.Parse(signed)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(signer.Verify(a.ID, "PUT", parsed.Query())).To(Succeed())
+			Expect(signer.Verify(a.ID, "GET", parsed.Query())).To(MatchError(artifact.ErrSignatureInvalid))
+			Expect(signer.Verify(uuid.New(), "PUT", parsed.Query())).To(MatchError(artifact.ErrSignatureInvalid))
+
+			expired, err := url.Parse(signer.Sign(a.ID, -time.Minute, "GET"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(signer.Verify(a.ID, "GET", expired.Query())).To(MatchError(artifact.ErrSignatureExpired))
+
+			uploaded, err := store.Upload(a.ID, artifact.Data{Content: io.NopCloser(strings.NewReader("after upload"))})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uploaded.Size).To(Equal(int64(len("after upload"))))
+			Expect(uploaded.Path).To(Equal(a.Path))
+
+			result, err := store.Read(a.ID)
+			Expect(err).ToNot(HaveOccurred())
+			got, err := io.ReadAll(result.Content)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Content.Close()).To(Succeed())
+			Expect(string(got)).To(Equal("after upload"))
+
+			_, err = artifact.NewBlobStore(artifactFS.NewLocalFS(GinkgoT().TempDir()), DefaultContext.DB(), "local").SignedURL(a.ID, time.Minute, "GET")
+			Expect(err).To(MatchError(ContainSubstring("does not support signed URLs")))
+		})
+
+		It("should only presign downloads and upload through the API", func() {
+			signer := artifact.NewHMACSigner([]byte("test-key"), "https://example.com/artifacts/signed")
+			store := artifact.NewBlobStore(presigningFS{artifactFS.NewLocalFS(GinkgoT().TempDir())}, DefaultContext.DB(), "s3", artifact.WithURLSigner(signer))
+
+			a, err := store.Write(artifact.Data{
+				Content:  io.NopCloser(strings.NewReader("presigned")),
+				Filename: "signed/presigned.txt",
+			}, &models.Artifact{})
+			Expect(err).ToNot(HaveOccurred())
+
+			download, err := store.SignedURL(a.ID, time.Minute, "GET")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(download).To(Equal("https://bucket.example.com/" + a.Path))
+
+			upload, err := store.SignedURL(a.ID, time.Minute, "PUT")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(upload).To(HavePrefix("https://example.com/artifacts/signed/" + a.ID.String() + "?"))
+		})
+
 		It("should return Pretty() on Data", func() {
 			d := artifact.Data{
 				Filename:      "test.json",
@@ -232,3 +678,12 @@ var _ = Describe("Artifacts", Ordered, func() {
 		})
 	})
 })
+
+// presigningFS presigns URLs for the objects of a filesystem like S3, GCS and Azure do.
+type presigningFS struct {
+	artifact.FilesystemRW
+}
+
+func (presigningFS) SignedURL(_ gocontext.Context, path string, _ time.Duration) (string, error) {
+	return "https://bucket.example.com/" + path, nil
+}
@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"database/sql"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"sigs.k8s.io/yaml"
+
+	"github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/query"
+	"github.com/flanksource/duty/rls"
+	"github.com/flanksource/duty/tests/fixtures/dummy"
+	"github.com/flanksource/duty/types"
+)
+
+var _ = Describe("Config masks", Ordered, func() {
+	config := models.ConfigItem{
+		ID:          uuid.New(),
+		ConfigClass: "ConfigMap",
+		Type:        lo.ToPtr("Kubernetes::ConfigMap"),
+		Name:        lo.ToPtr("masked-config"),
+		Config:      lo.ToPtr(`{"data":{"password":"hunter2","user":"admin"}}`),
+	}
+
+	masks := []models.ConfigMask{
+		{Name: "configmap-data", ConfigTypes: pq.StringArray{"Kubernetes::ConfigMap"}, Roles: pq.StringArray{"viewer"}, Paths: pq.StringArray{"$.data.password"}},
+		{Name: "everyone", Paths: pq.StringArray{"$.metadata.annotations"}},
+		{Name: "editors", Roles: pq.StringArray{"editor"}, Paths: pq.StringArray{"$.data.*"}},
+	}
+
+	relationship := models.ConfigComponentRelationship{ComponentID: dummy.Logistics.ID, ConfigID: config.ID}
+
+	BeforeAll(func() {
+		Expect(DefaultContext.DB().Create(&config).Error).To(BeNil())
+		Expect(DefaultContext.DB().Create(&masks).Error).To(BeNil())
+		Expect(DefaultContext.DB().Create(&relationship).Error).To(BeNil())
+	})
+
+	AfterAll(func() {
+		Expect(DefaultContext.DB().Delete(&relationship).Error).To(BeNil())
+		Expect(DefaultContext.DB().Delete(&masks).Error).To(BeNil())
+		Expect(DefaultContext.DB().Delete(&config).Error).To(BeNil())
+	})
+
+	It("should get the masks of roles", func() {
+		viewer, err := query.GetConfigMasks(DefaultContext, "viewer")
+		Expect(err).To(BeNil())
+		Expect(viewer).To(ConsistOf(
+			rls.ConfigMask{Types: []string{"Kubernetes::ConfigMap"}, Paths: []string{"$.data.password"}},
+			rls.ConfigMask{Paths: []string{"$.metadata.annotations"}},
+		))
+	})
+
+	It("should mask configs read through query functions", func() {
+		viewer, err := query.GetConfigMasks(DefaultContext, "viewer")
+		Expect(err).To(BeNil())
+		ctx := DefaultContext.WithRLSPayload(&rls.Payload{ConfigMasks: viewer})
+
+		masked, err := query.GetCachedConfig(ctx, config.ID.String())
+		Expect(err).To(BeNil())
+		Expect(masked.MaskedPaths).To(Equal([]string{"data.password"}))
+		Expect(*masked.Config).To(MatchJSON(`{"data":{"password":"***","user":"admin"}}`))
+
+		fromCache, err := query.ConfigItemFromCache(ctx, config.ID.String())
+		Expect(err).To(BeNil())
+		Expect(fromCache.MaskedPaths).To(Equal([]string{"data.password"}))
+
+		// The cached config must not be masked for other readers
+		unmasked, err := query.ConfigItemFromCache(DefaultContext, config.ID.String())
+		Expect(err).To(BeNil())
+		Expect(unmasked.MaskedPaths).To(BeEmpty())
+		Expect(*unmasked.Config).To(MatchJSON(*config.Config))
+	})
+
+	It("should mask configs found by query functions", func() {
+		viewer, err := query.GetConfigMasks(DefaultContext, "viewer")
+		Expect(err).To(BeNil())
+		ctx := DefaultContext.WithRLSPayload(&rls.Payload{ConfigMasks: viewer})
+
+		byIDs, err := query.GetConfigsByIDs(ctx, []uuid.UUID{config.ID})
+		Expect(err).To(BeNil())
+		found, err := query.FindConfigs(ctx, -1, types.ConfigQuery{ResourceSelector: types.ResourceSelector{ID: config.ID.String()}})
+		Expect(err).To(BeNil())
+		bySelector, err := query.FindConfigsByResourceSelector(ctx, -1, types.ResourceSelector{ID: config.ID.String()})
+		Expect(err).To(BeNil())
+		forComponent, err := query.FindConfigForComponent(ctx, dummy.Logistics.ID.String(), *config.Type)
+		Expect(err).To(BeNil())
+
+		for _, items := range [][]models.ConfigItem{byIDs, found, bySelector, forComponent} {
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].MaskedPaths).To(Equal([]string{"data.password"}))
+			Expect(*items[0].Config).To(MatchJSON(`{"data":{"password":"***","user":"admin"}}`))
+		}
+	})
+
+	Context("PostgREST", func() {
+		payload := rls.Payload{
+			Config:      []rls.Scope{{ID: config.ID.String()}},
+			ConfigMasks: []rls.ConfigMask{{Types: []string{"Kubernetes::*"}, Paths: []string{"$.data.*"}}},
+		}
+
+		type maskedConfig struct {
+			Config      string
+			MaskedPaths pq.StringArray `gorm:"type:text[]"`
+		}
+
+		withRLS := func(payload rls.Payload, fn func(tx *gorm.DB)) {
+			tx := DefaultContext.DB().Session(&gorm.Session{NewDB: true}).Begin(&sql.TxOptions{ReadOnly: true})
+			defer tx.Rollback()
+
+			Expect(payload.SetPostgresSessionRLS(tx)).To(BeNil())
+			fn(tx)
+		}
+
+		It("should mask configs read through config_items_masked", func() {
+			withRLS(payload, func(tx *gorm.DB) {
+				var result maskedConfig
+				Expect(tx.Raw("SELECT config, masked_paths FROM config_items_masked WHERE id = ?", config.ID).Scan(&result).Error).To(BeNil())
+				Expect(result.Config).To(MatchJSON(`{"data":{"password":"***","user":"***"}}`))
+				Expect([]string(result.MaskedPaths)).To(Equal([]string{"data.password", "data.user"}))
+
+				var detail string
+				Expect(tx.Raw("SELECT config FROM config_detail WHERE id = ?", config.ID).Scan(&detail).Error).To(BeNil())
+				Expect(detail).To(MatchJSON(`{"data":{"password":"***","user":"***"}}`))
+			})
+		})
+
+		It("should not return configs outside of the RLS scope", func() {
+			withRLS(rls.Payload{Config: []rls.Scope{{ID: uuid.NewString()}}}, func(tx *gorm.DB) {
+				var count int64
+				Expect(tx.Raw("SELECT COUNT(*) FROM config_items_masked WHERE id = ?", config.ID).Scan(&count).Error).To(BeNil())
+				Expect(count).To(BeZero())
+			})
+		})
+
+		It("should not mask config types that are excluded", func() {
+			payload := rls.Payload{
+				Config:      []rls.Scope{{ID: config.ID.String()}},
+				ConfigMasks: []rls.ConfigMask{{Types: []string{"Kubernetes::*", "!Kubernetes::ConfigMap"}, Paths: []string{"$.data.*"}}},
+			}
+			withRLS(payload, func(tx *gorm.DB) {
+				var result maskedConfig
+				Expect(tx.Raw("SELECT config, masked_paths FROM config_items_masked WHERE id = ?", config.ID).Scan(&result).Error).To(BeNil())
+				Expect(result.Config).To(MatchJSON(*config.Config))
+				Expect(result.MaskedPaths).To(BeEmpty())
+			})
+		})
+
+		It("should keep the raw config readable", func() {
+			withRLS(payload, func(tx *gorm.DB) {
+				var raw string
+				Expect(tx.Raw("SELECT config FROM config_items WHERE id = ?", config.ID).Scan(&raw).Error).To(BeNil())
+				Expect(raw).To(MatchJSON(*config.Config))
+			})
+		})
+
+		// The same cases are run against rls.Payload.MaskConfig in rls/mask_test.go
+		It("should mask like the query functions", func() {
+			data, err := os.ReadFile("../rls/testdata/config_masks.yaml")
+			Expect(err).To(BeNil())
+
+			var fixture struct {
+				Cases []struct {
+					Name   string           `json:"name"`
+					Type   string           `json:"type"`
+					Config string           `json:"config"`
+					Masks  []rls.ConfigMask `json:"masks"`
+					Masked string           `json:"masked"`
+					Paths  []string         `json:"paths"`
+				} `json:"cases"`
+			}
+			Expect(yaml.Unmarshal(data, &fixture)).To(Succeed())
+			Expect(fixture.Cases).ToNot(BeEmpty())
+
+			for _, tc := range fixture.Cases {
+				withRLS(rls.Payload{ConfigMasks: tc.Masks}, func(tx *gorm.DB) {
+					var result struct {
+						Masked string
+						Paths  pq.StringArray `gorm:"type:text[]"`
+					}
+					Expect(tx.Raw("SELECT masked, paths FROM mask_config(?, ?::jsonb)", tc.Type, tc.Config).Scan(&result).Error).To(BeNil(), tc.Name)
+					Expect(result.Masked).To(MatchJSON(tc.Masked), tc.Name)
+					if len(tc.Paths) == 0 {
+						Expect(result.Paths).To(BeEmpty(), tc.Name)
+					} else {
+						Expect([]string(result.Paths)).To(Equal(tc.Paths), tc.Name)
+					}
+				})
+			}
+		})
+	})
+})
@@ -103,6 +103,25 @@ var _ = Describe("Blob Stores", Label("e2e"), func() {
 				Expect(string(content)).To(Equal("record-1"))
 			})
 
+			It("should read byte ranges", func() {
+				for _, tc := range []struct {
+					offset, length int64
+					expected       string
+				}{
+					{0, 6, "record"},
+					{7, 1, "2"},
+					{3, 0, "ord-2"},
+				} {
+					reader, err := fs.ReadRange(gocontext.Background(), "record-2.txt", tc.offset, tc.length)
+					Expect(err).ToNot(HaveOccurred())
+
+					content, err := io.ReadAll(reader)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(reader.Close()).To(Succeed())
+					Expect(string(content)).To(Equal(tc.expected))
+				}
+			})
+
 			It("should stat files", func() {
 				info, err := fs.Stat("first.json")
 				Expect(err).ToNot(HaveOccurred())
@@ -2,6 +2,8 @@ package tests
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/flanksource/commons/hash"
 	"github.com/flanksource/commons/logger"
@@ -113,6 +115,194 @@ var _ = ginkgo.Describe("Event queue", func() {
 		Expect(handlerInvocationCount).To(Equal(iterations))
 	})
 
+	ginkgo.It("should dead-letter exhausted events and replay them", func() {
+		const syncEvent = "test.dead-letter.sync"
+		const asyncEvent = "test.dead-letter.async"
+		fetchOption := &postq.EventFetcherOption{MaxAttempts: 1}
+
+		for _, name := range []string{syncEvent, asyncEvent} {
+			// Already attempted MaxAttempts times, so the next failure is final.
+			err := DefaultContext.DB().Create(&models.Event{Name: name, EventID: uuid.New(), Attempts: 1}).Error
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		var fail = true
+		syncConsumer := postq.SyncEventConsumer{
+			WatchEvents: []string{syncEvent},
+			Consumers: postq.SyncHandlers(func(ctx context.Context, e models.Event) error {
+				if fail {
+					return fmt.Errorf("upstream unavailable")
+				}
+				return nil
+			}),
+			EventFetchOption: fetchOption,
+		}
+		asyncConsumer := postq.AsyncEventConsumer{
+			WatchEvents: []string{asyncEvent},
+			BatchSize:   10,
+			Consumer: postq.AsyncHandler(func(ctx context.Context, events models.Events) models.Events {
+				for i := range events {
+					events[i].SetError("invalid payload")
+				}
+				return events
+			}),
+			EventFetcherOption: fetchOption,
+		}
+
+		_, err := syncConsumer.Handle(DefaultContext)
+		Expect(err).To(HaveOccurred())
+		_, err = asyncConsumer.Handle(DefaultContext)
+		Expect(err).ToNot(HaveOccurred())
+
+		var queued int64
+		Expect(DefaultContext.DB().Model(&models.Event{}).Where("name IN ?", []string{syncEvent, asyncEvent}).Count(&queued).Error).To(Succeed())
+		Expect(queued).To(BeZero())
+
+		deadLetters, err := postq.ListDeadLetters(DefaultContext, postq.DeadLetterQuery{Names: []string{syncEvent, asyncEvent}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deadLetters).To(HaveLen(2))
+		for _, d := range deadLetters {
+			Expect(d.Attempts).To(Equal(2))
+			Expect(d.DeadLetteredAt).To(BeTemporally("~", time.Now(), time.Minute))
+		}
+
+		byError, err := postq.ListDeadLetters(DefaultContext, postq.DeadLetterQuery{Names: []string{syncEvent, asyncEvent}, Error: "UPSTREAM"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(byError).To(HaveLen(1))
+		Expect(byError[0].Name).To(Equal(syncEvent))
+
+		ginkgo.By("replaying with the attempts reset")
+		replayed, err := postq.ReplayDeadLetters(DefaultContext, byError[0].ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(replayed).To(Equal(1))
+
+		var event models.Event
+		Expect(DefaultContext.DB().Where("name = ?", syncEvent).First(&event).Error).To(Succeed())
+		Expect(event.Attempts).To(BeZero())
+		Expect(event.EventID).ToNot(Equal(uuid.Nil))
+
+		fail = false
+		consumer, err := syncConsumer.EventConsumer()
+		Expect(err).ToNot(HaveOccurred())
+		consumer.ConsumeUntilEmpty(DefaultContext)
+		Expect(DefaultContext.DB().Model(&models.Event{}).Where("name = ?", syncEvent).Count(&queued).Error).To(Succeed())
+		Expect(queued).To(BeZero())
+
+		replayed, err = postq.ReplayDeadLettersMatching(DefaultContext, postq.DeadLetterQuery{Names: []string{asyncEvent}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(replayed).To(Equal(1))
+
+		remaining, err := postq.ListDeadLetters(DefaultContext, postq.DeadLetterQuery{Names: []string{syncEvent, asyncEvent}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(remaining).To(BeEmpty())
+
+		Expect(DefaultContext.DB().Where("name = ?", asyncEvent).Delete(&models.Event{}).Error).To(Succeed())
+	})
+
+	ginkgo.It("should honour retry-after and permanent errors", func() {
+		const eventName = "test.retry.typed-errors"
+		retryEventID, permanentEventID := uuid.New(), uuid.New()
+		for _, id := range []uuid.UUID{retryEventID, permanentEventID} {
+			Expect(DefaultContext.DB().Create(&models.Event{Name: eventName, EventID: id}).Error).To(Succeed())
+		}
+
+		syncConsumer := postq.SyncEventConsumer{
+			WatchEvents: []string{eventName},
+			Consumers: postq.SyncHandlers(func(ctx context.Context, e models.Event) error {
+				if e.EventID == permanentEventID {
+					return postq.Permanent(fmt.Errorf("malformed event"))
+				}
+				return postq.RetryAfter(fmt.Errorf("rate limited"), time.Hour)
+			}),
+		}
+
+		consumer, err := syncConsumer.EventConsumer()
+		Expect(err).ToNot(HaveOccurred())
+		consumer.ConsumeUntilEmpty(DefaultContext)
+
+		var event models.Event
+		Expect(DefaultContext.DB().Where("name = ? AND event_id = ?", eventName, retryEventID).First(&event).Error).To(Succeed())
+		Expect(event.Attempts).To(Equal(1))
+		Expect(event.NextAttempt).ToNot(BeNil())
+		Expect(*event.NextAttempt).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+
+		deadLetters, err := postq.ListDeadLetters(DefaultContext, postq.DeadLetterQuery{Names: []string{eventName}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deadLetters).To(HaveLen(1))
+		Expect(*deadLetters[0].EventID).To(Equal(permanentEventID))
+		Expect(*deadLetters[0].Error).To(ContainSubstring("permanent failure"))
+		Expect(deadLetters[0].DeadLetteredAt).To(BeTemporally("~", time.Now(), time.Minute))
+
+		Expect(DefaultContext.DB().Where("name = ?", eventName).Delete(&models.Event{}).Error).To(Succeed())
+		Expect(DefaultContext.DB().Where("name = ?", eventName).Delete(&models.EventDeadLetter{}).Error).To(Succeed())
+	})
+
+	ginkgo.It("should deliver events with the same partition key one at a time in order", func() {
+		const eventName = "test.partitioned"
+		keys := map[string]int{"config-a": 4, "config-b": 3}
+
+		createdAt := time.Now().Add(-time.Hour)
+		for key, count := range keys {
+			for seq := range count {
+				createdAt = createdAt.Add(time.Second)
+				err := DefaultContext.DB().Create(&models.Event{
+					Name:       eventName,
+					EventID:    uuid.New(),
+					CreatedAt:  createdAt,
+					Properties: map[string]string{"id": key, "seq": fmt.Sprintf("%d", seq)},
+				}).Error
+				Expect(err).ToNot(HaveOccurred())
+			}
+		}
+
+		var mu sync.Mutex
+		inFlight := map[string]int{}
+		delivered := map[string][]string{}
+		var overlapping bool
+
+		syncConsumer := postq.SyncEventConsumer{
+			WatchEvents: []string{eventName},
+			Consumers: postq.SyncHandlers(func(ctx context.Context, e models.Event) error {
+				key := e.Properties["id"]
+				mu.Lock()
+				inFlight[key]++
+				overlapping = overlapping || inFlight[key] > 1
+				delivered[key] = append(delivered[key], e.Properties["seq"])
+				mu.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				mu.Lock()
+				inFlight[key]--
+				mu.Unlock()
+				return nil
+			}),
+			EventFetchOption: &postq.EventFetcherOption{PartitionKey: "id"},
+		}
+
+		consumer, err := syncConsumer.EventConsumer()
+		Expect(err).ToNot(HaveOccurred())
+
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer ginkgo.GinkgoRecover()
+				consumer.ConsumeUntilEmpty(DefaultContext)
+			}()
+		}
+		wg.Wait()
+
+		// A consumer stops when it finds nothing to fetch, which can happen
+		// while every remaining key is held by another consumer.
+		consumer.ConsumeUntilEmpty(DefaultContext)
+
+		Expect(overlapping).To(BeFalse())
+		Expect(delivered["config-a"]).To(Equal([]string{"0", "1", "2", "3"}))
+		Expect(delivered["config-b"]).To(Equal([]string{"0", "1", "2"}))
+	})
+
 	ginkgo.DescribeTable("should enforce uniqueness constraint on name and event_id",
 		func(events []models.Event, shouldFail bool) {
 			for i, event := range events {
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/job"
+	"github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Job locks", func() {
+	It("should hold a lease until it expires and then let another holder take it over", func() {
+		jobID := "lease-" + uuid.NewString()
+		DeferCleanup(func() {
+			Expect(DefaultContext.DB().Where("job_id = ?", jobID).Delete(&models.JobLock{}).Error).To(BeNil())
+		})
+
+		first, err := job.AcquireLease(DefaultContext, jobID, "replica-a", time.Minute)
+		Expect(err).To(BeNil())
+		Expect(first).ToNot(BeNil())
+		Expect(first.Holder).To(Equal("replica-a"))
+		Expect(first.PreviousHolder).To(BeNil())
+
+		second, err := job.AcquireLease(DefaultContext, jobID, "replica-b", time.Minute)
+		Expect(err).To(BeNil())
+		Expect(second).To(BeNil(), "the lease is still held by replica-a")
+
+		Expect(first.Renew(DefaultContext)).To(Succeed())
+
+		// replica-a stops renewing
+		Expect(DefaultContext.DB().Model(&models.JobLock{}).Where("job_id = ?", jobID).
+			Update("expires_at", time.Now().Add(-time.Second)).Error).To(BeNil())
+
+		second, err = job.AcquireLease(DefaultContext, jobID, "replica-b", time.Minute)
+		Expect(err).To(BeNil())
+		Expect(second).ToNot(BeNil())
+		Expect(second.Holder).To(Equal("replica-b"))
+		Expect(second.PreviousHolder).To(HaveValue(Equal("replica-a")))
+
+		Expect(first.Renew(DefaultContext)).To(MatchError(job.ErrLeaseLost))
+
+		// A stale holder releasing must not drop the new holder's lease
+		Expect(first.Release(DefaultContext)).To(Succeed())
+		var lock models.JobLock
+		Expect(DefaultContext.DB().Where("job_id = ?", jobID).First(&lock).Error).To(BeNil())
+		Expect(lock.Holder).To(Equal("replica-b"))
+
+		Expect(second.Release(DefaultContext)).To(Succeed())
+		var count int64
+		Expect(DefaultContext.DB().Model(&models.JobLock{}).Where("job_id = ?", jobID).Count(&count).Error).To(BeNil())
+		Expect(count).To(BeZero())
+	})
+
+	It("should skip distributed jobs that are running on another replica", func() {
+		_ = context.UpdateProperty(DefaultContext, "job.jitter.disable", "true")
+
+		var runs atomic.Int32
+		distributed := &job.Job{
+			Name:        "distributed-" + uuid.NewString(),
+			Distributed: true,
+			JobHistory:  true,
+			Context:     DefaultContext,
+			Fn: func(ctx job.JobRuntime) error {
+				runs.Add(1)
+				return nil
+			},
+		}
+		DeferCleanup(func() {
+			Expect(DefaultContext.DB().Where("job_id = ?", distributed.ID()).Delete(&models.JobLock{}).Error).To(BeNil())
+		})
+
+		other, err := job.AcquireLease(DefaultContext, distributed.ID(), "another-replica", time.Minute)
+		Expect(err).To(BeNil())
+		Expect(other).ToNot(BeNil())
+
+		distributed.Run()
+		Expect(runs.Load()).To(BeZero())
+		Expect(distributed.LastJob).ToNot(BeNil())
+		Expect(distributed.LastJob.Status).To(Equal(models.StatusSkipped))
+
+		Expect(other.Release(DefaultContext)).To(Succeed())
+		distributed.Run()
+		Expect(runs.Load()).To(Equal(int32(1)))
+
+		var count int64
+		Expect(DefaultContext.DB().Model(&models.JobLock{}).Where("job_id = ?", distributed.ID()).Count(&count).Error).To(BeNil())
+		Expect(count).To(BeZero(), "the lease is released once the job finishes")
+	})
+})
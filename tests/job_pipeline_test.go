@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"fmt"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/job"
+	"github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Job pipelines", func() {
+	It("should run jobs after their dependencies and skip the dependents of failed jobs", func() {
+		_ = context.UpdateProperty(DefaultContext, "job.jitter.disable", "true")
+		prefix := "pipeline-" + uuid.NewString()[0:8]
+
+		// The jobs form a chain, so they never run concurrently
+		var order []string
+		record := func(name string, err error) func(job.JobRuntime) error {
+			return func(ctx job.JobRuntime) error {
+				order = append(order, name)
+				return err
+			}
+		}
+
+		refresh := &job.Job{Name: prefix + "-refresh", JobHistory: true, Fn: record("refresh", nil)}
+		summary := &job.Job{Name: prefix + "-summary", JobHistory: true, DependsOn: []*job.Job{refresh}, Fn: record("summary", fmt.Errorf("summary failed"))}
+		cache := &job.Job{Name: prefix + "-cache", JobHistory: true, DependsOn: []*job.Job{summary}, Fn: record("cache", nil)}
+
+		pipeline, err := job.NewPipeline(DefaultContext, prefix, "@every 1h", cache, summary, refresh)
+		Expect(err).To(BeNil())
+		pipeline.Run()
+
+		Expect(order).To(Equal([]string{"refresh", "summary"}))
+
+		parent := pipeline.LastJob
+		Expect(parent).ToNot(BeNil())
+		Expect(parent.Status).To(Equal(models.StatusFailed))
+		Expect(parent.Details).To(HaveKeyWithValue("jobs", map[string]string{
+			refresh.ID(): models.StatusSuccess,
+			summary.ID(): models.StatusFailed,
+			cache.ID():   models.StatusSkipped,
+		}))
+
+		var children []models.JobHistory
+		Expect(DefaultContext.DB().Where("parent_id = ?", parent.ID).Find(&children).Error).To(BeNil())
+		statuses := map[string]string{}
+		for _, child := range children {
+			statuses[child.Name] = child.Status
+		}
+		Expect(statuses).To(Equal(map[string]string{
+			refresh.Name: models.StatusSuccess,
+			summary.Name: models.StatusFailed,
+			cache.Name:   models.StatusSkipped,
+		}))
+	})
+
+	It("should reject cycles and dependencies outside of the pipeline", func() {
+		a := &job.Job{Name: "a"}
+		b := &job.Job{Name: "b", DependsOn: []*job.Job{a}}
+		a.DependsOn = []*job.Job{b}
+
+		_, err := job.NewPipeline(DefaultContext, "cyclic", "@every 1h", a, b)
+		Expect(err).To(MatchError(ContainSubstring("dependency cycle")))
+
+		c := &job.Job{Name: "c", DependsOn: []*job.Job{{Name: "outside"}}}
+		_, err = job.NewPipeline(DefaultContext, "incomplete", "@every 1h", c)
+		Expect(err).To(MatchError(ContainSubstring("not part of the pipeline")))
+	})
+})
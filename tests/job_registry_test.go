@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/job"
+	"github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/robfig/cron/v3"
+)
+
+var _ = Describe("Job registry", func() {
+	It("should list, pause, resume and trigger scheduled jobs", func() {
+		_ = context.UpdateProperty(DefaultContext, "job.jitter.disable", "true")
+
+		var runs atomic.Int32
+		scheduled := &job.Job{
+			Name:     "registry-" + uuid.NewString(),
+			Schedule: "@every 1h",
+			Context:  DefaultContext,
+			Fn: func(ctx job.JobRuntime) error {
+				runs.Add(1)
+				return nil
+			},
+		}
+
+		cronRunner := cron.New()
+		Expect(scheduled.AddToScheduler(cronRunner)).To(Succeed())
+		DeferCleanup(func() {
+			scheduled.RemoveFromScheduler(cronRunner)
+			cronRunner.Stop()
+			Expect(DefaultContext.DB().Where("name = ?", "jobs."+scheduled.ID()+".paused").Delete(&models.AppProperty{}).Error).To(BeNil())
+		})
+
+		Expect(job.ListJobs()).To(ContainElement(HaveField("ID", scheduled.ID())))
+
+		status, err := job.GetJob(scheduled.ID())
+		Expect(err).To(BeNil())
+		Expect(status.Schedule).To(Equal("@every 1h"))
+		Expect(status.Paused).To(BeFalse())
+		Expect(status.NextRun).ToNot(BeNil())
+
+		Expect(job.PauseJob(DefaultContext, scheduled.ID())).To(Succeed())
+		var property models.AppProperty
+		Expect(DefaultContext.DB().Where("name = ?", "jobs."+scheduled.ID()+".paused").First(&property).Error).To(BeNil())
+		Expect(property.Value).To(Equal("true"))
+
+		status, err = job.GetJob(scheduled.ID())
+		Expect(err).To(BeNil())
+		Expect(status.Paused).To(BeTrue())
+
+		// scheduled runs are skipped while paused
+		scheduled.Run()
+		Expect(runs.Load()).To(BeZero())
+
+		// but the job can still be triggered on demand
+		Expect(job.TriggerJob(scheduled.ID())).To(Succeed())
+		Eventually(runs.Load).Should(Equal(int32(1)))
+		Eventually(func() *models.JobHistory {
+			status, _ := job.GetJob(scheduled.ID())
+			return status.LastRun
+		}).Should(HaveField("Status", models.StatusSuccess))
+
+		Expect(job.ResumeJob(DefaultContext, scheduled.ID())).To(Succeed())
+		scheduled.Run()
+		Expect(runs.Load()).To(Equal(int32(2)))
+
+		_, err = job.GetJob("does-not-exist")
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("should trigger jobs without jitter and report their last run while they run", func() {
+		_ = context.UpdateProperty(DefaultContext, "job.jitter.disable", "false")
+		DeferCleanup(func() {
+			_ = context.UpdateProperty(DefaultContext, "job.jitter.disable", "true")
+		})
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		triggered := &job.Job{
+			Name:     "registry-" + uuid.NewString(),
+			Schedule: "@every 1h",
+			Context:  DefaultContext,
+			Fn: func(ctx job.JobRuntime) error {
+				close(started)
+				<-release
+				ctx.History.SuccessCount = 1
+				return nil
+			},
+		}
+
+		cronRunner := cron.New()
+		Expect(triggered.AddToScheduler(cronRunner)).To(Succeed())
+		DeferCleanup(func() {
+			triggered.RemoveFromScheduler(cronRunner)
+			cronRunner.Stop()
+		})
+
+		// Scheduled runs of an hourly job are delayed by up to a minute and a half of jitter
+		Expect(job.TriggerJob(triggered.ID())).To(Succeed())
+		Eventually(started).WithTimeout(5 * time.Second).Should(BeClosed())
+
+		status, err := job.GetJob(triggered.ID())
+		Expect(err).To(BeNil())
+		Expect(status.LastRun).ToNot(BeNil())
+		Expect(status.LastRun.Status).To(Equal(models.StatusRunning))
+
+		close(release)
+		Eventually(func() *models.JobHistory {
+			status, _ := job.GetJob(triggered.ID())
+			return status.LastRun
+		}).Should(And(HaveField("Status", models.StatusSuccess), HaveField("SuccessCount", 1)))
+	})
+})
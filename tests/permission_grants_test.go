@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"time"
+
+	"github.com/flanksource/duty/api"
+	"github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/rbac"
+	"github.com/flanksource/duty/rbac/policy"
+	"github.com/flanksource/duty/tests/fixtures/dummy"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+)
+
+var _ = Describe("Permission grants", Ordered, func() {
+	BeforeAll(func() {
+		Expect(rbac.Init(DefaultContext, nil)).To(Succeed())
+	})
+
+	AfterAll(func() {
+		rbac.Reset()
+	})
+
+	subject := dummy.JohnWick.ID.String()
+	canRead := func() bool {
+		return rbac.Check(DefaultContext, subject, policy.ObjectCatalog, policy.ActionRead)
+	}
+
+	review := func() rbac.SubjectAccessReviewResult {
+		results, err := rbac.RunSubjectAccessReview(DefaultContext, rbac.SubjectAccessReviewRequest{
+			Resource: rbac.SubjectAccessReviewResource{Global: policy.ObjectCatalog},
+			Action:   policy.ActionRead,
+			Subjects: []string{subject},
+		})
+		Expect(err).To(BeNil())
+		Expect(results).To(HaveLen(1))
+		return results[0]
+	}
+
+	casbinRules := func(permissionID *uuid.UUID) int64 {
+		var count int64
+		Expect(DefaultContext.DB().Table("casbin_rule").Where("ptype = 'p' AND v5 = ?", permissionID.String()).Count(&count).Error).To(BeNil())
+		return count
+	}
+	events := func(grant *models.PermissionGrant) []string {
+		var events []models.PermissionGrantEvent
+		Expect(DefaultContext.DB().Where("grant_id = ?", grant.ID).Order("created_at").Find(&events).Error).To(BeNil())
+		return lo.Map(events, func(e models.PermissionGrantEvent, _ int) string { return e.Event })
+	}
+
+	request := func(duration time.Duration) *models.PermissionGrant {
+		grant, err := rbac.RequestGrant(DefaultContext, rbac.GrantRequest{
+			Subject:  dummy.JohnWick.ID.String(),
+			Action:   policy.ActionRead,
+			Object:   policy.ObjectCatalog,
+			Duration: duration,
+			Reason:   "investigating an incident",
+		}, &dummy.JohnWick.ID)
+		Expect(err).To(BeNil())
+		Expect(grant.Status).To(Equal(models.PermissionGrantStatusPending))
+		DeferCleanup(func() {
+			var current models.PermissionGrant
+			Expect(DefaultContext.DB().Where("id = ?", grant.ID).First(&current).Error).To(BeNil())
+			Expect(DefaultContext.DB().Delete(&current).Error).To(BeNil())
+			if current.PermissionID != nil {
+				Expect(DefaultContext.DB().Delete(&models.Permission{}, "id = ?", *current.PermissionID).Error).To(BeNil())
+			}
+		})
+		return grant
+	}
+
+	It("should create a time-bounded permission on approval and delete it on revocation", func() {
+		grant := request(time.Hour)
+
+		_, err := rbac.ApproveGrant(DefaultContext, grant.ID, dummy.JohnWick.ID)
+		Expect(api.ErrorCode(err)).To(Equal(api.EFORBIDDEN), "requesters cannot approve their own grants")
+
+		approved, err := rbac.ApproveGrant(DefaultContext, grant.ID, dummy.JohnDoe.ID)
+		Expect(err).To(BeNil())
+		Expect(approved.Status).To(Equal(models.PermissionGrantStatusApproved))
+		Expect(approved.ExpiresAt).ToNot(BeNil())
+		Expect(*approved.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+
+		var permission models.Permission
+		Expect(DefaultContext.DB().Where("id = ?", approved.PermissionID).First(&permission).Error).To(BeNil())
+		Expect(permission.Source).To(Equal(rbac.SourceJIT))
+		Expect(permission.Subject).To(Equal(dummy.JohnWick.ID.String()))
+		Expect(permission.Until).ToNot(BeNil())
+		Expect(*permission.Until).To(BeTemporally("~", *approved.ExpiresAt, time.Second))
+
+		_, err = rbac.ApproveGrant(DefaultContext, grant.ID, dummy.JohnDoe.ID)
+		Expect(api.ErrorCode(err)).To(Equal(api.ECONFLICT))
+
+		Expect(rbac.RevokeGrant(DefaultContext, grant.ID, &dummy.JohnDoe.ID)).To(Succeed())
+		Expect(DefaultContext.DB().Where("id = ?", approved.PermissionID).First(&permission).Error).To(BeNil())
+		Expect(permission.DeletedAt).ToNot(BeNil())
+
+		Expect(events(grant)).To(Equal([]string{"requested", models.PermissionGrantStatusApproved, models.PermissionGrantStatusRevoked}))
+	})
+
+	It("should expire grants when they run out", func() {
+		grant := request(time.Second)
+		_, err := rbac.ApproveGrant(DefaultContext, grant.ID, dummy.JohnDoe.ID)
+		Expect(err).To(BeNil())
+
+		Eventually(func() string {
+			var current models.PermissionGrant
+			Expect(DefaultContext.DB().Where("id = ?", grant.ID).First(&current).Error).To(BeNil())
+			return current.Status
+		}, "5s", "100ms").Should(Equal(models.PermissionGrantStatusExpired))
+
+		Expect(events(grant)).To(Equal([]string{"requested", models.PermissionGrantStatusApproved, models.PermissionGrantStatusExpired}))
+	})
+
+	It("should expire grants that were missed by the background job", func() {
+		grant := request(time.Hour)
+		approved, err := rbac.ApproveGrant(DefaultContext, grant.ID, dummy.JohnDoe.ID)
+		Expect(err).To(BeNil())
+
+		// As if the process restarted after the grant expired
+		Expect(DefaultContext.DB().Model(approved).Update("expires_at", time.Now().Add(-time.Minute)).Error).To(BeNil())
+
+		expired, err := rbac.ExpireGrants(DefaultContext, time.Minute)
+		Expect(err).To(BeNil())
+		Expect(expired).To(BeNumerically(">=", 1))
+
+		var current models.PermissionGrant
+		Expect(DefaultContext.DB().Where("id = ?", grant.ID).First(&current).Error).To(BeNil())
+		Expect(current.Status).To(Equal(models.PermissionGrantStatusExpired))
+		Expect(current.RevokedAt).ToNot(BeNil())
+	})
+
+	It("should allow access only while the grant is approved", func() {
+		grant := request(time.Hour)
+		Expect(canRead()).To(BeFalse())
+
+		approved, err := rbac.ApproveGrant(DefaultContext, grant.ID, dummy.JohnDoe.ID)
+		Expect(err).To(BeNil())
+		Expect(canRead()).To(BeTrue())
+
+		result := review()
+		Expect(result.Allowed).To(BeTrue())
+		Expect(result.ExpiresAt).ToNot(BeNil())
+		Expect(*result.ExpiresAt).To(BeTemporally("~", *approved.ExpiresAt, time.Second))
+		ttl, err := time.ParseDuration(result.TTL)
+		Expect(err).To(BeNil())
+		Expect(ttl).To(BeNumerically("~", time.Hour, time.Minute))
+
+		Expect(rbac.RevokeGrant(DefaultContext, grant.ID, &dummy.JohnDoe.ID)).To(Succeed())
+		Expect(canRead()).To(BeFalse())
+		Expect(casbinRules(approved.PermissionID)).To(BeZero())
+
+		result = review()
+		Expect(result.Allowed).To(BeFalse())
+		Expect(result.ExpiresAt).To(BeNil())
+		Expect(result.TTL).To(BeEmpty())
+	})
+
+	It("should deny access once the grant expires", func() {
+		grant := request(time.Second)
+		_, err := rbac.ApproveGrant(DefaultContext, grant.ID, dummy.JohnDoe.ID)
+		Expect(err).To(BeNil())
+		Expect(canRead()).To(BeTrue())
+
+		Eventually(canRead, "5s", "100ms").Should(BeFalse())
+	})
+
+	It("should remove the rules of expired permissions that were left in casbin_rule", func() {
+		grant := request(time.Hour)
+		approved, err := rbac.ApproveGrant(DefaultContext, grant.ID, dummy.JohnDoe.ID)
+		Expect(err).To(BeNil())
+		Expect(casbinRules(approved.PermissionID)).To(Equal(int64(1)))
+
+		// As if the grant was expired long ago, but removing its rule failed
+		Expect(DefaultContext.DB().Model(approved).Update("status", models.PermissionGrantStatusExpired).Error).To(BeNil())
+		Expect(DefaultContext.DB().Model(&models.Permission{}).Where("id = ?", approved.PermissionID).
+			Update("until", time.Now().Add(-time.Hour)).Error).To(BeNil())
+		Expect(canRead()).To(BeTrue())
+
+		_, err = rbac.ExpireGrants(DefaultContext, time.Minute)
+		Expect(err).To(BeNil())
+		Expect(casbinRules(approved.PermissionID)).To(BeZero())
+		Expect(canRead()).To(BeFalse())
+	})
+
+	It("should reject invalid requests", func() {
+		_, err := rbac.RequestGrant(DefaultContext, rbac.GrantRequest{
+			Subject:  dummy.JohnWick.ID.String(),
+			Action:   policy.ActionRead,
+			Object:   policy.ObjectCatalog,
+			Duration: 30 * 24 * time.Hour,
+		}, nil)
+		Expect(api.ErrorCode(err)).To(Equal(api.EINVALID))
+	})
+})
@@ -1939,6 +1939,102 @@ var _ = Describe("RLS test", Ordered, ContinueOnFailure, func() {
 		}
 	})
 
+	var _ = Describe("check claims query", func() {
+		var (
+			tx                        *gorm.DB
+			logisticsChecks           int64
+			logisticsAPICanaryStatus  int64
+			logisticsDBCheckStatuses  int64
+			totalIncidents            int64
+			totalNotifications        int64
+			totalNotificationsHistory int64
+		)
+
+		count := func(payload rls.Payload, model any) int64 {
+			GinkgoHelper()
+			Expect(payload.SetPostgresSessionRLS(tx)).To(BeNil())
+
+			var count int64
+			Expect(tx.Model(model).Count(&count).Error).To(BeNil())
+			return count
+		}
+
+		BeforeAll(func() {
+			tx = DefaultContext.DB().Session(&gorm.Session{NewDB: true}).Begin(&sql.TxOptions{ReadOnly: true})
+
+			db := DefaultContext.DB()
+			Expect(db.Model(&models.Check{}).Where("labels @> ?", `{"app":"logistics"}`).Count(&logisticsChecks).Error).To(BeNil())
+			Expect(db.Model(&models.CheckStatus{}).Where("check_id IN (SELECT id FROM checks WHERE canary_id = ?)", dummy.LogisticsAPICanary.ID).Count(&logisticsAPICanaryStatus).Error).To(BeNil())
+			Expect(db.Model(&models.CheckStatus{}).Where("check_id = ?", dummy.LogisticsDBCheck.ID).Count(&logisticsDBCheckStatuses).Error).To(BeNil())
+			Expect(db.Model(&models.Incident{}).Count(&totalIncidents).Error).To(BeNil())
+			Expect(db.Model(&models.Notification{}).Count(&totalNotifications).Error).To(BeNil())
+			Expect(db.Model(&models.NotificationSendHistory{}).Count(&totalNotificationsHistory).Error).To(BeNil())
+
+			Expect(logisticsChecks).To(BeNumerically(">", 1))
+			Expect(logisticsAPICanaryStatus).To(BeNumerically(">", 0))
+			Expect(logisticsDBCheckStatuses).To(BeNumerically(">", 0))
+			Expect(totalIncidents).To(BeNumerically(">", 1))
+			Expect(totalNotifications).To(BeNumerically(">", 0))
+		})
+
+		AfterAll(func() {
+			Expect(tx.Commit().Error).To(BeNil())
+		})
+
+		It("should scope checks by their labels", func() {
+			payload := rls.Payload{Check: []rls.Scope{{Tags: map[string]string{"app": "logistics"}}}}
+			Expect(count(payload, &models.Check{})).To(Equal(logisticsChecks))
+		})
+
+		It("should prefer the check claim over the canary scopes", func() {
+			payload := rls.Payload{
+				Canary: []rls.Scope{{Names: []string{dummy.LogisticsAPICanary.Name}}},
+				Check:  []rls.Scope{{Names: []string{dummy.LogisticsDBCheck.Name}}},
+			}
+			Expect(count(payload, &models.Check{})).To(Equal(int64(1)))
+		})
+
+		It("should apply deny scopes on checks", func() {
+			payload := rls.Payload{Check: []rls.Scope{
+				{Tags: map[string]string{"app": "logistics"}},
+				{Names: []string{dummy.LogisticsDBCheck.Name}, Deny: true},
+			}}
+			Expect(count(payload, &models.Check{})).To(Equal(logisticsChecks - 1))
+		})
+
+		It("should scope check statuses by their check", func() {
+			canary := rls.Payload{Canary: []rls.Scope{{Names: []string{dummy.LogisticsAPICanary.Name}}}}
+			Expect(count(canary, &models.CheckStatus{})).To(Equal(logisticsAPICanaryStatus))
+
+			check := rls.Payload{Check: []rls.Scope{{ID: dummy.LogisticsDBCheck.ID.String()}}}
+			Expect(count(check, &models.CheckStatus{})).To(Equal(logisticsDBCheckStatuses))
+		})
+
+		It("should only scope incidents with an incident claim", func() {
+			config := rls.Payload{Config: []rls.Scope{{Tags: map[string]string{"cluster": "aws"}}}}
+			Expect(count(config, &models.Incident{})).To(Equal(totalIncidents))
+
+			byTitle := rls.Payload{Incident: []rls.Scope{{Names: []string{dummy.LogisticsAPIDownIncident.Title}}}}
+			Expect(count(byTitle, &models.Incident{})).To(Equal(int64(1)))
+
+			byID := rls.Payload{Incident: []rls.Scope{{ID: dummy.UIDownIncident.ID.String()}}}
+			Expect(count(byID, &models.Incident{})).To(Equal(int64(1)))
+		})
+
+		It("should only scope notifications with a notification claim", func() {
+			config := rls.Payload{Config: []rls.Scope{{Tags: map[string]string{"cluster": "aws"}}}}
+			Expect(count(config, &models.Notification{})).To(Equal(totalNotifications))
+			Expect(count(config, &models.NotificationSendHistory{})).To(Equal(totalNotificationsHistory))
+
+			byName := rls.Payload{Notification: []rls.Scope{{Names: []string{dummy.NoMatchNotification.Name}}}}
+			Expect(count(byName, &models.Notification{})).To(Equal(int64(1)))
+
+			none := rls.Payload{Notification: []rls.Scope{{Names: []string{"non-existent-notification"}}}}
+			Expect(count(none, &models.Notification{})).To(Equal(int64(0)))
+			Expect(count(none, &models.NotificationSendHistory{})).To(Equal(int64(0)))
+		})
+	})
+
 	var _ = Describe("views query", func() {
 		var (
 			tx                  *gorm.DB
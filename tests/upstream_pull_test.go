@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	ginkgo "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/tests/setup"
+	"github.com/flanksource/duty/upstream"
+)
+
+var _ = ginkgo.Describe("Upstream pull", ginkgo.Ordered, ginkgo.Label("slow"), func() {
+	var upstreamCtx *context.Context
+	var echoCloser, drop func()
+	var client *upstream.UpstreamClient
+
+	permission := models.Permission{
+		ID:      uuid.New(),
+		Name:    "pulled-permission",
+		Subject: "viewer",
+		Action:  "read",
+		Object:  "catalog",
+		Source:  models.SourceUI,
+	}
+	property := models.AppProperty{Name: "pull.test", Value: "upstream"}
+
+	// Properties of the agent itself are never pulled
+	reserved := models.AppProperty{Name: "artifacts.pull.test", Value: "upstream"}
+
+	localValue := func() string {
+		var value string
+		Expect(DefaultContext.DB().Raw("SELECT value FROM properties WHERE name = ?", property.Name).Scan(&value).Error).To(BeNil())
+		return value
+	}
+
+	pulled := func(table, id string) models.UpstreamPulledObject {
+		var record models.UpstreamPulledObject
+		Expect(DefaultContext.DB().Where("table_name = ? AND id = ?", table, id).First(&record).Error).To(BeNil())
+		return record
+	}
+
+	ginkgo.BeforeAll(func() {
+		var err error
+		upstreamCtx, drop, err = setup.NewDB(DefaultContext, "upstream_pull")
+		Expect(err).ToNot(HaveOccurred())
+
+		e := echo.New()
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				c.SetRequest(c.Request().WithContext(upstreamCtx.Wrap(c.Request().Context())))
+				return next(c)
+			}
+		})
+		e.Use(upstream.AgentAuthMiddleware(cache.New(time.Hour, time.Hour)))
+		e.POST("/upstream/pull", upstream.PullHandler)
+
+		var port int
+		port, echoCloser = setup.RunEcho(e)
+		client = upstream.NewUpstreamClient(upstream.UpstreamConfig{
+			Host:      fmt.Sprintf("http://localhost:%d", port),
+			AgentName: "pull-agent",
+		})
+
+		Expect(upstreamCtx.DB().Create(&permission).Error).To(BeNil())
+		Expect(context.UpdateProperty(*upstreamCtx, "upstream.pull.properties", "pull.,artifacts.")).To(BeNil())
+		Expect(context.UpdateProperty(*upstreamCtx, property.Name, property.Value)).To(BeNil())
+		Expect(context.UpdateProperty(*upstreamCtx, reserved.Name, reserved.Value)).To(BeNil())
+	})
+
+	ginkgo.AfterAll(func() {
+		Expect(DefaultContext.DB().Delete(&models.Permission{}, "id = ?", permission.ID).Error).To(BeNil())
+		Expect(DefaultContext.DB().Exec("DELETE FROM properties WHERE name IN (?, 'upstream.pull.conflict')", property.Name).Error).To(BeNil())
+		Expect(DefaultContext.DB().Exec("DELETE FROM upstream_pulled_objects").Error).To(BeNil())
+		DefaultContext.ClearCache()
+		echoCloser()
+		drop()
+	})
+
+	ginkgo.It("should pull down upstream objects", func() {
+		summary, err := upstream.PullAll(DefaultContext, client, 1)
+		Expect(err).To(BeNil())
+		Expect(summary["permissions"].Applied).To(BeNumerically(">=", 1))
+		Expect(summary["properties"].Applied).To(BeNumerically(">=", 1))
+
+		var local models.Permission
+		Expect(DefaultContext.DB().Where("id = ?", permission.ID).First(&local).Error).To(BeNil())
+		Expect(local.Name).To(Equal(permission.Name))
+		Expect(localValue()).To(Equal("upstream"))
+		Expect(pulled("permissions", permission.ID.String()).Status).To(Equal(models.UpstreamPullStatusApplied))
+
+		var reservedCount int64
+		Expect(DefaultContext.DB().Model(&models.AppProperty{}).Where("name IN (?, 'upstream.pull.properties')", reserved.Name).Count(&reservedCount).Error).To(BeNil())
+		Expect(reservedCount).To(BeZero())
+
+		summary, err = upstream.PullAll(DefaultContext, client, 100)
+		Expect(err).To(BeNil())
+		Expect(summary).To(BeEmpty(), "nothing changed since the last pull")
+	})
+
+	ginkgo.It("should pull down deletions", func() {
+		Expect(upstreamCtx.DB().Model(&models.Permission{}).Where("id = ?", permission.ID).Update("deleted_at", time.Now()).Error).To(BeNil())
+
+		summary, err := upstream.PullAll(DefaultContext, client, 100)
+		Expect(err).To(BeNil())
+		Expect(summary["permissions"].Applied).To(Equal(1))
+
+		var local models.Permission
+		Expect(DefaultContext.DB().Where("id = ?", permission.ID).First(&local).Error).To(BeNil())
+		Expect(local.DeletedAt).ToNot(BeNil())
+	})
+
+	ginkgo.It("should overwrite local edits by default", func() {
+		Expect(DefaultContext.DB().Exec("UPDATE properties SET value = 'agent' WHERE name = ?", property.Name).Error).To(BeNil())
+		Expect(upstreamCtx.DB().Exec("UPDATE properties SET value = 'upstream-1' WHERE name = ?", property.Name).Error).To(BeNil())
+
+		summary, err := upstream.PullAll(DefaultContext, client, 100)
+		Expect(err).To(BeNil())
+		Expect(summary["properties"].Applied).To(Equal(1))
+		Expect(localValue()).To(Equal("upstream-1"))
+	})
+
+	ginkgo.It("should keep local edits when the agent wins conflicts", func() {
+		Expect(context.UpdateProperty(DefaultContext, "upstream.pull.conflict", upstream.PullConflictAgentWins)).To(BeNil())
+		Expect(DefaultContext.DB().Exec("UPDATE properties SET value = 'agent' WHERE name = ?", property.Name).Error).To(BeNil())
+		Expect(upstreamCtx.DB().Exec("UPDATE properties SET value = 'upstream-2' WHERE name = ?", property.Name).Error).To(BeNil())
+
+		summary, err := upstream.PullAll(DefaultContext, client, 100)
+		Expect(err).To(BeNil())
+		Expect(summary["properties"].Conflicts).To(ConsistOf(property.Name))
+		Expect(localValue()).To(Equal("agent"))
+		Expect(pulled("properties", property.Name).Status).To(Equal(models.UpstreamPullStatusConflict))
+	})
+
+	ginkgo.It("should only pull the objects of the agent and retry the ones that failed", func() {
+		agent, err := upstream.GetOrCreateAgent(*upstreamCtx, "pull-agent")
+		Expect(err).To(BeNil())
+		other, err := upstream.GetOrCreateAgent(*upstreamCtx, "other-pull-agent")
+		Expect(err).To(BeNil())
+
+		newConfig := func(agentID uuid.UUID) models.ConfigItem {
+			return models.ConfigItem{
+				ID:          uuid.New(),
+				AgentID:     agentID,
+				ConfigClass: "Pod",
+				Type:        lo.ToPtr("Kubernetes::Pod"),
+				Name:        lo.ToPtr("pulled-pod"),
+			}
+		}
+		config, otherConfig := newConfig(agent.ID), newConfig(other.ID)
+		Expect(upstreamCtx.DB().Create(&[]models.ConfigItem{config, otherConfig}).Error).To(BeNil())
+
+		newPermission := func(configID uuid.UUID) models.Permission {
+			return models.Permission{ID: uuid.New(), Name: "pulled-config-permission", Subject: "viewer", Action: "read", ConfigID: &configID, Source: models.SourceUI}
+		}
+		own, others := newPermission(config.ID), newPermission(otherConfig.ID)
+		Expect(upstreamCtx.DB().Create(&[]models.Permission{own, others}).Error).To(BeNil())
+
+		ginkgo.By("failing to apply the permission of a config that was not scraped by the agent yet")
+		summary, err := upstream.PullAll(DefaultContext, client, 100)
+		Expect(err).To(BeNil())
+		Expect(summary["permissions"].Failed).To(ConsistOf(own.ID.String()))
+
+		var otherCount int64
+		Expect(DefaultContext.DB().Model(&models.UpstreamPulledObject{}).Where("id = ?", others.ID.String()).Count(&otherCount).Error).To(BeNil())
+		Expect(otherCount).To(BeZero(), "objects of other agents must not be pulled")
+
+		ginkgo.By("retrying the permission once the config exists on the agent")
+		local := newConfig(uuid.Nil)
+		local.ID = config.ID
+		Expect(DefaultContext.DB().Create(&local).Error).To(BeNil())
+
+		summary, err = upstream.PullAll(DefaultContext, client, 100)
+		Expect(err).To(BeNil())
+		Expect(summary["permissions"].Applied).To(Equal(1))
+		Expect(pulled("permissions", own.ID.String()).Status).To(Equal(models.UpstreamPullStatusApplied))
+
+		Expect(DefaultContext.DB().Delete(&models.Permission{}, "id = ?", own.ID).Error).To(BeNil())
+		Expect(DefaultContext.DB().Delete(&local).Error).To(BeNil())
+	})
+
+	ginkgo.It("should pull past an object that keeps failing", func() {
+		Expect(context.UpdateProperty(DefaultContext, "upstream.pull.max_attempts", "2")).To(BeNil())
+
+		// The playbook only exists on the upstream
+		playbook := models.Playbook{ID: uuid.New(), Name: "upstream-only", Spec: []byte("{}"), Source: models.SourceUI}
+		Expect(upstreamCtx.DB().Create(&playbook).Error).To(BeNil())
+
+		failing := models.Permission{ID: uuid.New(), Name: "pulled-playbook-permission", Subject: "viewer", Action: "playbook:run", PlaybookID: &playbook.ID, Source: models.SourceUI}
+		Expect(upstreamCtx.DB().Create(&failing).Error).To(BeNil())
+
+		// More objects than the batch size after the failing one
+		var later []models.Permission
+		for i := range 3 {
+			p := models.Permission{ID: uuid.New(), Name: fmt.Sprintf("pulled-permission-%d", i), Subject: "viewer", Action: "read", Object: "catalog", Source: models.SourceUI}
+			Expect(upstreamCtx.DB().Create(&p).Error).To(BeNil())
+			later = append(later, p)
+		}
+		laterIDs := lo.Map(later, func(p models.Permission, _ int) uuid.UUID { return p.ID })
+
+		summary, err := upstream.PullAll(DefaultContext, client, 2)
+		Expect(err).To(BeNil())
+		Expect(summary["permissions"].Failed).To(ConsistOf(failing.ID.String()))
+		Expect(pulled("permissions", failing.ID.String()).Attempts).To(Equal(1))
+
+		ginkgo.By("giving up on the failing object once it reached upstream.pull.max_attempts")
+		summary, err = upstream.PullAll(DefaultContext, client, 2)
+		Expect(err).To(BeNil())
+		Expect(summary["permissions"].Failed).To(ConsistOf(failing.ID.String()))
+		Expect(pulled("permissions", failing.ID.String()).Attempts).To(Equal(2))
+
+		var count int64
+		Expect(DefaultContext.DB().Model(&models.Permission{}).Where("id IN ?", laterIDs).Count(&count).Error).To(BeNil())
+		Expect(count).To(BeEquivalentTo(len(later)))
+
+		summary, err = upstream.PullAll(DefaultContext, client, 2)
+		Expect(err).To(BeNil())
+		Expect(summary).To(BeEmpty(), "the failing object is not pulled again")
+
+		Expect(DefaultContext.DB().Delete(&models.Permission{}, "id IN ?", laterIDs).Error).To(BeNil())
+		Expect(DefaultContext.DB().Exec("DELETE FROM properties WHERE name = 'upstream.pull.max_attempts'").Error).To(BeNil())
+		DefaultContext.ClearCache()
+	})
+})
@@ -0,0 +1,176 @@
+package tests
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	ginkgo "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/tests/setup"
+	"github.com/flanksource/duty/upstream"
+)
+
+var _ = ginkgo.Describe("Upstream spool", ginkgo.Ordered, func() {
+	var (
+		echoCloser func()
+		client     *upstream.UpstreamClient
+
+		lock     sync.Mutex
+		online   bool
+		received []string
+	)
+
+	setOnline := func(v bool) {
+		lock.Lock()
+		defer lock.Unlock()
+		online = v
+	}
+
+	spooled := func() []models.UpstreamSpoolEntry {
+		var entries []models.UpstreamSpoolEntry
+		Expect(DefaultContext.DB().Order("id").Find(&entries).Error).To(BeNil())
+		return entries
+	}
+
+	deleteMsg := func(name string) *upstream.PushData {
+		return &upstream.PushData{Topologies: []models.Topology{{ID: uuid.New(), Name: name}}}
+	}
+
+	ginkgo.BeforeAll(func() {
+		e := echo.New()
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				lock.Lock()
+				defer lock.Unlock()
+				if !online {
+					return c.NoContent(http.StatusServiceUnavailable)
+				}
+				return next(c)
+			}
+		})
+		e.GET("/upstream/ping", func(c echo.Context) error { return nil })
+		e.DELETE("/upstream/push", func(c echo.Context) error {
+			var msg upstream.PushData
+			if err := json.NewDecoder(c.Request().Body).Decode(&msg); err != nil {
+				return err
+			}
+			received = append(received, "delete:"+msg.Topologies[0].Name)
+			return nil
+		})
+		e.POST("/upstream/artifacts/:id", func(c echo.Context) error {
+			content, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return err
+			}
+			received = append(received, "artifact:"+string(content))
+			return nil
+		})
+
+		var port int
+		port, echoCloser = setup.RunEcho(e)
+		client = upstream.NewUpstreamClient(upstream.UpstreamConfig{
+			Host:      fmt.Sprintf("http://localhost:%d", port),
+			AgentName: "spool-agent",
+		})
+	})
+
+	ginkgo.AfterAll(func() {
+		Expect(DefaultContext.DB().Exec("DELETE FROM upstream_spool").Error).To(BeNil())
+		echoCloser()
+	})
+
+	ginkgo.It("should spool deletes and artifacts while the upstream is unreachable", func() {
+		setOnline(false)
+
+		Expect(client.Delete(DefaultContext, deleteMsg("first"))).To(BeNil())
+		Expect(client.PushArtifacts(DefaultContext, uuid.New(), io.NopCloser(strings.NewReader("second")))).To(BeNil())
+
+		// Sent in order after the earlier entries, even though the upstream is back
+		setOnline(true)
+		Expect(client.Delete(DefaultContext, deleteMsg("third"))).To(BeNil())
+
+		entries := spooled()
+		Expect(entries).To(HaveLen(3))
+		Expect(entries[1].Kind).To(Equal(models.UpstreamSpoolKindArtifact))
+		Expect(received).To(BeEmpty())
+	})
+
+	ginkgo.It("should drain the spool in order", func() {
+		setOnline(true)
+
+		drained, err := upstream.DrainSpool(DefaultContext, client)
+		Expect(err).To(BeNil())
+		Expect(drained).To(Equal(3))
+		Expect(received).To(Equal([]string{"delete:first", "artifact:second", "delete:third"}))
+		Expect(spooled()).To(BeEmpty())
+	})
+
+	ginkgo.It("should evict the oldest entries when the spool is full", func() {
+		Expect(context.UpdateProperty(DefaultContext, "upstream.spool.max_entries", "2")).To(BeNil())
+		defer func() {
+			Expect(DefaultContext.DB().Exec("DELETE FROM properties WHERE name = 'upstream.spool.max_entries'").Error).To(BeNil())
+			DefaultContext.ClearCache()
+		}()
+
+		setOnline(false)
+		for _, name := range []string{"a", "b", "c"} {
+			Expect(client.Delete(DefaultContext, deleteMsg(name))).To(BeNil())
+		}
+
+		entries := spooled()
+		Expect(entries).To(HaveLen(2))
+
+		var oldest upstream.PushData
+		Expect(json.Unmarshal(entries[0].Payload, &oldest)).To(BeNil())
+		Expect(oldest.Topologies[0].Name).To(Equal("b"))
+
+		_, err := upstream.DrainSpool(DefaultContext, client)
+		Expect(err).ToNot(BeNil(), "the upstream is unreachable")
+		Expect(spooled()).To(HaveLen(2))
+	})
+
+	ginkgo.It("should reject entries larger than the spool", func() {
+		Expect(DefaultContext.DB().Exec("DELETE FROM upstream_spool").Error).To(BeNil())
+		Expect(context.UpdateProperty(DefaultContext, "upstream.spool.max_size", "4")).To(BeNil())
+		defer func() {
+			Expect(DefaultContext.DB().Exec("DELETE FROM properties WHERE name = 'upstream.spool.max_size'").Error).To(BeNil())
+			DefaultContext.ClearCache()
+		}()
+
+		setOnline(false)
+		err := client.PushArtifacts(DefaultContext, uuid.New(), io.NopCloser(strings.NewReader("too large")))
+		Expect(err).To(MatchError(ContainSubstring("exceeds upstream.spool.max_size")))
+		Expect(spooled()).To(BeEmpty())
+
+		ginkgo.By("reading no more than upstream.spool.max_size bytes of an artifact spooled behind earlier entries")
+		Expect(DefaultContext.DB().Create(&models.UpstreamSpoolEntry{Kind: models.UpstreamSpoolKindDelete, Payload: []byte("{}"), Size: 2}).Error).To(BeNil())
+		err = client.PushArtifacts(DefaultContext, uuid.New(), io.NopCloser(rand.Reader))
+		Expect(err).To(MatchError(ContainSubstring("exceeds upstream.spool.max_size")))
+		Expect(spooled()).To(HaveLen(1))
+		Expect(DefaultContext.DB().Exec("DELETE FROM upstream_spool").Error).To(BeNil())
+	})
+
+	ginkgo.It("should drain the spool with a job", func() {
+		setOnline(false)
+		Expect(client.PushArtifacts(DefaultContext, uuid.New(), io.NopCloser(strings.NewReader("from job")))).To(BeNil())
+		Expect(spooled()).To(HaveLen(1))
+
+		setOnline(true)
+		drainJob := upstream.DrainSpoolJob(client)
+		drainJob.Context = DefaultContext
+		drainJob.Run()
+		Expect(drainJob.LastJob.SuccessCount).To(Equal(1))
+		Expect(received).To(ContainElement("artifact:from job"))
+		Expect(spooled()).To(BeEmpty())
+	})
+})
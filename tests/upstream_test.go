@@ -15,6 +15,7 @@ import (
 	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
 	"github.com/samber/lo/mutable"
+	"gorm.io/gorm"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/yaml"
 
@@ -494,6 +495,91 @@ var _ = ginkgo.Describe("Reconcile Test", ginkgo.Ordered, ginkgo.Label("slow"),
 		})
 	})
 
+	ginkgo.It("should push compressed chunks only once", func() {
+		Expect(context.UpdateProperties(DefaultContext, map[string]string{
+			"upstream.push.compression": upstream.CompressionZstd,
+			"upstream.push.chunk_size":  "2048",
+		})).To(BeNil())
+		defer func() {
+			Expect(DefaultContext.DB().Exec("DELETE FROM properties WHERE name IN ('upstream.push.compression', 'upstream.push.chunk_size')").Error).To(BeNil())
+			DefaultContext.ClearCache()
+		}()
+
+		var histories []models.JobHistory
+		for i := 0; i < 20; i++ {
+			histories = append(histories, models.JobHistory{
+				ID:           uuid.New(),
+				Name:         "chunked-push",
+				ResourceType: "test",
+				ResourceID:   fmt.Sprintf("chunk-%d", i),
+				Status:       models.StatusSuccess,
+				TimeStart:    time.Now(),
+			})
+		}
+		msg := upstream.NewPushData(histories)
+		Expect(msg.Size()).To(BeNumerically(">", 2048))
+
+		countPushed := func() int64 {
+			var count int64
+			Expect(upstreamCtx.DB().Model(&models.JobHistory{}).Where("name = ?", "chunked-push").Count(&count).Error).To(BeNil())
+			return count
+		}
+
+		var chunksBefore int64
+		Expect(upstreamCtx.DB().Model(&models.UpstreamPushChunk{}).Count(&chunksBefore).Error).To(BeNil())
+
+		Expect(upstreamClient.Push(DefaultContext, msg)).To(BeNil())
+		Expect(countPushed()).To(BeNumerically("==", len(histories)))
+
+		var chunks int64
+		Expect(upstreamCtx.DB().Model(&models.UpstreamPushChunk{}).Count(&chunks).Error).To(BeNil())
+		Expect(chunks - chunksBefore).To(BeNumerically(">", 1))
+
+		// The same chunks pushed again, e.g. by the next reconcile after a later chunk failed, are skipped
+		Expect(upstreamCtx.DB().Where("name = ?", "chunked-push").Delete(&models.JobHistory{}).Error).To(BeNil())
+		Expect(upstreamClient.Push(DefaultContext, msg)).To(BeNil())
+		Expect(countPushed()).To(BeZero())
+
+		var chunksAfter int64
+		Expect(upstreamCtx.DB().Model(&models.UpstreamPushChunk{}).Count(&chunksAfter).Error).To(BeNil())
+		Expect(chunksAfter - chunksBefore).To(Equal(chunks))
+
+		// Modified data is pushed again
+		histories[0].SuccessCount++
+		Expect(upstreamClient.Push(DefaultContext, upstream.NewPushData(histories))).To(BeNil())
+		Expect(countPushed()).To(BeNumerically(">=", 1))
+
+		// Retried chunks are skipped by the upstream
+		pushWithKey := func(key string) {
+			resp, err := upstreamClient.R(DefaultContext).
+				QueryParam(upstream.AgentNameQueryParam, upstreamClient.AgentName).
+				Header(upstream.IdempotencyKeyHeader, key).
+				Post("push", upstream.NewPushData(histories))
+			Expect(err).To(BeNil())
+			Expect(resp.IsOK()).To(BeTrue())
+		}
+		Expect(upstreamCtx.DB().Where("name = ?", "chunked-push").Delete(&models.JobHistory{}).Error).To(BeNil())
+		key := uuid.NewString() + "/0"
+		pushWithKey(key)
+		Expect(countPushed()).To(BeNumerically("==", len(histories)))
+
+		Expect(upstreamCtx.DB().Where("name = ?", "chunked-push").Delete(&models.JobHistory{}).Error).To(BeNil())
+		pushWithKey(key)
+		Expect(countPushed()).To(BeZero())
+
+		// Expired keys are deleted by the cleanup job
+		Expect(upstreamCtx.DB().Model(&models.UpstreamPushChunk{}).Where("key = ?", key).
+			Update("created_at", gorm.Expr("NOW() - INTERVAL '2 hours'")).Error).To(BeNil())
+		upstream.CleanupPushChunksJob.Context = *upstreamCtx
+		upstream.CleanupPushChunksJob.Run()
+		Expect(upstream.CleanupPushChunksJob.LastJob.Status).To(Equal(models.StatusSuccess))
+		Expect(upstream.CleanupPushChunksJob.LastJob.SuccessCount).To(BeNumerically(">=", 1))
+
+		var remaining int64
+		Expect(upstreamCtx.DB().Model(&models.UpstreamPushChunk{}).Where("key = ?", key).Count(&remaining).Error).To(BeNil())
+		Expect(remaining).To(BeZero())
+	})
+
 	ginkgo.AfterAll(func() {
 		echoCloser()
 		drop()
@@ -0,0 +1,273 @@
+package upstream
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
+	"github.com/samber/lo"
+	"gorm.io/gorm/clause"
+
+	"github.com/flanksource/duty/api"
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/job"
+	"github.com/flanksource/duty/models"
+)
+
+// IdempotencyKeyHeader identifies a push chunk so the upstream can skip chunks it has already saved.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+const defaultPushChunkSize = 4 * 1024 * 1024
+
+// pushChunker splits push data into chunks of a bounded JSON size.
+type pushChunker struct {
+	maxSize int
+	chunks  []*PushData
+	current *PushData
+	size    int
+
+	// fields of the current chunk, whose keys add to its size
+	fields map[string]struct{}
+}
+
+func (c *pushChunker) flush() {
+	if c.current != nil && c.current.Count() > 0 {
+		c.chunks = append(c.chunks, c.current)
+	}
+	c.current = &PushData{}
+	c.size = 2 // {}
+	c.fields = map[string]struct{}{}
+}
+
+func chunkItems[T any](c *pushChunker, field string, items []T, add func(p *PushData, item T)) error {
+	for _, item := range items {
+		b, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+
+		size := len(b) + 1
+		if _, ok := c.fields[field]; !ok {
+			size += len(field) + 8 // "field":[],
+		}
+
+		// A single item larger than the limit is sent on its own
+		if c.size+size > c.maxSize && c.current.Count() > 0 {
+			c.flush()
+			size += len(field) + 8
+		}
+		add(c.current, item)
+		c.fields[field] = struct{}{}
+		c.size += size
+	}
+	return nil
+}
+
+// Chunks splits the push data into chunks whose JSON encoding is at most maxSize bytes.
+// Items keep the order in which InsertUpstreamMsg saves them so parents are pushed before their children.
+func (t *PushData) Chunks(maxSize int) ([]*PushData, error) {
+	if size, err := t.Size(); err != nil {
+		return nil, err
+	} else if size <= maxSize {
+		return []*PushData{t}, nil
+	}
+
+	c := &pushChunker{maxSize: maxSize}
+	c.flush()
+
+	err := chunkItems(c, "topologies", t.Topologies, func(p *PushData, i models.Topology) { p.Topologies = append(p.Topologies, i) })
+	if err == nil {
+		err = chunkItems(c, "canaries", t.Canaries, func(p *PushData, i models.Canary) { p.Canaries = append(p.Canaries, i) })
+	}
+	if err == nil {
+		err = chunkItems(c, "components", t.Components, func(p *PushData, i models.Component) { p.Components = append(p.Components, i) })
+	}
+	if err == nil {
+		err = chunkItems(c, "component_relationships", t.ComponentRelationships, func(p *PushData, i models.ComponentRelationship) {
+			p.ComponentRelationships = append(p.ComponentRelationships, i)
+		})
+	}
+	if err == nil {
+		err = chunkItems(c, "config_scrapers", t.ConfigScrapers, func(p *PushData, i models.ConfigScraper) { p.ConfigScrapers = append(p.ConfigScrapers, i) })
+	}
+	if err == nil {
+		err = chunkItems(c, "config_items", t.ConfigItems, func(p *PushData, i models.ConfigItem) { p.ConfigItems = append(p.ConfigItems, i) })
+	}
+	if err == nil {
+		err = chunkItems(c, "config_relationships", t.ConfigRelationships, func(p *PushData, i models.ConfigRelationship) {
+			p.ConfigRelationships = append(p.ConfigRelationships, i)
+		})
+	}
+	if err == nil {
+		err = chunkItems(c, "config_component_relationships", t.ConfigComponentRelationships, func(p *PushData, i models.ConfigComponentRelationship) {
+			p.ConfigComponentRelationships = append(p.ConfigComponentRelationships, i)
+		})
+	}
+	if err == nil {
+		err = chunkItems(c, "check_config_relationships", t.CheckConfigRelationships, func(p *PushData, i models.CheckConfigRelationship) {
+			p.CheckConfigRelationships = append(p.CheckConfigRelationships, i)
+		})
+	}
+	if err == nil {
+		err = chunkItems(c, "config_items_last_scraped_time", t.ConfigItemsLastScrapedTime, func(p *PushData, i models.ConfigItemLastScrapedTime) {
+			p.ConfigItemsLastScrapedTime = append(p.ConfigItemsLastScrapedTime, i)
+		})
+	}
+	if err == nil {
+		err = chunkItems(c, "checks_unlogged", t.ChecksUnlogged, func(p *PushData, i models.ChecksUnlogged) { p.ChecksUnlogged = append(p.ChecksUnlogged, i) })
+	}
+	if err == nil {
+		err = chunkItems(c, "config_changes", t.ConfigChanges, func(p *PushData, i models.ConfigChange) { p.ConfigChanges = append(p.ConfigChanges, i) })
+	}
+	if err == nil {
+		err = chunkItems(c, "config_analysis", t.ConfigAnalysis, func(p *PushData, i models.ConfigAnalysis) { p.ConfigAnalysis = append(p.ConfigAnalysis, i) })
+	}
+	if err == nil {
+		err = chunkItems(c, "checks", t.Checks, func(p *PushData, i models.Check) { p.Checks = append(p.Checks, i) })
+	}
+	if err == nil {
+		err = chunkItems(c, "artifacts", t.Artifacts, func(p *PushData, i models.Artifact) { p.Artifacts = append(p.Artifacts, i) })
+	}
+	if err == nil {
+		err = chunkItems(c, "job_history", t.JobHistory, func(p *PushData, i models.JobHistory) { p.JobHistory = append(p.JobHistory, i) })
+	}
+	if err == nil {
+		err = chunkItems(c, "view_panels", t.ViewPanels, func(p *PushData, i models.ViewPanel) { p.ViewPanels = append(p.ViewPanels, i) })
+	}
+	if err == nil {
+		views := lo.Keys(t.GeneratedViews)
+		slices.Sort(views)
+		for _, view := range views {
+			if err = chunkItems(c, "generated_views."+view, t.GeneratedViews[view], func(p *PushData, i models.GeneratedViewTable) {
+				if p.GeneratedViews == nil {
+					p.GeneratedViews = make(map[string][]models.GeneratedViewTable)
+				}
+				p.GeneratedViews[view] = append(p.GeneratedViews[view], i)
+			}); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = chunkItems(c, "check_statuses", t.CheckStatuses, func(p *PushData, i models.CheckStatus) { p.CheckStatuses = append(p.CheckStatuses, i) })
+	}
+	if err == nil {
+		err = chunkItems(c, "playbook_actions", t.PlaybookActions, func(p *PushData, i models.PlaybookRunAction) { p.PlaybookActions = append(p.PlaybookActions, i) })
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error chunking push data: %w", err)
+	}
+
+	c.flush()
+	return c.chunks, nil
+}
+
+// pushIdempotencyKey identifies a chunk of a push by its method and encoded contents,
+// so the same chunk pushed again within upstream.push.idempotency_ttl is skipped.
+func pushIdempotencyKey(method string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func compressPushBody(encoding string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "", CompressionNone:
+		return body, nil
+	case CompressionGzip:
+		w = gzip.NewWriter(&buf)
+	case CompressionZstd:
+		var err error
+		if w, err = zstd.NewWriter(&buf); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression %q, only %s, %s and %s are supported", encoding, CompressionNone, CompressionGzip, CompressionZstd)
+	}
+
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressPushBody decodes a request body by its Content-Encoding.
+// Legacy agents send uncompressed bodies without a Content-Encoding.
+func decompressPushBody(encoding string, body io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case "", "identity":
+		return io.NopCloser(body), nil
+	case CompressionGzip:
+		return gzip.NewReader(body)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, api.Errorf(api.EINVALID, "unsupported content encoding %q", encoding)
+	}
+}
+
+// isPushChunkSaved returns true when the chunk was saved within the upstream.push.idempotency_ttl.
+func isPushChunkSaved(ctx context.Context, agentID uuid.UUID, key string) (bool, error) {
+	ttl := ctx.Properties().Duration("upstream.push.idempotency_ttl", time.Hour)
+
+	var saved bool
+	err := ctx.DB().Raw("SELECT EXISTS (SELECT 1 FROM upstream_push_chunks WHERE agent_id = ? AND key = ? AND created_at > ?)",
+		agentID, key, time.Now().Add(-ttl)).Scan(&saved).Error
+	return saved, err
+}
+
+func savePushChunk(ctx context.Context, agentID uuid.UUID, key string) error {
+	return ctx.DB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "agent_id"}, {Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]any{"created_at": clause.Expr{SQL: "NOW()"}}),
+	}).Create(&models.UpstreamPushChunk{AgentID: agentID, Key: key}).Error
+}
+
+// CleanupPushChunks deletes the idempotency keys of push chunks older than the given age.
+// Chunks retried after their key is deleted are saved again.
+func CleanupPushChunks(ctx context.Context, age time.Duration) (int, error) {
+	tx := ctx.DB().Where("created_at < ?", time.Now().Add(-age)).Delete(&models.UpstreamPushChunk{})
+	return int(tx.RowsAffected), tx.Error
+}
+
+// CleanupPushChunksJob deletes the idempotency keys of push chunks older than the upstream.push.idempotency_ttl.
+var CleanupPushChunksJob = &job.Job{
+	Name:       "CleanupUpstreamPushChunks",
+	Schedule:   "@every 1h",
+	Singleton:  true,
+	JobHistory: true,
+	Retention:  job.RetentionFew,
+	Fn: func(ctx job.JobRuntime) error {
+		deleted, err := CleanupPushChunks(ctx.Context, ctx.Properties().Duration("upstream.push.idempotency_ttl", time.Hour))
+		ctx.History.SuccessCount = deleted
+		return err
+	},
+}
+
+// Jobs are the jobs of the upstream that receives the pushes of agents.
+var Jobs = []*job.Job{CleanupPushChunksJob}
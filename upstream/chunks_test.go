@@ -0,0 +1,119 @@
+package upstream
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+
+	"github.com/flanksource/duty/models"
+)
+
+func TestPushDataChunks(t *testing.T) {
+	var msg PushData
+	for i := 0; i < 50; i++ {
+		msg.ConfigItems = append(msg.ConfigItems, models.ConfigItem{ID: uuid.New(), Name: lo.ToPtr(fmt.Sprintf("config-%d", i))})
+		msg.ConfigChanges = append(msg.ConfigChanges, models.ConfigChange{ID: uuid.NewString(), ConfigID: msg.ConfigItems[i].ID.String()})
+	}
+	msg.GeneratedViews = map[string][]models.GeneratedViewTable{
+		"view_b": {{ViewTableName: "view_b", Row: map[string]any{"id": 1}}},
+		"view_a": {{ViewTableName: "view_a", Row: map[string]any{"id": 2}}},
+	}
+
+	size, err := msg.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unchunked, err := msg.Chunks(size)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(unchunked) != 1 {
+		t.Fatalf("expected a single chunk when the data fits, got %d", len(unchunked))
+	}
+
+	maxSize := size / 5
+	chunks, err := msg.Chunks(maxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 5 {
+		t.Fatalf("expected at least 5 chunks, got %d", len(chunks))
+	}
+
+	var merged PushData
+	for i, chunk := range chunks {
+		if chunkSize, _ := chunk.Size(); chunkSize > maxSize {
+			t.Errorf("chunk %d is %d bytes, larger than %d", i, chunkSize, maxSize)
+		}
+		merged.ConfigItems = append(merged.ConfigItems, chunk.ConfigItems...)
+		merged.ConfigChanges = append(merged.ConfigChanges, chunk.ConfigChanges...)
+		merged.GeneratedViews = lo.Assign(merged.GeneratedViews, chunk.GeneratedViews)
+
+		// Config items are saved before the changes that reference them
+		if len(chunk.ConfigItems) > 0 && len(merged.ConfigChanges) > len(chunk.ConfigChanges) {
+			t.Errorf("chunk %d has config items after config changes", i)
+		}
+	}
+
+	if len(merged.ConfigItems) != len(msg.ConfigItems) || len(merged.ConfigChanges) != len(msg.ConfigChanges) || len(merged.GeneratedViews) != 2 {
+		t.Errorf("expected chunks to have all the items, got %s", merged.String())
+	}
+	for i := range msg.ConfigItems {
+		if merged.ConfigItems[i].ID != msg.ConfigItems[i].ID {
+			t.Fatalf("expected chunks to keep the order of the items")
+		}
+	}
+}
+
+func TestPushBodyCompression(t *testing.T) {
+	body := bytes.Repeat([]byte(`{"config_items":[{"id":"1"}]}`), 100)
+
+	for _, encoding := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(encoding, func(t *testing.T) {
+			compressed, err := compressPushBody(encoding, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encoding != CompressionNone && len(compressed) >= len(body) {
+				t.Errorf("expected %s to compress the body, got %d bytes from %d", encoding, len(compressed), len(body))
+			}
+
+			header := lo.Ternary(encoding == CompressionNone, "", encoding)
+			reader, err := decompressPushBody(header, bytes.NewReader(compressed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+
+			decompressed, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decompressed, body) {
+				t.Errorf("expected the decompressed body to match the original")
+			}
+		})
+	}
+
+	if _, err := compressPushBody("brotli", body); err == nil {
+		t.Error("expected an error for an unsupported compression")
+	}
+}
+
+func TestPushIdempotencyKey(t *testing.T) {
+	chunk := []byte(`{"config_items":[{"id":"1"}]}`)
+	if pushIdempotencyKey(http.MethodPost, chunk) != pushIdempotencyKey(http.MethodPost, []byte(`{"config_items":[{"id":"1"}]}`)) {
+		t.Error("expected a retried chunk to have the same key")
+	}
+	if pushIdempotencyKey(http.MethodPost, chunk) == pushIdempotencyKey(http.MethodPost, []byte(`{"config_items":[{"id":"2"}]}`)) {
+		t.Error("expected chunks with other contents to have different keys")
+	}
+	if pushIdempotencyKey(http.MethodPost, chunk) == pushIdempotencyKey(http.MethodDelete, chunk) {
+		t.Error("expected a delete of the same contents to have a different key")
+	}
+}
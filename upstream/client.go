@@ -8,8 +8,8 @@ import (
 	"time"
 
 	"github.com/flanksource/commons/http"
-	"github.com/flanksource/commons/logger"
 	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
 	gocache "github.com/patrickmn/go-cache"
 
 	"github.com/flanksource/duty/api"
@@ -46,8 +46,8 @@ func NewUpstreamClient(config UpstreamConfig) *UpstreamClient {
 	return &client
 }
 
-// PushArtifacts uploads the given artifact to the upstream server.
-func (t *UpstreamClient) PushArtifacts(ctx context.Context, artifactID uuid.UUID, reader io.ReadCloser) error {
+// sendArtifact uploads the given artifact to the upstream server.
+func (t *UpstreamClient) sendArtifact(ctx context.Context, artifactID uuid.UUID, reader io.ReadCloser) error {
 	resp, err := t.R(ctx).Post(fmt.Sprintf("artifacts/%s", artifactID), reader)
 	if err != nil {
 		return fmt.Errorf("error pushing to upstream: %w", err)
@@ -81,33 +81,83 @@ func (t *UpstreamClient) Push(ctx context.Context, msg *PushData) error {
 	return t.push(ctx, netHTTP.MethodPost, msg)
 }
 
-// Delete performs hard delete on the given items from the upstream server.
-func (t *UpstreamClient) Delete(ctx context.Context, msg *PushData) error {
+// sendDelete performs hard delete on the given items from the upstream server.
+func (t *UpstreamClient) sendDelete(ctx context.Context, msg *PushData) error {
 	return t.push(ctx, netHTTP.MethodDelete, msg)
 }
 
+// push sends the push message in chunks of a bounded size.
+// Chunks that fail with foreign key errors don't stop the remaining chunks,
+// and their IDs are returned together in a single error.
 func (t *UpstreamClient) push(ctx context.Context, method string, msg *PushData) error {
 	if msg.Count() == 0 {
 		return nil
 	}
 
+	chunks, err := msg.Chunks(ctx.Properties().Int("upstream.push.chunk_size", defaultPushChunkSize))
+	if err != nil {
+		return err
+	}
+
+	var fkError PushFKError
+	for _, chunk := range chunks {
+		err := t.pushChunk(ctx, method, chunk)
+		if err == nil {
+			continue
+		}
+
+		var chunkFKError PushFKError
+		if httpError := api.HTTPErrorFromErr(err); httpError != nil && httpError.Data != "" &&
+			json.Unmarshal([]byte(httpError.Data), &chunkFKError) == nil && !chunkFKError.Empty() {
+			fkError.IDs = append(fkError.IDs, chunkFKError.IDs...)
+			continue
+		}
+		return err
+	}
+
+	if !fkError.Empty() {
+		data, err := json.Marshal(fkError)
+		if err != nil {
+			return err
+		}
+		return &api.HTTPError{Err: ForeignKeyError, Data: string(data)}
+	}
+	return nil
+}
+
+// pushChunk sends a chunk of a push, keyed by its contents so that the upstream skips it
+// when it is retried, e.g. on a timeout after the upstream saved it or by the next reconcile
+// after a later chunk failed.
+func (t *UpstreamClient) pushChunk(ctx context.Context, method string, msg *PushData) error {
 	start := time.Now()
 	msg.AddMetrics(ctx.Counter("push_queue_records", "method", method, "agent", t.AgentName, "table", ""))
 	histogram := ctx.Histogram("push_queue_batch", context.LatencyBuckets, "method", method, "agent", t.AgentName, StatusLabel, "")
-	req := t.R(ctx).QueryParam(AgentNameQueryParam, t.AgentName)
-	if err := req.Body(msg); err != nil {
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("error encoding push data: %w", err)
+	}
+
+	encoding := ctx.Properties().String("upstream.push.compression", CompressionNone)
+	compressed, err := compressPushBody(encoding, body)
+	if err != nil {
+		return fmt.Errorf("error compressing push data: %w", err)
+	}
+
+	req := t.R(ctx).QueryParam(AgentNameQueryParam, t.AgentName).
+		Header(IdempotencyKeyHeader, pushIdempotencyKey(method, body)).
+		Retry(3, time.Second, 2)
+	if encoding != CompressionNone {
+		req = req.Header(echo.HeaderContentEncoding, encoding)
+	}
+	if err := req.Body(compressed); err != nil {
 		return fmt.Errorf("error setting body: %w", err)
 	}
 
 	resp, err := req.Do(method, "push")
 	if err != nil {
 		histogram.Label(StatusLabel, StatusError).Since(start)
-		msgSize, msgSizeErr := msg.Size()
-		if msgSizeErr != nil {
-			logger.Errorf("failed to get msgsize: %w", msgSizeErr)
-		}
-
-		return fmt.Errorf("error pushing to upstream (msg_size: %d bytes): %w", msgSize, err)
+		return fmt.Errorf("error pushing to upstream (msg_size: %d bytes, compressed: %d bytes): %w", len(body), len(compressed), err)
 	}
 	defer resp.Body.Close()
 
@@ -125,6 +175,31 @@ func (t *UpstreamClient) push(ctx context.Context, method string, msg *PushData)
 	return nil
 }
 
+// Pull fetches the centrally managed objects modified on the upstream after the given cursors.
+func (t *UpstreamClient) Pull(ctx context.Context, pullReq PullRequest) (*PullData, error) {
+	req := t.R(ctx).QueryParam(AgentNameQueryParam, t.AgentName)
+	if err := req.Body(pullReq); err != nil {
+		return nil, fmt.Errorf("error setting request body: %w", err)
+	}
+
+	resp, err := req.Do(netHTTP.MethodPost, "pull")
+	if err != nil {
+		return nil, fmt.Errorf("error pulling from upstream: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if !resp.IsOK() {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("upstream server returned error status[%d]: %s", resp.StatusCode, parseResponse(string(respBody)))
+	}
+
+	var result PullData
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	return &result, nil
+}
+
 // ListViews returns all views from upstream with namespace,name pairs
 func (t *UpstreamClient) ListViews(ctx context.Context, views []ViewIdentifier) ([]ViewWithColumns, error) {
 	cacheKey := "columns-list"
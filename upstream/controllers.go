@@ -24,6 +24,7 @@ const (
 	StatusAgentError = "agent-error"
 	StatusError      = "error"
 	StatusOK         = "ok"
+	StatusDuplicate  = "duplicate"
 	StatusLabel      = "status"
 	AgentLabel       = "agent"
 
@@ -82,17 +83,30 @@ func NewPushHandler(ringManager StatusRingManager) echo.HandlerFunc {
 			histogram.Since(start)
 		}()
 
+		agentID := ctx.Agent().ID
+		histogram = histogram.Label(AgentLabel, agentID.String())
+
+		// Chunks retried by the agent after the upstream saved them are skipped.
+		// Legacy agents send no idempotency key.
+		idempotencyKey := c.Request().Header.Get(IdempotencyKeyHeader)
+		if idempotencyKey != "" {
+			if saved, err := isPushChunkSaved(ctx, agentID, idempotencyKey); err != nil {
+				histogram.Label(StatusLabel, StatusError)
+				return api.WriteError(c, err)
+			} else if saved {
+				histogram.Label(StatusLabel, StatusDuplicate)
+				return nil
+			}
+		}
+
 		var req PushData
-		err := json.NewDecoder(c.Request().Body).Decode(&req)
-		if err != nil {
+		if err := decodePushData(c, &req); err != nil {
 			histogram.Label(StatusLabel, StatusAgentError)
 			return c.JSON(http.StatusBadRequest, api.HTTPError{Err: err.Error(), Message: "invalid json request"})
 		}
 
 		ctx.GetSpan().SetAttributes(attribute.Int("count", req.Count()))
 
-		agentID := ctx.Agent().ID
-		histogram = histogram.Label(AgentLabel, agentID.String())
 		req.PopulateAgentID(agentID)
 		req.AddAgentConfig(lo.FromPtr(ctx.Agent()))
 
@@ -105,6 +119,12 @@ func NewPushHandler(ringManager StatusRingManager) echo.HandlerFunc {
 			return api.WriteError(c, err)
 		}
 
+		if idempotencyKey != "" {
+			if err := savePushChunk(ctx, agentID, idempotencyKey); err != nil {
+				logger.Errorf("failed to save push chunk: %v", err)
+			}
+		}
+
 		addJobHistoryToRing(ctx, agentID.String(), req.JobHistory, ringManager)
 
 		histogram.Label(StatusLabel, StatusOK)
@@ -118,6 +138,17 @@ func NewPushHandler(ringManager StatusRingManager) echo.HandlerFunc {
 	}
 }
 
+// decodePushData decodes push data sent either uncompressed or compressed with gzip or zstd.
+func decodePushData(c echo.Context, req *PushData) error {
+	body, err := decompressPushBody(c.Request().Header.Get(echo.HeaderContentEncoding), c.Request().Body)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return json.NewDecoder(body).Decode(req)
+}
+
 func addJobHistoryToRing(ctx context.Context, agentID string, histories []models.JobHistory, ringManager StatusRingManager) {
 	if ringManager == nil {
 		return
@@ -134,7 +165,7 @@ func DeleteHandler(c echo.Context) error {
 	ctx := c.Request().Context().(context.Context)
 	start := time.Now()
 	var req PushData
-	err := json.NewDecoder(c.Request().Body).Decode(&req)
+	err := decodePushData(c, &req)
 	histogram := ctx.Histogram("push_queue_delete_handler", context.LatencyBuckets, StatusLabel, "", AgentLabel, "")
 	if err != nil {
 		histogram.Label(StatusLabel, StatusAgentError).Since(start)
@@ -221,3 +252,21 @@ func ListViewsHandler(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, result)
 }
+
+// PullHandler returns the centrally managed objects that agents pull down.
+func PullHandler(c echo.Context) error {
+	ctx := c.Request().Context().(context.Context)
+
+	var req PullRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return api.WriteError(c, api.Errorf(api.EINVALID, "invalid json request: %v", err))
+	}
+
+	data, err := GetPullData(ctx, req)
+	if err != nil {
+		return api.WriteError(c, err)
+	}
+
+	ctx.GetSpan().SetAttributes(attribute.Int("count", data.Count()))
+	return c.JSON(http.StatusOK, data)
+}
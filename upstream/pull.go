@@ -0,0 +1,426 @@
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/flanksource/duty/api"
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/models"
+)
+
+const (
+	// PullConflictUpstreamWins overwrites local edits with the upstream object
+	PullConflictUpstreamWins = "upstream"
+
+	// PullConflictAgentWins keeps local edits until they are reverted on the agent
+	PullConflictAgentWins = "agent"
+
+	// PullConflictNewestWins keeps whichever side was modified last
+	PullConflictNewestWins = "newest"
+)
+
+// pullModifiedAtExpr is when an object was last modified, deletions included,
+// as soft deletes do not bump updated_at.
+const pullModifiedAtExpr = "COALESCE(GREATEST(updated_at, deleted_at), created_at)"
+
+// PullCursor is the position in a table up to which objects have been pulled.
+// Objects are pulled in the order of their modification time and key.
+type PullCursor struct {
+	ModifiedAt time.Time `json:"modified_at"`
+	ID         string    `json:"id"`
+}
+
+type PullRequest struct {
+	// Cursors of each table. Tables without a cursor are pulled from the start.
+	Cursors map[string]PullCursor `json:"cursors,omitempty"`
+	Limit   int                   `json:"limit,omitempty"`
+}
+
+// PullData consists of centrally managed objects
+// that agents pull down from the upstream.
+type PullData struct {
+	NotificationSilences []models.NotificationSilence `json:"notification_silences,omitempty"`
+	Permissions          []models.Permission          `json:"permissions,omitempty"`
+	ScrapePlugins        []models.ScrapePlugin        `json:"scrape_plugins,omitempty"`
+	Properties           []models.AppProperty         `json:"properties,omitempty"`
+
+	// Cursors to send with the next pull
+	Cursors map[string]PullCursor `json:"cursors,omitempty"`
+}
+
+func (t PullData) Count() int {
+	return len(t.NotificationSilences) + len(t.Permissions) + len(t.ScrapePlugins) + len(t.Properties)
+}
+
+type PullTableSummary struct {
+	Applied   int      `json:"applied,omitempty"`
+	Conflicts []string `json:"conflicts,omitempty"`
+	Failed    []string `json:"failed,omitempty"`
+}
+
+type PullSummary map[string]PullTableSummary
+
+func (t PullSummary) add(table, id, status string) {
+	v := t[table]
+	switch status {
+	case models.UpstreamPullStatusApplied:
+		v.Applied++
+	case models.UpstreamPullStatusConflict:
+		v.Conflicts = append(v.Conflicts, id)
+	case models.UpstreamPullStatusFailed:
+		v.Failed = append(v.Failed, id)
+	}
+	t[table] = v
+}
+
+type pullTable[T any] struct {
+	name string
+
+	// key is the primary key column
+	key string
+
+	keyOf      func(T) string
+	modifiedAt func(T) time.Time
+	upsert     func(db *gorm.DB, item T) error
+
+	// scope restricts the objects pulled by an agent. All objects are pulled when nil.
+	scope func(ctx context.Context, db *gorm.DB, agentID uuid.UUID) *gorm.DB
+}
+
+// agentResourcesScope only pulls the objects that refer to resources of the agent,
+// or to no resource at all. columns maps the resource columns to the tables of the resources.
+func agentResourcesScope(columns map[string]string) func(context.Context, *gorm.DB, uuid.UUID) *gorm.DB {
+	return func(_ context.Context, db *gorm.DB, agentID uuid.UUID) *gorm.DB {
+		for _, column := range slices.Sorted(maps.Keys(columns)) {
+			db = db.Where(fmt.Sprintf("%s IS NULL OR %s IN (SELECT id FROM %s WHERE agent_id = ?)", column, column, columns[column]), agentID)
+		}
+		return db
+	}
+}
+
+// pullReservedPropertyPrefixes are properties that configure the agent itself
+// and are never pulled from the upstream.
+var pullReservedPropertyPrefixes = []string{"upstream.", "artifacts."}
+
+func isReservedPullProperty(name string) bool {
+	return lo.SomeBy(pullReservedPropertyPrefixes, func(prefix string) bool { return strings.HasPrefix(name, prefix) })
+}
+
+func pullModifiedAt(createdAt time.Time, updatedAt, deletedAt *time.Time) time.Time {
+	modified := createdAt
+	if updatedAt != nil {
+		modified = *updatedAt
+	}
+	if deletedAt != nil && deletedAt.After(modified) {
+		modified = *deletedAt
+	}
+	return modified
+}
+
+var pullNotificationSilences = pullTable[models.NotificationSilence]{
+	name:  "notification_silences",
+	key:   "id",
+	keyOf: func(s models.NotificationSilence) string { return s.ID.String() },
+	modifiedAt: func(s models.NotificationSilence) time.Time {
+		return pullModifiedAt(s.CreatedAt, &s.UpdatedAt, s.DeletedAt)
+	},
+	upsert: func(db *gorm.DB, s models.NotificationSilence) error {
+		return db.Clauses(clause.OnConflict{UpdateAll: true}).Omit("created_by").Create(&s).Error
+	},
+	scope: agentResourcesScope(map[string]string{
+		"config_id":    "config_items",
+		"component_id": "components",
+		"canary_id":    "canaries",
+		"check_id":     "checks",
+	}),
+}
+
+var pullPermissions = pullTable[models.Permission]{
+	name:  "permissions",
+	key:   "id",
+	keyOf: func(p models.Permission) string { return p.ID.String() },
+	modifiedAt: func(p models.Permission) time.Time {
+		return pullModifiedAt(p.CreatedAt, &p.UpdatedAt, p.DeletedAt)
+	},
+	upsert: func(db *gorm.DB, p models.Permission) error {
+		return db.Clauses(clause.OnConflict{UpdateAll: true}).Omit("created_by", "updated_by").Create(&p).Error
+	},
+	scope: agentResourcesScope(map[string]string{
+		"config_id":    "config_items",
+		"component_id": "components",
+		"canary_id":    "canaries",
+	}),
+}
+
+var pullScrapePlugins = pullTable[models.ScrapePlugin]{
+	name:  "scrape_plugins",
+	key:   "id",
+	keyOf: func(p models.ScrapePlugin) string { return p.ID.String() },
+	modifiedAt: func(p models.ScrapePlugin) time.Time {
+		return pullModifiedAt(p.CreatedAt, p.UpdatedAt, p.DeletedAt)
+	},
+	upsert: func(db *gorm.DB, p models.ScrapePlugin) error {
+		return db.Clauses(clause.OnConflict{UpdateAll: true}).Omit("created_by").Create(&p).Error
+	},
+}
+
+var pullProperties = pullTable[models.AppProperty]{
+	name:  "properties",
+	key:   "name",
+	keyOf: func(p models.AppProperty) string { return p.Name },
+	modifiedAt: func(p models.AppProperty) time.Time {
+		return pullModifiedAt(p.CreatedAt, &p.UpdatedAt, p.DeletedAt)
+	},
+	upsert: func(db *gorm.DB, p models.AppProperty) error {
+		if isReservedPullProperty(p.Name) {
+			return fmt.Errorf("property %s cannot be pulled from the upstream", p.Name)
+		}
+
+		// The model defaults deleted_at to the current time, so the columns are written explicitly
+		return db.Exec(`INSERT INTO properties (name, value, deleted_at) VALUES (?, ?, ?)
+			ON CONFLICT (name) DO UPDATE SET value = excluded.value, deleted_at = excluded.deleted_at`,
+			p.Name, p.Value, p.DeletedAt).Error
+	},
+	// Only the properties with a prefix in upstream.pull.properties are pulled
+	scope: func(ctx context.Context, db *gorm.DB, _ uuid.UUID) *gorm.DB {
+		prefixes := lo.Compact(lo.Map(strings.Split(ctx.Properties().String("upstream.pull.properties", ""), ","), func(prefix string, _ int) string {
+			return strings.TrimSpace(prefix)
+		}))
+		if len(prefixes) == 0 {
+			return db.Where("FALSE")
+		}
+
+		for _, reserved := range pullReservedPropertyPrefixes {
+			db = db.Where("NOT starts_with(name, ?)", reserved)
+		}
+		return db.Where("EXISTS (SELECT 1 FROM unnest(?::text[]) AS prefix WHERE starts_with(name, prefix))", pq.StringArray(prefixes))
+	},
+}
+
+// fetch returns up to limit objects of the agent modified after the cursor, and the cursor after them.
+func (t pullTable[T]) fetch(ctx context.Context, agentID uuid.UUID, cursor PullCursor, limit int) ([]T, PullCursor, error) {
+	var items []T
+	query := ctx.DB().Table(t.name)
+	if t.scope != nil {
+		query = t.scope(ctx, query, agentID)
+	}
+	err := query.
+		Where(fmt.Sprintf(`(%s, %s::text COLLATE "C") > (?, ?)`, pullModifiedAtExpr, t.key), cursor.ModifiedAt, cursor.ID).
+		Order(fmt.Sprintf(`%s, %s::text COLLATE "C"`, pullModifiedAtExpr, t.key)).
+		Limit(limit).
+		Find(&items).Error
+	if err != nil {
+		return nil, cursor, fmt.Errorf("error fetching %s: %w", t.name, err)
+	}
+
+	if len(items) > 0 {
+		last := items[len(items)-1]
+		cursor = PullCursor{ModifiedAt: t.modifiedAt(last), ID: t.keyOf(last)}
+	}
+	return items, cursor, nil
+}
+
+// GetPullData returns the objects of the agent in the context modified after the cursors of the request.
+func GetPullData(ctx context.Context, req PullRequest) (*PullData, error) {
+	agent := ctx.Agent()
+	if agent == nil {
+		return nil, api.Errorf(api.EINVALID, "agent is required to pull")
+	}
+
+	limit := ctx.Properties().Int("upstream.pull.batch_size", 500)
+	if req.Limit > 0 && req.Limit < limit {
+		limit = req.Limit
+	}
+
+	data := &PullData{Cursors: map[string]PullCursor{}}
+	var err error
+	if data.NotificationSilences, data.Cursors[pullNotificationSilences.name], err = pullNotificationSilences.fetch(ctx, agent.ID, req.Cursors[pullNotificationSilences.name], limit); err != nil {
+		return nil, err
+	}
+	if data.Permissions, data.Cursors[pullPermissions.name], err = pullPermissions.fetch(ctx, agent.ID, req.Cursors[pullPermissions.name], limit); err != nil {
+		return nil, err
+	}
+	if data.ScrapePlugins, data.Cursors[pullScrapePlugins.name], err = pullScrapePlugins.fetch(ctx, agent.ID, req.Cursors[pullScrapePlugins.name], limit); err != nil {
+		return nil, err
+	}
+	if data.Properties, data.Cursors[pullProperties.name], err = pullProperties.fetch(ctx, agent.ID, req.Cursors[pullProperties.name], limit); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// upstreamWins resolves an object that was edited both locally and on the upstream.
+func upstreamWins(policy string, localModifiedAt, upstreamModifiedAt time.Time) bool {
+	switch policy {
+	case PullConflictAgentWins:
+		return false
+	case PullConflictNewestWins:
+		return !localModifiedAt.After(upstreamModifiedAt)
+	default:
+		return true
+	}
+}
+
+// apply upserts the pulled objects, one transaction per object,
+// and records the version of each object that was pulled.
+func (t pullTable[T]) apply(ctx context.Context, items []T, policy string, summary PullSummary) error {
+	for _, item := range items {
+		id := t.keyOf(item)
+		record := models.UpstreamPulledObject{Table: t.name, ID: id, UpstreamModifiedAt: t.modifiedAt(item)}
+
+		err := ctx.DB().Transaction(func(tx *gorm.DB) error {
+			var previous []models.UpstreamPulledObject
+			if err := tx.Where("table_name = ? AND id = ?", t.name, id).Find(&previous).Error; err != nil {
+				return err
+			}
+
+			localModifiedAt, err := t.localModifiedAt(tx, id)
+			if err != nil {
+				return err
+			}
+
+			// The local copy was edited on the agent when it exists but was never written by a pull,
+			// or was modified after the last pull wrote it.
+			edited := localModifiedAt != nil && (len(previous) == 0 || previous[0].LocalModifiedAt == nil || localModifiedAt.After(*previous[0].LocalModifiedAt))
+			if edited && !upstreamWins(policy, *localModifiedAt, record.UpstreamModifiedAt) {
+				record.Status = models.UpstreamPullStatusConflict
+				if len(previous) > 0 {
+					record.LocalModifiedAt = previous[0].LocalModifiedAt
+				}
+				return saveUpstreamPulledObject(tx, record)
+			}
+
+			if err := tx.Transaction(func(tx *gorm.DB) error { return t.upsert(tx, item) }); err != nil {
+				record.Status = models.UpstreamPullStatusFailed
+				record.Error = lo.ToPtr(err.Error())
+				record.Attempts = 1
+				if len(previous) > 0 && previous[0].Status == models.UpstreamPullStatusFailed && previous[0].UpstreamModifiedAt.Equal(record.UpstreamModifiedAt) {
+					record.Attempts += previous[0].Attempts
+				}
+				return saveUpstreamPulledObject(tx, record)
+			}
+
+			if record.LocalModifiedAt, err = t.localModifiedAt(tx, id); err != nil {
+				return err
+			}
+			record.Status = models.UpstreamPullStatusApplied
+			return saveUpstreamPulledObject(tx, record)
+		})
+		if err != nil {
+			return fmt.Errorf("error applying %s (id=%s): %w", t.name, id, err)
+		}
+
+		if record.Status == models.UpstreamPullStatusFailed {
+			ctx.Warnf("failed to apply pulled %s (id=%s, attempt=%d): %s", t.name, id, record.Attempts, lo.FromPtr(record.Error))
+		}
+		summary.add(t.name, id, record.Status)
+	}
+
+	return nil
+}
+
+func (t pullTable[T]) localModifiedAt(db *gorm.DB, id string) (*time.Time, error) {
+	var modified []time.Time
+	if err := db.Table(t.name).Select(pullModifiedAtExpr).Where(fmt.Sprintf("%s::text = ?", t.key), id).Scan(&modified).Error; err != nil {
+		return nil, err
+	}
+	if len(modified) == 0 {
+		return nil, nil
+	}
+	return &modified[0], nil
+}
+
+func saveUpstreamPulledObject(db *gorm.DB, record models.UpstreamPulledObject) error {
+	record.PulledAt = time.Now()
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&record).Error
+}
+
+// ApplyPullData upserts the objects pulled from the upstream on the agent.
+//
+// Objects edited both locally and on the upstream are resolved
+// with the upstream.pull.conflict property: upstream (default), agent or newest.
+func ApplyPullData(ctx context.Context, data *PullData, summary PullSummary) error {
+	policy := ctx.Properties().String("upstream.pull.conflict", PullConflictUpstreamWins)
+	if !lo.Contains([]string{PullConflictUpstreamWins, PullConflictAgentWins, PullConflictNewestWins}, policy) {
+		return api.Errorf(api.EINVALID, "unsupported upstream.pull.conflict %q", policy)
+	}
+	if len(data.Properties) > 0 {
+		defer ctx.ClearCache()
+	}
+
+	return errors.Join(
+		pullNotificationSilences.apply(ctx, data.NotificationSilences, policy, summary),
+		pullPermissions.apply(ctx, data.Permissions, policy, summary),
+		pullScrapePlugins.apply(ctx, data.ScrapePlugins, policy, summary),
+		pullProperties.apply(ctx, data.Properties, policy, summary),
+	)
+}
+
+// GetPullCursors returns the position up to which each table has been pulled.
+// The cursor stops before the first object that failed to apply so that it is pulled again,
+// until it failed upstream.pull.max_attempts times, e.g. a permission of a playbook that only exists on the upstream.
+func GetPullCursors(ctx context.Context) (map[string]PullCursor, error) {
+	maxAttempts := ctx.Properties().Int("upstream.pull.max_attempts", 5)
+
+	var records []models.UpstreamPulledObject
+	if err := ctx.DB().Raw(`SELECT DISTINCT ON (table_name) * FROM upstream_pulled_objects p
+		WHERE (status != ? OR attempts >= ?) AND NOT EXISTS (
+			SELECT 1 FROM upstream_pulled_objects f
+			WHERE f.table_name = p.table_name AND f.status = ? AND f.attempts < ?
+				AND (f.upstream_modified_at, f.id COLLATE "C") <= (p.upstream_modified_at, p.id COLLATE "C")
+		)
+		ORDER BY table_name, upstream_modified_at DESC, id COLLATE "C" DESC`,
+		models.UpstreamPullStatusFailed, maxAttempts, models.UpstreamPullStatusFailed, maxAttempts).Scan(&records).Error; err != nil {
+		return nil, fmt.Errorf("error fetching pull cursors: %w", err)
+	}
+
+	cursors := make(map[string]PullCursor, len(records))
+	for _, r := range records {
+		cursors[r.Table] = PullCursor{ModifiedAt: r.UpstreamModifiedAt, ID: r.ID}
+	}
+	return cursors, nil
+}
+
+// PullAll pulls down the objects modified on the upstream since the last pull.
+// Objects that fail to apply are pulled again by the next runs, up to upstream.pull.max_attempts times.
+func PullAll(ctx context.Context, client *UpstreamClient, batchSize int) (PullSummary, error) {
+	summary := PullSummary{}
+	var previous map[string]PullCursor
+	for {
+		cursors, err := GetPullCursors(ctx)
+		if err != nil {
+			return summary, err
+		}
+		if previous != nil && maps.EqualFunc(previous, cursors, func(a, b PullCursor) bool {
+			return a.ID == b.ID && a.ModifiedAt.Equal(b.ModifiedAt)
+		}) {
+			// Only failed objects were pulled
+			return summary, nil
+		}
+		previous = cursors
+
+		data, err := client.Pull(ctx, PullRequest{Cursors: cursors, Limit: batchSize})
+		if err != nil {
+			return summary, err
+		}
+		if data.Count() == 0 {
+			return summary, nil
+		}
+
+		if err := ApplyPullData(ctx, data, summary); err != nil {
+			return summary, err
+		}
+	}
+}
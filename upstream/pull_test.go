@@ -0,0 +1,57 @@
+package upstream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPullModifiedAt(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	deleted := created.Add(2 * time.Hour)
+
+	tests := []struct {
+		name      string
+		updatedAt *time.Time
+		deletedAt *time.Time
+		expected  time.Time
+	}{
+		{name: "created", expected: created},
+		{name: "updated", updatedAt: &updated, expected: updated},
+		{name: "deleted after update", updatedAt: &updated, deletedAt: &deleted, expected: deleted},
+		{name: "deleted before update", updatedAt: &deleted, deletedAt: &updated, expected: deleted},
+		{name: "deleted without update", deletedAt: &deleted, expected: deleted},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := pullModifiedAt(created, tc.updatedAt, tc.deletedAt); !got.Equal(tc.expected) {
+				t.Errorf("expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestUpstreamWins(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Minute)
+
+	tests := []struct {
+		policy   string
+		local    time.Time
+		upstream time.Time
+		expected bool
+	}{
+		{policy: PullConflictUpstreamWins, local: newer, upstream: older, expected: true},
+		{policy: PullConflictAgentWins, local: older, upstream: newer, expected: false},
+		{policy: PullConflictNewestWins, local: older, upstream: newer, expected: true},
+		{policy: PullConflictNewestWins, local: newer, upstream: older, expected: false},
+		{policy: PullConflictNewestWins, local: older, upstream: older, expected: true},
+	}
+
+	for _, tc := range tests {
+		if got := upstreamWins(tc.policy, tc.local, tc.upstream); got != tc.expected {
+			t.Errorf("upstreamWins(%s, local=%s, upstream=%s) = %v, expected %v", tc.policy, tc.local, tc.upstream, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package upstream
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+
+	"github.com/flanksource/duty/context"
+	"github.com/flanksource/duty/job"
+	"github.com/flanksource/duty/models"
+)
+
+const (
+	defaultSpoolMaxSize     = 100 * 1024 * 1024
+	defaultSpoolMaxEntries  = 10000
+	defaultSpoolMaxAttempts = 10
+	spoolDrainBatchSize     = 10
+)
+
+// SpoolDelete queues a delete to be sent to the upstream by DrainSpool.
+func SpoolDelete(ctx context.Context, msg *PushData) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("error encoding push data: %w", err)
+	}
+	return spool(ctx, models.UpstreamSpoolEntry{Kind: models.UpstreamSpoolKindDelete, Payload: payload})
+}
+
+// SpoolArtifact queues an artifact upload to be sent to the upstream by DrainSpool.
+func SpoolArtifact(ctx context.Context, artifactID uuid.UUID, content []byte) error {
+	return spool(ctx, models.UpstreamSpoolEntry{Kind: models.UpstreamSpoolKindArtifact, ArtifactID: &artifactID, Payload: content})
+}
+
+func spool(ctx context.Context, entry models.UpstreamSpoolEntry) error {
+	entry.Size = int64(len(entry.Payload))
+	if maxSize := ctx.Properties().Int("upstream.spool.max_size", defaultSpoolMaxSize); maxSize > 0 && entry.Size > int64(maxSize) {
+		ctx.Counter("upstream_spool_rejected", "kind", entry.Kind).Add(1)
+		return fmt.Errorf("spooled %s of %d bytes exceeds upstream.spool.max_size of %d bytes", entry.Kind, entry.Size, maxSize)
+	}
+
+	if err := ctx.DB().Create(&entry).Error; err != nil {
+		return fmt.Errorf("error spooling %s: %w", entry.Kind, err)
+	}
+	ctx.Counter("upstream_spool_enqueued", "kind", entry.Kind).Add(1)
+
+	if _, err := evictSpool(ctx); err != nil {
+		return err
+	}
+	return updateSpoolMetrics(ctx)
+}
+
+// evictSpool deletes the oldest entries once the spool exceeds
+// upstream.spool.max_size bytes or upstream.spool.max_entries entries.
+func evictSpool(ctx context.Context) (int, error) {
+	maxSize := ctx.Properties().Int("upstream.spool.max_size", defaultSpoolMaxSize)
+	maxEntries := ctx.Properties().Int("upstream.spool.max_entries", defaultSpoolMaxEntries)
+
+	tx := ctx.DB().Exec(`DELETE FROM upstream_spool WHERE id IN (
+		SELECT id FROM (
+			SELECT id, SUM(size) OVER (ORDER BY id DESC) AS total, ROW_NUMBER() OVER (ORDER BY id DESC) AS n FROM upstream_spool
+		) AS s WHERE total > ? OR n > ?
+	)`, maxSize, maxEntries)
+	if tx.Error != nil {
+		return 0, fmt.Errorf("error evicting spool: %w", tx.Error)
+	}
+
+	if tx.RowsAffected > 0 {
+		ctx.Warnf("upstream spool is full, evicted %d oldest entries", tx.RowsAffected)
+		ctx.Counter("upstream_spool_evicted", "reason", "full").Add(int(tx.RowsAffected))
+	}
+	return int(tx.RowsAffected), nil
+}
+
+func updateSpoolMetrics(ctx context.Context) error {
+	var stats struct {
+		Entries int
+		Bytes   int64
+		Oldest  *time.Time
+	}
+	if err := ctx.DB().Raw("SELECT COUNT(*) AS entries, COALESCE(SUM(size), 0) AS bytes, MIN(created_at) AS oldest FROM upstream_spool").Scan(&stats).Error; err != nil {
+		return fmt.Errorf("error fetching spool stats: %w", err)
+	}
+
+	ctx.Gauge("upstream_spool_entries").Set(float64(stats.Entries))
+	ctx.Gauge("upstream_spool_bytes").Set(float64(stats.Bytes))
+	ctx.Gauge("upstream_spool_oldest_seconds").Set(lo.TernaryF(stats.Oldest == nil,
+		func() float64 { return 0 },
+		func() float64 { return time.Since(*stats.Oldest).Seconds() }))
+	return nil
+}
+
+func hasSpooled(ctx context.Context) (bool, error) {
+	var spooled bool
+	err := ctx.DB().Raw("SELECT EXISTS (SELECT 1 FROM upstream_spool)").Scan(&spooled).Error
+	return spooled, err
+}
+
+// Delete performs hard delete on the given items from the upstream server.
+// The delete is spooled, to be sent by DrainSpool, when it fails
+// or when earlier entries are still spooled so that the upstream receives deletes in order.
+func (t *UpstreamClient) Delete(ctx context.Context, msg *PushData) error {
+	if msg.Count() == 0 {
+		return nil
+	}
+
+	if spooled, err := hasSpooled(ctx); err != nil {
+		return err
+	} else if !spooled {
+		err := t.sendDelete(ctx, msg)
+		if err == nil {
+			return nil
+		}
+		ctx.Warnf("spooling delete of %s: %v", msg.String(), err)
+	}
+
+	return SpoolDelete(ctx, msg)
+}
+
+// spoolBuffer keeps what an upload read from an artifact that cannot seek, so that it can be
+// spooled if the upload fails. It stops buffering once the content exceeds the size of the spool.
+type spoolBuffer struct {
+	bytes.Buffer
+	maxSize  int
+	overflow bool
+}
+
+func (b *spoolBuffer) Write(p []byte) (int, error) {
+	if b.overflow {
+		return len(p), nil
+	}
+	if b.maxSize > 0 && b.Len()+len(p) > b.maxSize {
+		b.overflow = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// PushArtifacts uploads the given artifact to the upstream server,
+// spooling the upload when it fails or when earlier entries are still spooled.
+//
+// The content is streamed to the upstream. Readers that can seek are rewound to be spooled
+// after a failed upload, the content read from others is kept up to upstream.spool.max_size bytes.
+func (t *UpstreamClient) PushArtifacts(ctx context.Context, artifactID uuid.UUID, reader io.ReadCloser) error {
+	defer reader.Close()
+
+	spooled, err := hasSpooled(ctx)
+	if err != nil {
+		return err
+	}
+
+	maxSize := ctx.Properties().Int("upstream.spool.max_size", defaultSpoolMaxSize)
+	var content io.Reader = reader
+	if !spooled {
+		seeker, seekable := reader.(io.ReadSeeker)
+		buffered := &spoolBuffer{maxSize: maxSize}
+
+		upload := io.Reader(seeker)
+		if !seekable {
+			upload = io.TeeReader(reader, buffered)
+		}
+
+		err := t.sendArtifact(ctx, artifactID, io.NopCloser(upload))
+		if err == nil {
+			return nil
+		}
+		ctx.Warnf("spooling upload of artifact %s: %v", artifactID, err)
+
+		if seekable {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("error rewinding artifact %s: %w", artifactID, err)
+			}
+		} else if buffered.overflow {
+			return rejectSpooledArtifact(ctx, artifactID, maxSize)
+		} else {
+			content = io.MultiReader(&buffered.Buffer, reader)
+		}
+	}
+
+	if maxSize > 0 {
+		content = io.LimitReader(content, int64(maxSize)+1)
+	}
+	buf, err := io.ReadAll(content)
+	if err != nil {
+		return fmt.Errorf("error reading artifact %s: %w", artifactID, err)
+	} else if maxSize > 0 && len(buf) > maxSize {
+		return rejectSpooledArtifact(ctx, artifactID, maxSize)
+	}
+	return SpoolArtifact(ctx, artifactID, buf)
+}
+
+func rejectSpooledArtifact(ctx context.Context, artifactID uuid.UUID, maxSize int) error {
+	ctx.Counter("upstream_spool_rejected", "kind", models.UpstreamSpoolKindArtifact).Add(1)
+	return fmt.Errorf("spooled artifact %s exceeds upstream.spool.max_size of %d bytes", artifactID, maxSize)
+}
+
+// DrainSpoolJob sends the spooled deletes and artifact uploads to the upstream once it is reachable.
+func DrainSpoolJob(client *UpstreamClient) *job.Job {
+	return &job.Job{
+		Name:       "DrainUpstreamSpool",
+		Schedule:   "@every 1m",
+		Singleton:  true,
+		JobHistory: true,
+		Retention:  job.RetentionFew,
+		Fn: func(ctx job.JobRuntime) error {
+			drained, err := DrainSpool(ctx.Context, client)
+			ctx.History.SuccessCount = drained
+			return err
+		},
+	}
+}
+
+func (t *UpstreamClient) sendSpoolEntry(ctx context.Context, entry models.UpstreamSpoolEntry) error {
+	switch entry.Kind {
+	case models.UpstreamSpoolKindDelete:
+		var msg PushData
+		if err := json.Unmarshal(entry.Payload, &msg); err != nil {
+			return fmt.Errorf("error decoding spooled delete: %w", err)
+		}
+		return t.sendDelete(ctx, &msg)
+	case models.UpstreamSpoolKindArtifact:
+		return t.sendArtifact(ctx, lo.FromPtr(entry.ArtifactID), io.NopCloser(bytes.NewReader(entry.Payload)))
+	default:
+		return fmt.Errorf("unknown spool entry kind %q", entry.Kind)
+	}
+}
+
+// DrainSpool sends the spooled entries to the upstream, oldest first.
+// It stops at the first entry that fails so that entries are sent in order.
+// Entries that fail upstream.spool.max_attempts times are dropped.
+//
+// DrainSpool must not run concurrently with itself, see DrainSpoolJob.
+func DrainSpool(ctx context.Context, client *UpstreamClient) (int, error) {
+	if spooled, err := hasSpooled(ctx); err != nil || !spooled {
+		return 0, err
+	}
+
+	if err := client.Ping(ctx); err != nil {
+		return 0, fmt.Errorf("upstream is unreachable: %w", err)
+	}
+
+	maxAttempts := ctx.Properties().Int("upstream.spool.max_attempts", defaultSpoolMaxAttempts)
+
+	var drained int
+	defer func() {
+		if err := updateSpoolMetrics(ctx); err != nil {
+			ctx.Errorf("%v", err)
+		}
+	}()
+
+	for {
+		var entries []models.UpstreamSpoolEntry
+		if err := ctx.DB().Order("id").Limit(spoolDrainBatchSize).Find(&entries).Error; err != nil {
+			return drained, fmt.Errorf("error fetching spool: %w", err)
+		}
+		if len(entries) == 0 {
+			return drained, nil
+		}
+
+		for _, entry := range entries {
+			if sendErr := client.sendSpoolEntry(ctx, entry); sendErr != nil {
+				entry.Attempts++
+				entry.Error = lo.ToPtr(sendErr.Error())
+				entry.LastAttempt = lo.ToPtr(time.Now())
+
+				if maxAttempts > 0 && entry.Attempts >= maxAttempts {
+					ctx.Errorf("dropping spooled %s (id=%d) after %d attempts: %v", entry.Kind, entry.ID, entry.Attempts, sendErr)
+					if err := ctx.DB().Delete(&entry).Error; err != nil {
+						return drained, fmt.Errorf("error deleting spool entry: %w", err)
+					}
+					ctx.Counter("upstream_spool_evicted", "reason", "max_attempts").Add(1)
+					continue
+				}
+
+				if err := ctx.DB().Select("attempts", "error", "last_attempt").Updates(&entry).Error; err != nil {
+					return drained, fmt.Errorf("error updating spool entry: %w", err)
+				}
+				return drained, fmt.Errorf("error sending spooled %s (id=%d): %w", entry.Kind, entry.ID, sendErr)
+			}
+
+			if err := ctx.DB().Delete(&entry).Error; err != nil {
+				return drained, fmt.Errorf("error deleting spool entry: %w", err)
+			}
+			drained++
+			ctx.Counter("upstream_spool_drained", "kind", entry.Kind).Add(1)
+		}
+	}
+}
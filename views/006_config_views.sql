@@ -1,4 +1,4 @@
--- dependsOn: functions/drop.sql, functions/cost_overlap.sql
+-- dependsOn: functions/drop.sql, functions/cost_overlap.sql, functions/config_masks.sql
 
 -- Add cascade drops first to make sure all functions and views are always recreated
 DROP VIEW IF EXISTS configs CASCADE;
@@ -665,6 +665,27 @@ FOR EACH ROW
   EXECUTE FUNCTION insert_config_changes_updates_in_event_queue();
 ---
 
+DROP VIEW IF EXISTS config_items_masked CASCADE;
+
+-- The config items with the config masks of the request applied to their config,
+-- and the paths that were masked as masked_paths.
+-- The columns follow config_items, like ci.*, with the config replaced by the masked config.
+-- Rows are filtered by the RLS policy of config_items as the view is security_invoker (views/9998_rls_enable.sql).
+DO $$
+DECLARE
+  columns text;
+BEGIN
+  SELECT string_agg(CASE WHEN attname = 'config' THEN 'm.masked AS config' ELSE 'ci.' || quote_ident(attname) END, ', ' ORDER BY attnum)
+  INTO columns
+  FROM pg_attribute
+  WHERE attrelid = 'config_items'::regclass AND attnum > 0 AND NOT attisdropped;
+
+  EXECUTE format('CREATE OR REPLACE VIEW config_items_masked AS
+    SELECT %s, m.paths AS masked_paths
+    FROM config_items AS ci
+      CROSS JOIN LATERAL mask_config(ci.type, ci.config) AS m', columns);
+END $$;
+
 DROP VIEW IF EXISTS config_analysis_items;
 
 -- Used by resource selector search for config analysis / insights.
@@ -676,14 +697,15 @@ CREATE OR REPLACE VIEW config_analysis_items AS
     ci.type,
     ci.tags,
     ci.labels,
-    ci.config,
+    cm.config,
     ci.agent_id,
     ci.path,
     ci.name as config_name,
     ci.type as config_type,
     ci.config_class
   FROM config_analysis as ca
-    LEFT JOIN config_items as ci ON ca.config_id = ci.id;
+    LEFT JOIN config_items as ci ON ca.config_id = ci.id
+    LEFT JOIN config_items_masked as cm ON cm.id = ci.id;
 
 -- related_config_ids_recursive---
 DROP FUNCTION IF EXISTS related_config_ids_recursive;
@@ -1055,7 +1077,7 @@ CREATE OR REPLACE VIEW catalog_changes AS
     c.deleted_at,
     c.type,
     c.tags,
-    c.config,
+    cm.config,
     cc.external_created_by,
     cc.created_at,
     cc.severity,
@@ -1071,7 +1093,8 @@ CREATE OR REPLACE VIEW catalog_changes AS
     c.path,
     cc.inserted_at
   FROM config_changes cc
-  LEFT JOIN config_items c on c.id = cc.config_id;
+  LEFT JOIN config_items c on c.id = cc.config_id
+  LEFT JOIN config_items_masked cm on cm.id = cc.config_id;
 
 DROP VIEW IF EXISTS config_detail;
 
@@ -1091,7 +1114,7 @@ CREATE OR REPLACE VIEW config_detail AS
       'id', config_scrapers.id,
       'name', config_scrapers.name
     ) ELSE NULL END as scraper
-  FROM config_items as ci
+  FROM config_items_masked as ci
     LEFT JOIN agents ON agents.id = ci.agent_id
     LEFT JOIN config_items_last_scraped_time ON config_items_last_scraped_time.config_id = ci.id
     LEFT JOIN config_scrapers ON config_scrapers.id = ci.scraper_id
@@ -1,6 +1,9 @@
 -- We drop this first because of dependencies
 DROP VIEW IF EXISTS integrations_with_status;
 
+-- Recreated with its dependents below, as new job_history columns change its columns
+DROP VIEW IF EXISTS job_history_latest_status CASCADE;
+
 -- Intermediate view to get the latest job history status for each resource
 CREATE OR REPLACE VIEW
   job_history_latest_status AS
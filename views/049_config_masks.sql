@@ -0,0 +1,21 @@
+-- Config mask functions are defined in functions/config_masks.sql
+
+-- Replaced by the config_items_masked view
+DROP FUNCTION IF EXISTS masked_config(config_items);
+DROP FUNCTION IF EXISTS masked_paths(config_items);
+
+-- config_items.config stays readable by the PostgREST roles, clients read the masked config
+-- from config_items_masked or config_detail. An earlier version of this script revoked it,
+-- so the grant on the table is restored.
+DO $$
+DECLARE
+  role_name text;
+BEGIN
+  FOREACH role_name IN ARRAY ARRAY['postgrest_api', 'postgrest_anon']
+  LOOP
+    IF EXISTS (SELECT FROM pg_catalog.pg_roles WHERE rolname = role_name) THEN
+      EXECUTE format('GRANT SELECT ON config_items TO %I', role_name);
+      EXECUTE format('GRANT SELECT ON config_items_masked TO %I', role_name);
+    END IF;
+  END LOOP;
+END $$;
@@ -154,6 +154,22 @@ BEGIN
         EXECUTE 'ALTER TABLE checks ENABLE ROW LEVEL SECURITY;';
     END IF;
 
+    IF NOT (SELECT relrowsecurity FROM pg_class WHERE relname = 'check_statuses') THEN
+        EXECUTE 'ALTER TABLE check_statuses ENABLE ROW LEVEL SECURITY;';
+    END IF;
+
+    IF NOT (SELECT relrowsecurity FROM pg_class WHERE relname = 'incidents') THEN
+        EXECUTE 'ALTER TABLE incidents ENABLE ROW LEVEL SECURITY;';
+    END IF;
+
+    IF NOT (SELECT relrowsecurity FROM pg_class WHERE relname = 'notifications') THEN
+        EXECUTE 'ALTER TABLE notifications ENABLE ROW LEVEL SECURITY;';
+    END IF;
+
+    IF NOT (SELECT relrowsecurity FROM pg_class WHERE relname = 'notification_send_history') THEN
+        EXECUTE 'ALTER TABLE notification_send_history ENABLE ROW LEVEL SECURITY;';
+    END IF;
+
     -- Another relation called "views" exists in the information_schema schema.
     IF NOT (SELECT c.relrowsecurity FROM pg_class c JOIN pg_namespace n ON c.relnamespace = n.oid WHERE c.relname = 'views' AND n.nspname = 'public') THEN
         EXECUTE 'ALTER TABLE views ENABLE ROW LEVEL SECURITY;';
@@ -364,12 +380,21 @@ CREATE POLICY playbook_runs_auth ON playbook_runs
     );
 
 -- Policy checks
+-- Checks are scoped by the check claim when it is present, otherwise by the RLS on their canary.
 DROP POLICY IF EXISTS checks_auth ON checks;
 
 CREATE POLICY checks_auth ON checks
   FOR ALL TO postgrest_api, postgrest_anon
     USING (
       CASE WHEN (SELECT is_rls_disabled()) THEN TRUE
+      WHEN current_setting('request.jwt.claims', TRUE)::jsonb ? 'check' THEN
+        match_scope(
+          current_setting('request.jwt.claims', TRUE)::jsonb -> 'check',
+          checks.labels,
+          checks.agent_id,
+          checks.name,
+          checks.id
+        )
       ELSE EXISTS (
         -- just leverage the RLS on canaries
         SELECT 1
@@ -379,6 +404,78 @@ CREATE POLICY checks_auth ON checks
       END
     );
 
+-- Policy check_statuses
+DROP POLICY IF EXISTS check_statuses_auth ON check_statuses;
+
+CREATE POLICY check_statuses_auth ON check_statuses
+  FOR ALL TO postgrest_api, postgrest_anon
+    USING (
+      CASE WHEN (SELECT is_rls_disabled()) THEN TRUE
+      ELSE EXISTS (
+        -- just leverage the RLS on checks
+        SELECT 1
+        FROM checks
+        WHERE checks.id = check_statuses.check_id
+      )
+      END
+    );
+
+-- Policy incidents
+-- Incidents are only scoped when the payload has an incident claim.
+DROP POLICY IF EXISTS incidents_auth ON incidents;
+
+CREATE POLICY incidents_auth ON incidents
+  FOR ALL TO postgrest_api, postgrest_anon
+    USING (
+      CASE WHEN (SELECT is_rls_disabled()) THEN TRUE
+      WHEN NOT (current_setting('request.jwt.claims', TRUE)::jsonb ? 'incident') THEN TRUE
+      ELSE
+        match_scope(
+          current_setting('request.jwt.claims', TRUE)::jsonb -> 'incident',
+          NULL,
+          NULL,
+          incidents.title,
+          incidents.id
+        )
+      END
+    );
+
+-- Policy notifications
+-- Notifications are only scoped when the payload has a notification claim.
+DROP POLICY IF EXISTS notifications_auth ON notifications;
+
+CREATE POLICY notifications_auth ON notifications
+  FOR ALL TO postgrest_api, postgrest_anon
+    USING (
+      CASE WHEN (SELECT is_rls_disabled()) THEN TRUE
+      WHEN NOT (current_setting('request.jwt.claims', TRUE)::jsonb ? 'notification') THEN TRUE
+      ELSE
+        match_scope(
+          current_setting('request.jwt.claims', TRUE)::jsonb -> 'notification',
+          NULL,
+          NULL,
+          notifications.name,
+          notifications.id
+        )
+      END
+    );
+
+-- Policy notification_send_history
+DROP POLICY IF EXISTS notification_send_history_auth ON notification_send_history;
+
+CREATE POLICY notification_send_history_auth ON notification_send_history
+  FOR ALL TO postgrest_api, postgrest_anon
+    USING (
+      CASE WHEN (SELECT is_rls_disabled()) THEN TRUE
+      ELSE EXISTS (
+        -- just leverage the RLS on notifications
+        SELECT 1
+        FROM notifications
+        WHERE notifications.id = notification_send_history.notification_id
+      )
+      END
+    );
+
 -- Policy views
 DROP POLICY IF EXISTS views_auth ON views;
 
@@ -414,6 +511,8 @@ CREATE POLICY view_panels_auth ON view_panels
 
 ALTER VIEW analysis_by_config SET (security_invoker = true);
 ALTER VIEW catalog_changes SET (security_invoker = true);
+ALTER VIEW changes_by_component SET (security_invoker = true);
+ALTER VIEW check_status_summary_hour SET (security_invoker = true);
 ALTER VIEW check_summary SET (security_invoker = true);
 ALTER VIEW check_summary_by_config SET (security_invoker = true);
 ALTER VIEW check_summary_for_config SET (security_invoker = true);
@@ -427,6 +526,7 @@ ALTER VIEW config_changes_by_types SET (security_invoker = true);
 ALTER VIEW config_class_summary SET (security_invoker = true);
 ALTER VIEW config_classes SET (security_invoker = true);
 ALTER VIEW config_detail SET (security_invoker = true);
+ALTER VIEW config_items_masked SET (security_invoker = true);
 ALTER VIEW config_labels SET (security_invoker = true);
 ALTER VIEW config_names SET (security_invoker = true);
 ALTER VIEW config_scrapers_with_status SET (security_invoker = true);
@@ -438,6 +538,10 @@ ALTER VIEW config_types SET (security_invoker = true);
 ALTER VIEW configs SET (security_invoker = true);
 ALTER VIEW external_group_summary SET (security_invoker = true);
 ALTER VIEW topology SET (security_invoker = true);
+ALTER VIEW incident_summary SET (security_invoker = true);
+ALTER VIEW incidents_by_component SET (security_invoker = true);
 ALTER VIEW incidents_by_config SET (security_invoker = true);
+ALTER VIEW notification_send_history_resources SET (security_invoker = true);
+ALTER VIEW notification_send_history_summary SET (security_invoker = true);
 ALTER VIEW playbook_names SET (security_invoker = true);
 ALTER VIEW views_summary SET (security_invoker = true);
@@ -49,6 +49,22 @@ BEGIN
         EXECUTE 'ALTER TABLE checks DISABLE ROW LEVEL SECURITY;';
     END IF;
 
+    IF (SELECT relrowsecurity FROM pg_class WHERE relname = 'check_statuses') THEN
+        EXECUTE 'ALTER TABLE check_statuses DISABLE ROW LEVEL SECURITY;';
+    END IF;
+
+    IF (SELECT relrowsecurity FROM pg_class WHERE relname = 'incidents') THEN
+        EXECUTE 'ALTER TABLE incidents DISABLE ROW LEVEL SECURITY;';
+    END IF;
+
+    IF (SELECT relrowsecurity FROM pg_class WHERE relname = 'notifications') THEN
+        EXECUTE 'ALTER TABLE notifications DISABLE ROW LEVEL SECURITY;';
+    END IF;
+
+    IF (SELECT relrowsecurity FROM pg_class WHERE relname = 'notification_send_history') THEN
+        EXECUTE 'ALTER TABLE notification_send_history DISABLE ROW LEVEL SECURITY;';
+    END IF;
+
     IF (SELECT c.relrowsecurity FROM pg_class c JOIN pg_namespace n ON c.relnamespace = n.oid WHERE c.relname = 'views' AND n.nspname = 'public') THEN
         EXECUTE 'ALTER TABLE views DISABLE ROW LEVEL SECURITY;';
     END IF;
@@ -83,6 +99,14 @@ DROP POLICY IF EXISTS playbook_runs_auth ON playbook_runs;
 
 DROP POLICY IF EXISTS checks_auth ON checks;
 
+DROP POLICY IF EXISTS check_statuses_auth ON check_statuses;
+
+DROP POLICY IF EXISTS incidents_auth ON incidents;
+
+DROP POLICY IF EXISTS notifications_auth ON notifications;
+
+DROP POLICY IF EXISTS notification_send_history_auth ON notification_send_history;
+
 DROP POLICY IF EXISTS views_auth ON views;
 
 DROP POLICY IF EXISTS view_panels_auth ON view_panels;